@@ -0,0 +1,507 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"github.com/rrebeiz/quickmovies/internal/i18n"
+	"github.com/rrebeiz/quickmovies/internal/seed"
+	"github.com/rrebeiz/quickmovies/internal/validator"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// errIntegrity makes the integrity command exit with a failure when it finds
+// problems.
+var errIntegrity = errors.New("integrity problems found")
+
+// admin runs the administration commands, given after the config flags:
+//
+//	backend -storage sqlite -db-dsn sqlite://movies.db admin users list
+//
+// so they use the same database as the server, configured the same way.
+type admin struct {
+	db              *sql.DB
+	movies          data.Movies
+	trash           data.Trash
+	users           data.Users
+	tokens          data.Tokens
+	idempotencyKeys data.IdempotencyKeys
+	webhooks        data.Webhooks
+	stdout          io.Writer
+	stderr          io.Writer
+}
+
+type adminCommand struct {
+	name  string
+	usage string
+	run   func(a *admin, ctx context.Context, fs *flag.FlagSet, output *adminOutput, args []string) error
+}
+
+var adminCommands = []adminCommand{
+	{"users create", "create a user, optionally granting it permissions", (*admin).createUser},
+	{"users list", "list the users and their permissions", (*admin).listUsers},
+	{"permissions list", "list the permissions, or those of a user with -email", (*admin).listPermissions},
+	{"permissions grant", "grant permissions to a user", (*admin).grantPermissions},
+	{"permissions revoke", "revoke permissions from a user", (*admin).revokePermissions},
+	{"tokens issue", "issue an API token for a user, shown once", (*admin).issueToken},
+	{"tokens revoke", "revoke a token, or every token of a user with -email", (*admin).revokeTokens},
+	{"seed", "create or update the movies of a fixture set", (*admin).seed},
+	{"purge", "delete expired tokens and idempotency keys, and old trashed movies, webhook deliveries and events", (*admin).purge},
+	{"integrity", "check the schema, the movies and the relations between tables", (*admin).integrity},
+}
+
+// newAdmin opens the database of cfg. The admin commands need one of the
+// database storages, since the memory storage only lives in the server.
+func newAdmin(cfg config, stdout, stderr io.Writer) (*admin, error) {
+	if cfg.storage != "postgres" && cfg.storage != "sqlite" {
+		return nil, fmt.Errorf("the admin commands need the postgres or sqlite storage, not %s", cfg.storage)
+	}
+	db, err := openDB(cfg, cfg.db.dsn)
+	if err != nil {
+		return nil, err
+	}
+	a := &admin{db: db, stdout: stdout, stderr: stderr}
+	if cfg.storage == "sqlite" {
+		a.movies = data.NewSQLiteMovieModel(db)
+		a.trash = data.NewSQLiteMovieModel(db)
+		a.users = data.NewSQLiteUserModel(db)
+		a.tokens = data.NewSQLiteTokenModel(db)
+		a.idempotencyKeys = data.NewSQLiteIdempotencyKeyModel(db)
+		a.webhooks = data.NewSQLiteWebhookModel(db)
+	} else {
+		a.movies = data.NewMovieModel(db)
+		a.trash = data.NewMovieModel(db)
+		a.users = data.NewUserModel(db)
+		a.tokens = data.NewTokenModel(db)
+		a.idempotencyKeys = data.NewIdempotencyKeyModel(db)
+		a.webhooks = data.NewWebhookModel(db)
+	}
+	return a, nil
+}
+
+// runAdmin runs the admin command in args and returns the exit code.
+func runAdmin(cfg config, args []string) int {
+	a, err := newAdmin(cfg, os.Stdout, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer a.db.Close()
+
+	err = a.run(context.Background(), args)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errIntegrity):
+		return 1
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+}
+
+// run finds the command named by the leading args and runs it with the rest.
+func (a *admin) run(ctx context.Context, args []string) error {
+	for _, cmd := range adminCommands {
+		words := strings.Fields(cmd.name)
+		if len(args) < len(words) || strings.Join(args[:len(words)], " ") != cmd.name {
+			continue
+		}
+		fs := flag.NewFlagSet("admin "+cmd.name, flag.ContinueOnError)
+		fs.SetOutput(a.stderr)
+		output := &adminOutput{w: a.stdout}
+		fs.BoolVar(&output.json, "json", false, "print JSON instead of text")
+		fs.Usage = func() {
+			fmt.Fprintf(a.stderr, "usage: admin %s [flags]\n\n%s\n\n", cmd.name, cmd.usage)
+			fs.PrintDefaults()
+		}
+		return cmd.run(a, ctx, fs, output, args[len(words):])
+	}
+
+	a.usage()
+	if len(args) == 0 {
+		return errors.New("missing admin command")
+	}
+	return fmt.Errorf("unknown admin command %q", strings.Join(args, " "))
+}
+
+func (a *admin) usage() {
+	fmt.Fprintln(a.stderr, "usage: admin <command> [flags]")
+	fmt.Fprintln(a.stderr)
+	tw := tabwriter.NewWriter(a.stderr, 0, 8, 2, ' ', 0)
+	for _, cmd := range adminCommands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.usage)
+	}
+	tw.Flush()
+}
+
+// adminOutput prints the result of a command as JSON with -json, and as text
+// otherwise.
+type adminOutput struct {
+	w    io.Writer
+	json bool
+}
+
+func (o *adminOutput) print(v any, text func(w io.Writer)) error {
+	if !o.json {
+		tw := tabwriter.NewWriter(o.w, 0, 8, 2, ' ', 0)
+		text(tw)
+		return tw.Flush()
+	}
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
+
+// userWithPermissions is how users are printed.
+type userWithPermissions struct {
+	*data.User
+	Permissions []string `json:"permissions"`
+}
+
+func (a *admin) createUser(ctx context.Context, fs *flag.FlagSet, output *adminOutput, args []string) error {
+	var user data.User
+	var permissions string
+	fs.StringVar(&user.Name, "name", "", "the name of the user")
+	fs.StringVar(&user.Email, "email", "", "the email of the user")
+	fs.StringVar(&permissions, "permissions", "", "comma separated permissions to grant")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	codes, err := permissionCodes(permissions, false)
+	if err != nil {
+		return err
+	}
+
+	v := validator.NewValidator()
+	data.ValidateUser(v, &user)
+	if !v.Valid() {
+		return validationError("user", v)
+	}
+	err = a.users.CreateUser(ctx, &user)
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicateEmail) {
+			return fmt.Errorf("a user with the email %s already exists", user.Email)
+		}
+		return err
+	}
+	if len(codes) > 0 {
+		err = a.users.GrantPermissions(ctx, user.ID, codes...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return output.print(envelope{"user": userWithPermissions{&user, codes}}, func(w io.Writer) {
+		fmt.Fprintf(w, "created user %d %s <%s> with permissions %s\n", user.ID, user.Name, user.Email, formatPermissions(codes))
+	})
+}
+
+func (a *admin) listUsers(ctx context.Context, fs *flag.FlagSet, output *adminOutput, args []string) error {
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	users, err := a.users.GetAllUsers(ctx)
+	if err != nil {
+		return err
+	}
+	list := make([]userWithPermissions, 0, len(users))
+	for _, user := range users {
+		codes, err := a.users.GetPermissions(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		list = append(list, userWithPermissions{user, codes})
+	}
+
+	return output.print(envelope{"users": list}, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tEMAIL\tPERMISSIONS\tCREATED")
+		for _, user := range list {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", user.ID, user.Name, user.Email, formatPermissions(user.Permissions), user.CreatedAt.Format(time.RFC3339))
+		}
+	})
+}
+
+func (a *admin) listPermissions(ctx context.Context, fs *flag.FlagSet, output *adminOutput, args []string) error {
+	email := fs.String("email", "", "only list the permissions of this user")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	codes := data.PermissionCodes
+	if *email != "" {
+		user, err := a.findUser(ctx, *email)
+		if err != nil {
+			return err
+		}
+		codes, err = a.users.GetPermissions(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return output.print(envelope{"permissions": codes}, func(w io.Writer) {
+		for _, code := range codes {
+			fmt.Fprintln(w, code)
+		}
+	})
+}
+
+func (a *admin) grantPermissions(ctx context.Context, fs *flag.FlagSet, output *adminOutput, args []string) error {
+	return a.changePermissions(ctx, fs, output, args, "granted", a.users.GrantPermissions)
+}
+
+func (a *admin) revokePermissions(ctx context.Context, fs *flag.FlagSet, output *adminOutput, args []string) error {
+	return a.changePermissions(ctx, fs, output, args, "revoked", a.users.RevokePermissions)
+}
+
+func (a *admin) changePermissions(ctx context.Context, fs *flag.FlagSet, output *adminOutput, args []string, done string, change func(context.Context, int64, ...string) error) error {
+	email := fs.String("email", "", "the email of the user")
+	permissions := fs.String("permissions", "", "comma separated permissions")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	codes, err := permissionCodes(*permissions, true)
+	if err != nil {
+		return err
+	}
+	user, err := a.findUser(ctx, *email)
+	if err != nil {
+		return err
+	}
+	err = change(ctx, user.ID, codes...)
+	if err != nil {
+		return err
+	}
+	current, err := a.users.GetPermissions(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return output.print(envelope{"user": userWithPermissions{user, current}}, func(w io.Writer) {
+		fmt.Fprintf(w, "%s %s, %s now has %s\n", done, formatPermissions(codes), user.Email, formatPermissions(current))
+	})
+}
+
+func (a *admin) issueToken(ctx context.Context, fs *flag.FlagSet, output *adminOutput, args []string) error {
+	email := fs.String("email", "", "the email of the user")
+	ttl := fs.Duration("ttl", 30*24*time.Hour, "how long the token is valid")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *ttl <= 0 {
+		return errors.New("ttl must be greater than 0")
+	}
+	user, err := a.findUser(ctx, *email)
+	if err != nil {
+		return err
+	}
+	token, err := a.tokens.NewToken(ctx, user.ID, *ttl, data.ScopeAPI)
+	if err != nil {
+		return err
+	}
+
+	return output.print(envelope{"token": token}, func(w io.Writer) {
+		fmt.Fprintf(w, "%s\n\nissued for %s until %s, it is not shown again\n", token.Plaintext, user.Email, token.Expiry.Format(time.RFC3339))
+	})
+}
+
+func (a *admin) revokeTokens(ctx context.Context, fs *flag.FlagSet, output *adminOutput, args []string) error {
+	plaintext := fs.String("token", "", "the token to revoke")
+	email := fs.String("email", "", "revoke every token of this user instead")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if (*plaintext == "") == (*email == "") {
+		return errors.New("either -token or -email must be set")
+	}
+
+	var revoked int64 = 1
+	if *plaintext != "" {
+		err = a.tokens.RevokeToken(ctx, *plaintext)
+		if errors.Is(err, data.ErrNoRecordFound) {
+			return errors.New("the token does not exist or has already been revoked")
+		}
+	} else {
+		var user *data.User
+		user, err = a.findUser(ctx, *email)
+		if err != nil {
+			return err
+		}
+		revoked, err = a.tokens.RevokeAllTokens(ctx, user.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return output.print(envelope{"revoked": revoked}, func(w io.Writer) {
+		fmt.Fprintf(w, "revoked %d tokens\n", revoked)
+	})
+}
+
+func (a *admin) seed(ctx context.Context, fs *flag.FlagSet, output *adminOutput, args []string) error {
+	name := fs.String("set", "demo", "the fixture set to load")
+	list := fs.Bool("list", false, "list the fixture sets instead")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *list {
+		sets := seed.Sets()
+		summaries := make([]envelope, 0, len(sets))
+		for _, set := range sets {
+			summaries = append(summaries, envelope{"name": set.Name, "description": set.Description})
+		}
+		return output.print(envelope{"sets": summaries}, func(w io.Writer) {
+			for _, set := range sets {
+				fmt.Fprintf(w, "%s\t%s\n", set.Name, set.Description)
+			}
+		})
+	}
+
+	set, ok := seed.Lookup(*name)
+	if !ok {
+		return fmt.Errorf("unknown fixture set %q, see seed -list", *name)
+	}
+	result, err := seed.Load(ctx, a.movies, set)
+	if err != nil {
+		return err
+	}
+
+	return output.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "loaded the %s set: created %d, updated %d and left %d movies unchanged\n", set.Name, result.Created, result.Updated, result.Unchanged)
+	})
+}
+
+func (a *admin) purge(ctx context.Context, fs *flag.FlagSet, output *adminOutput, args []string) error {
+	keep := fs.Duration("keep", 30*24*time.Hour, "how long trashed movies, finished webhook deliveries and the events sent to webhooks are kept")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *keep <= 0 {
+		return errors.New("keep must be greater than 0")
+	}
+	tokens, err := a.tokens.DeleteExpiredTokens(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := a.idempotencyKeys.DeleteExpiredKeys(ctx)
+	if err != nil {
+		return err
+	}
+	before := time.Now().Add(-*keep)
+	movies, err := a.trash.PurgeTrash(ctx, before)
+	if err != nil {
+		return err
+	}
+	deliveries, events, err := a.webhooks.DeleteOldEvents(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	result := envelope{
+		"expired_tokens":           tokens,
+		"expired_idempotency_keys": keys,
+		"trashed_movies":           movies,
+		"old_webhook_deliveries":   deliveries,
+		"old_events":               events,
+	}
+	return output.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "deleted %d expired tokens, %d expired idempotency keys, %d trashed movies, %d old webhook deliveries and %d old events\n", tokens, keys, movies, deliveries, events)
+	})
+}
+
+func (a *admin) integrity(ctx context.Context, fs *flag.FlagSet, output *adminOutput, args []string) error {
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	problems, err := data.CheckIntegrity(ctx, a.db, a.movies)
+	if err != nil {
+		return err
+	}
+
+	err = output.print(envelope{"problems": problems}, func(w io.Writer) {
+		if len(problems) == 0 {
+			fmt.Fprintln(w, "no problems found")
+			return
+		}
+		fmt.Fprintln(w, "CHECK\tPROBLEM")
+		for _, problem := range problems {
+			fmt.Fprintf(w, "%s\t%s\n", problem.Check, problem.Message)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return errIntegrity
+	}
+	return nil
+}
+
+func (a *admin) findUser(ctx context.Context, email string) (*data.User, error) {
+	if email == "" {
+		return nil, errors.New("-email must be set")
+	}
+	user, err := a.users.GetUserByEmail(ctx, email)
+	if errors.Is(err, data.ErrNoRecordFound) {
+		return nil, fmt.Errorf("no user with the email %s", email)
+	}
+	return user, err
+}
+
+// permissionCodes splits a comma separated list of permissions and checks
+// that they exist.
+func permissionCodes(list string, required bool) ([]string, error) {
+	codes := []string{}
+	for _, code := range strings.Split(list, ",") {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		if !validator.PermittedValue(code, data.PermissionCodes...) {
+			return nil, fmt.Errorf("unknown permission %q, expected %s", code, strings.Join(data.PermissionCodes, ", "))
+		}
+		codes = append(codes, code)
+	}
+	if required && len(codes) == 0 {
+		return nil, errors.New("-permissions must be set")
+	}
+	sort.Strings(codes)
+	return codes, nil
+}
+
+func formatPermissions(codes []string) string {
+	if len(codes) == 0 {
+		return "none"
+	}
+	return strings.Join(codes, ",")
+}
+
+// validationError lists the failed checks of v like config.validate.
+func validationError(name string, v *validator.Validator) error {
+	var lines []string
+	for _, key := range v.Fields() {
+		for _, e := range v.Errors[key] {
+			lines = append(lines, fmt.Sprintf("%s: %s", key, e.Localize(i18n.DefaultLanguage)))
+		}
+	}
+	return fmt.Errorf("invalid %s:\n  %s", name, strings.Join(lines, "\n  "))
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestAdmin runs the admin commands against a migrated SQLite database.
+func newTestAdmin(t *testing.T) (*admin, *bytes.Buffer) {
+	t.Helper()
+	var cfg config
+	cfg.storage = "sqlite"
+	cfg.db.dsn = sqliteScheme + filepath.Join(t.TempDir(), "admin.db")
+	cfg.db.maxOpenConns = 1
+	cfg.db.maxIdleTime = "15m"
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	a, err := newAdmin(cfg, &stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.db.Close() })
+
+	files, err := filepath.Glob("../../migrations/sqlite/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = a.db.Exec(string(migration))
+		if err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+	}
+	_, err = a.db.Exec(`create table schema_migrations (version bigint not null primary key, dirty boolean not null); insert into schema_migrations values (?, false)`, len(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, &stdout
+}
+
+func TestAdminCommands(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           string
+		expectedError  string
+		expectedOutput string
+	}{
+		{"create user test", "users create -name Ada -email Ada@Example.com -permissions movies:read", "", "created user 1 Ada <ada@example.com> with permissions movies:read"},
+		{"duplicate user test", "users create -name Ada -email ada@example.com", "a user with the email ada@example.com already exists", ""},
+		{"invalid user test", "users create -email ada", "invalid user:\n  email: is not in a valid format\n  name: should not be empty", ""},
+		{"unknown permission test", "users create -name Bob -email bob@example.com -permissions movies:delete", `unknown permission "movies:delete"`, ""},
+		{"grant test", "permissions grant -email ada@example.com -permissions movies:write,webhooks:write", "", "granted movies:write,webhooks:write, ada@example.com now has movies:read,movies:write,webhooks:write"},
+		{"grant again test", "permissions grant -email ada@example.com -permissions movies:write", "", "now has movies:read,movies:write,webhooks:write"},
+		{"revoke test", "permissions revoke -email ada@example.com -permissions movies:read", "", "now has movies:write,webhooks:write"},
+		{"user permissions test", "permissions list -email ada@example.com -json", "", `"permissions": [`},
+		{"unknown user test", "permissions grant -email bob@example.com -permissions movies:read", "no user with the email bob@example.com", ""},
+		{"list users json test", "users list -json", "", `"email": "ada@example.com",`},
+		{"list users test", "users list", "", "1   Ada   ada@example.com  movies:write,webhooks:write"},
+		{"issue token test", "tokens issue -email ada@example.com -ttl 1h", "", "issued for ada@example.com until"},
+		{"revoke all tokens test", "tokens revoke -email ada@example.com", "", "revoked 1 tokens"},
+		{"revoke unknown token test", "tokens revoke -token ABCDEFGHIJKLMNOPQRSTUVWXYZ", "the token does not exist or has already been revoked", ""},
+		{"seed test", "seed", "", "loaded the demo set: created 12, updated 0 and left 0 movies unchanged"},
+		{"seed again test", "seed -json", "", "\"created\": 0,\n\t\"updated\": 0,\n\t\"unchanged\": 12"},
+		{"seed set test", "seed -set edge", "", "created 7"},
+		{"seed list test", "seed -list", "", "dev    3000 generated movies"},
+		{"unknown set test", "seed -set huge", `unknown fixture set "huge"`, ""},
+		{"purge test", "purge", "", "deleted 0 expired tokens, 0 expired idempotency keys, 0 trashed movies, 0 old webhook deliveries and 0 old events"},
+		{"invalid keep test", "purge -keep 0s", "keep must be greater than 0", ""},
+		{"integrity test", "integrity", "", "no problems found"},
+		{"unknown command test", "users delete", `unknown admin command "users delete"`, ""},
+	}
+
+	a, stdout := newTestAdmin(t)
+	for _, e := range tests {
+		stdout.Reset()
+		err := a.run(context.Background(), strings.Fields(e.args))
+		switch {
+		case e.expectedError == "" && err != nil:
+			t.Errorf("%s: expected no error but got %s", e.name, err)
+		case e.expectedError != "" && (err == nil || !strings.Contains(err.Error(), e.expectedError)):
+			t.Errorf("%s: expected %q but got %v", e.name, e.expectedError, err)
+		}
+		if !strings.Contains(stdout.String(), e.expectedOutput) {
+			t.Errorf("%s: expected %q in %q", e.name, e.expectedOutput, stdout.String())
+		}
+	}
+}
+
+func TestAdminTokensAndIntegrity(t *testing.T) {
+	a, stdout := newTestAdmin(t)
+	ctx := context.Background()
+
+	err := a.run(ctx, strings.Fields("users create -name Ada -email ada@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	err = a.run(ctx, strings.Fields("tokens issue -email ada@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := strings.TrimSpace(strings.SplitN(stdout.String(), "\n", 2)[0])
+	if len(token) != 26 {
+		t.Fatalf("expected a 26 character token but got %q", token)
+	}
+
+	users := data.NewSQLiteUserModel(a.db)
+	user, err := users.GetUserForToken(ctx, data.ScopeAPI, token)
+	if err != nil || user.Email != "ada@example.com" {
+		t.Errorf("expected the token to belong to ada@example.com but got %v, %v", user, err)
+	}
+
+	_, err = a.db.Exec(`update tokens set expiry = '2000-01-01 00:00:00.000'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = users.GetUserForToken(ctx, data.ScopeAPI, token)
+	if !errors.Is(err, data.ErrNoRecordFound) {
+		t.Errorf("expected an expired token to be refused but got %v", err)
+	}
+	stdout.Reset()
+	err = a.run(ctx, strings.Fields("purge"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "deleted 1 expired tokens") {
+		t.Errorf("expected the expired token to be purged but got %q", stdout.String())
+	}
+	err = a.run(ctx, []string{"tokens", "revoke", "-token", token})
+	if err == nil {
+		t.Error("expected the purged token to be gone")
+	}
+
+	// written around the API, which would reject both.
+	_, err = a.db.Exec(`insert into movies (title, year, runtime, genres) values ('', 2020, 100, '["western"]')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = a.db.Exec(`pragma foreign_keys = off; insert into tokens (hash, user_id, scope, expiry) values (x'00', 42, 'api', '2100-01-01 00:00:00.000'); pragma foreign_keys = on`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	err = a.run(ctx, strings.Fields("integrity"))
+	if !errors.Is(err, errIntegrity) {
+		t.Errorf("expected errIntegrity but got %v", err)
+	}
+	for _, expected := range []string{"invalid_movie", "movie 1: genres[0] please use the following permitted genres", "title should not be empty", "orphaned_tokens  1 rows"} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("expected %q in %q", expected, stdout.String())
+		}
+	}
+}
+
+func TestAdminPurgeWebhookEvents(t *testing.T) {
+	a, stdout := newTestAdmin(t)
+	ctx := context.Background()
+	webhooks := data.NewSQLiteWebhookModel(a.db)
+
+	err := webhooks.CreateWebhook(ctx, &data.Webhook{URL: "https://example.com/hook", Events: []string{data.EventMovieCreated}, Secret: "secret", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		err = a.movies.CreateMovie(ctx, &data.Movie{Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = webhooks.FanOutEvents(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// event 1 was delivered long ago, event 2 failed recently and event 3
+	// is still pending.
+	_, err = a.db.Exec(`update webhook_deliveries set status = 'succeeded', updated_at = '2000-01-01 00:00:00.000' where event_id = 1;
+		update webhook_deliveries set status = 'failed' where event_id = 2;
+		update outbox_events set fanned_out_at = '2000-01-01 00:00:00.000'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = a.run(ctx, strings.Fields("purge -keep 1h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "1 old webhook deliveries and 1 old events") {
+		t.Errorf("expected the delivered event to be purged but got %q", stdout.String())
+	}
+	var deliveries, events int
+	err = a.db.QueryRow(`select (select count(*) from webhook_deliveries), (select count(*) from outbox_events)`).Scan(&deliveries, &events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deliveries != 2 || events != 2 {
+		t.Errorf("expected the recent and pending deliveries and their events to be kept but got %d deliveries and %d events", deliveries, events)
+	}
+}
+
+func TestAdminPurgeTrash(t *testing.T) {
+	a, stdout := newTestAdmin(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		err := a.movies.CreateMovie(ctx, &data.Movie{Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []int64{1, 2} {
+		err := a.movies.DeleteMovie(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := a.db.Exec(`update movies set deleted_at = '2000-01-01 00:00:00.000' where id = 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = a.run(ctx, strings.Fields("purge -keep 1h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "1 trashed movies") {
+		t.Errorf("expected the movie trashed long ago to be purged but got %q", stdout.String())
+	}
+	var ids []int64
+	rows, err := a.db.Query(`select id from movies order by id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 3 {
+		t.Errorf("expected the recently trashed and the live movie to be kept but got %v", ids)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"github.com/rrebeiz/quickmovies/internal/validator"
+	"net/http"
+	"strings"
+)
+
+const userContextKey = contextKey("user")
+
+// tokenLength is the length of the tokens issued by the admin commands.
+const tokenLength = 26
+
+// authenticate identifies the user of the bearer token in the Authorization
+// header. Requests without the header stay anonymous, while an invalid or
+// expired token is refused.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || len(token) != tokenLength || app.models.Users == nil {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+		user, err := app.models.Users.GetUserForToken(r.Context(), data.ScopeAPI, token)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrNoRecordFound):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, user)))
+	})
+}
+
+// contextUser returns the authenticated user, or nil for anonymous requests.
+func contextUser(r *http.Request) *data.User {
+	user, _ := r.Context().Value(userContextKey).(*data.User)
+	return user
+}
+
+// requirePermission only lets authenticated users holding the permission code
+// through to next.
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := contextUser(r)
+		if user == nil {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+		permissions, err := app.models.Users.GetPermissions(r.Context(), user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !validator.PermittedValue(code, permissions...) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
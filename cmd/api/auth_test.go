@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	writerToken = "WRITERWRITERWRITERWRITER22"
+	readerToken = "READERREADERREADERREADER22"
+)
+
+// testUsers knows two users: the owner of writerToken may read and write
+// webhooks and read metrics, the owner of readerToken may only read webhooks.
+type testUsers struct {
+	data.Users
+}
+
+func (testUsers) GetUserForToken(ctx context.Context, scope, plaintext string) (*data.User, error) {
+	switch plaintext {
+	case writerToken:
+		return &data.User{ID: 1, Name: "writer", Email: "writer@example.com"}, nil
+	case readerToken:
+		return &data.User{ID: 2, Name: "reader", Email: "reader@example.com"}, nil
+	}
+	return nil, data.ErrNoRecordFound
+}
+
+func (testUsers) GetPermissions(ctx context.Context, userID int64) ([]string, error) {
+	if userID == 1 {
+		return []string{"metrics:read", "webhooks:read", "webhooks:write"}, nil
+	}
+	return []string{"webhooks:read"}, nil
+}
+
+func TestAuthentication(t *testing.T) {
+	tests := []struct {
+		name                 string
+		method               string
+		url                  string
+		authorization        string
+		expectedStatus       int
+		expectedAuthenticate string
+		expectedResponse     string
+	}{
+		{"anonymous test", "GET", "/v1/webhooks", "", http.StatusUnauthorized, "Bearer", "{\"error\":\"you must be authenticated to access this resource\"}\n"},
+		{"anonymous movies test", "GET", "/v1/movies/1", "", http.StatusOK, "", ""},
+		{"unknown token test", "GET", "/v1/webhooks", "Bearer AAAAAAAAAAAAAAAAAAAAAAAAAA", http.StatusUnauthorized, "Bearer", "{\"error\":\"invalid or missing authentication token\"}\n"},
+		{"wrong scheme test", "GET", "/v1/webhooks", "Basic " + readerToken, http.StatusUnauthorized, "Bearer", "{\"error\":\"invalid or missing authentication token\"}\n"},
+		{"short token test", "GET", "/v1/movies/1", "Bearer abc", http.StatusUnauthorized, "Bearer", "{\"error\":\"invalid or missing authentication token\"}\n"},
+		{"reader test", "GET", "/v1/webhooks", "Bearer " + readerToken, http.StatusOK, "", "{\"webhooks\":[]}\n"},
+		{"reader deliveries test", "GET", "/v1/webhooks/1/deliveries", "Bearer " + readerToken, http.StatusNotFound, "", ""},
+		{"reader write test", "DELETE", "/v1/webhooks/1", "Bearer " + readerToken, http.StatusForbidden, "", "{\"error\":\"your account does not have the permissions needed to access this resource\"}\n"},
+		{"writer test", "DELETE", "/v1/webhooks/1", "Bearer " + writerToken, http.StatusNotFound, "", ""},
+	}
+
+	app := testApp
+	app.models = newTestModels()
+	routes := app.routes()
+	for _, e := range tests {
+		req := httptest.NewRequest(e.method, e.url, nil)
+		if e.authorization != "" {
+			req.Header.Set("Authorization", e.authorization)
+		}
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if e.expectedAuthenticate != rr.Header().Get("WWW-Authenticate") {
+			t.Errorf("%s: expected WWW-Authenticate %q but got %q", e.name, e.expectedAuthenticate, rr.Header().Get("WWW-Authenticate"))
+		}
+		if e.expectedResponse != "" && e.expectedResponse != rr.Body.String() {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
+		}
+	}
+}
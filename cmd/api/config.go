@@ -0,0 +1,344 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rrebeiz/quickmovies/internal/validator"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// envPrefix is prepended to the upper-cased flag name to form the environment
+// variable for a setting, e.g. QUICKMOVIES_DB_DSN for -db-dsn.
+const envPrefix = "QUICKMOVIES_"
+
+type config struct {
+	env     string
+	port    int
+	storage string
+	db      struct {
+		dsn          string
+		maxOpenConns int
+		maxIdleConns int
+		maxIdleTime  string
+		replicaDSNs  stringList
+		stickiness   time.Duration
+	}
+	memory struct {
+		snapshot string
+	}
+	cache struct {
+		size int
+		ttl  time.Duration
+	}
+	tls struct {
+		certFile     string
+		keyFile      string
+		selfSigned   bool
+		redirectPort int
+	}
+	grpc struct {
+		port int
+	}
+	idempotency struct {
+		ttl time.Duration
+	}
+	events struct {
+		replay int
+	}
+	webhooks struct {
+		pollInterval time.Duration
+		maxAttempts  int
+		disableAfter int
+		allowPrivate bool
+	}
+	// trustedProxies may set X-Forwarded-For, as IPs or CIDR prefixes.
+	trustedProxies stringList
+	shutdownDelay  time.Duration
+	file           string
+	printConfig    bool
+}
+
+// parsePrefix parses a CIDR prefix, or a single IP as a prefix of its own.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// stringList is a flag.Value for flags that may be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// secretFlags are redacted by writeConfig.
+var secretFlags = map[string]bool{
+	"db-dsn":         true,
+	"db-replica-dsn": true,
+}
+
+func newFlagSet(cfg *config, getenv func(string) string) *flag.FlagSet {
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+	fs.StringVar(&cfg.env, "environment", "develop", "default app environment (develop|staging|production)")
+	fs.IntVar(&cfg.port, "port", 4000, "the default app port")
+	fs.StringVar(&cfg.db.dsn, "db-dsn", getenv("DSN"), "the database DSN")
+	fs.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "db max open connections")
+	fs.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "db max idle connections")
+	fs.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "db max idle time")
+	fs.Var(&cfg.db.replicaDSNs, "db-replica-dsn", "a read replica DSN, may be repeated")
+	fs.DurationVar(&cfg.db.stickiness, "db-read-your-writes", 5*time.Second, "how long reads from a client stay on the primary after it writes")
+	fs.StringVar(&cfg.storage, "storage", "postgres", "the storage backend (memory|postgres|sqlite)")
+	fs.StringVar(&cfg.memory.snapshot, "memory-snapshot", "", "optional JSON snapshot file loaded on startup and saved on shutdown by the memory storage")
+	fs.IntVar(&cfg.cache.size, "cache-size", 0, "max number of movies kept in the read-through cache, 0 disables it")
+	fs.DurationVar(&cfg.cache.ttl, "cache-ttl", time.Minute, "how long a cached movie is served before it is fetched again")
+	fs.StringVar(&cfg.tls.certFile, "tls-cert", "", "TLS certificate file, serves HTTPS when set together with -tls-key")
+	fs.StringVar(&cfg.tls.keyFile, "tls-key", "", "TLS private key file")
+	fs.BoolVar(&cfg.tls.selfSigned, "tls-self-signed", false, "serve HTTPS with a generated self-signed certificate, develop environment only")
+	fs.IntVar(&cfg.tls.redirectPort, "http-redirect-port", 0, "optional plain HTTP port that redirects to HTTPS, 0 disables it")
+	fs.IntVar(&cfg.grpc.port, "grpc-port", 0, "optional port for the gRPC MovieService, 0 disables it")
+	fs.DurationVar(&cfg.idempotency.ttl, "idempotency-ttl", 24*time.Hour, "how long responses to requests with an Idempotency-Key are kept for retries")
+	fs.IntVar(&cfg.events.replay, "events-replay", 1000, "most missed movie events replayed from the outbox to a client resuming /v1/movies/events, which is told to reset beyond that")
+	fs.DurationVar(&cfg.webhooks.pollInterval, "webhook-poll-interval", time.Second, "how often the outbox is checked for webhook deliveries")
+	fs.IntVar(&cfg.webhooks.maxAttempts, "webhook-max-attempts", 8, "delivery attempts before a webhook delivery is given up")
+	fs.IntVar(&cfg.webhooks.disableAfter, "webhook-disable-after", 20, "consecutive failed attempts after which a webhook is disabled")
+	fs.BoolVar(&cfg.webhooks.allowPrivate, "webhook-allow-private", false, "allow webhooks to loopback and private addresses, for local receivers")
+	fs.Var(&cfg.trustedProxies, "trusted-proxy", "an IP or CIDR prefix of a proxy whose X-Forwarded-For is trusted, may be repeated")
+	fs.DurationVar(&cfg.shutdownDelay, "shutdown-delay", 0, "how long the server keeps serving after its readiness probe starts failing on shutdown")
+	fs.StringVar(&cfg.file, "config", "", "optional YAML config file, keyed by flag name")
+	fs.BoolVar(&cfg.printConfig, "print-config", false, "print the resolved config with secrets redacted and exit")
+	return fs
+}
+
+// loadConfig resolves the config from args, the QUICKMOVIES_* environment
+// variables read through getenv and the config file, in that order of
+// precedence, falling back to the flag defaults.
+func loadConfig(args []string, getenv func(string) string) (config, *flag.FlagSet, error) {
+	var cfg config
+	fs := newFlagSet(&cfg, getenv)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return cfg, fs, err
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	if cfg.file == "" {
+		cfg.file = getenv(envName("config"))
+	}
+	file := make(map[string][]string)
+	if cfg.file != "" {
+		file, err = readConfigFile(cfg.file)
+		if err != nil {
+			return cfg, fs, err
+		}
+	}
+
+	var errs []string
+	fs.VisitAll(func(f *flag.Flag) {
+		fromFile, inFile := file[f.Name]
+		delete(file, f.Name)
+		if explicit[f.Name] || f.Name == "config" {
+			return
+		}
+		var values []string
+		if env := getenv(envName(f.Name)); env != "" {
+			values = []string{env}
+			if _, ok := f.Value.(*stringList); ok {
+				values = strings.Split(env, ",")
+			}
+		} else if inFile {
+			values = fromFile
+		}
+		for _, value := range values {
+			err := fs.Set(f.Name, value)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid value %q", f.Name, value))
+			}
+		}
+	})
+	for name := range file {
+		errs = append(errs, fmt.Sprintf("%s: unknown setting in %s", name, cfg.file))
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return cfg, fs, fmt.Errorf("invalid configuration:\n  %s", strings.Join(errs, "\n  "))
+	}
+
+	return cfg, fs, cfg.validate()
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// readConfigFile reads a flat YAML document mapping flag names to values.
+// Repeatable flags such as db-replica-dsn may be given as a list.
+func readConfigFile(path string) (map[string][]string, error) {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+	default:
+		return nil, fmt.Errorf("config file %s must be a .yaml or .yml file", path)
+	}
+
+	js, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+	err = yaml.Unmarshal(js, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := make(map[string][]string, len(raw))
+	for name, value := range raw {
+		switch value := value.(type) {
+		case []any:
+			for _, item := range value {
+				values[name] = append(values[name], fmt.Sprint(item))
+			}
+		case map[string]any:
+			return nil, fmt.Errorf("config file %s: %s must not be a mapping", path, name)
+		case nil:
+		default:
+			values[name] = []string{fmt.Sprint(value)}
+		}
+	}
+	return values, nil
+}
+
+func (cfg config) validate() error {
+	v := validator.NewValidator()
+
+	v.Check(validator.PermittedValue(cfg.env, "develop", "staging", "production"), "environment", "must be one of develop, staging or production")
+	v.Check(cfg.port > 0 && cfg.port <= 65535, "port", "must be between 1 and 65535")
+
+	v.Check(validator.PermittedValue(cfg.storage, "memory", "postgres", "sqlite"), "storage", "must be one of memory, postgres or sqlite")
+	if cfg.storage == "postgres" || cfg.storage == "sqlite" {
+		v.Check(cfg.db.dsn != "", "db-dsn", fmt.Sprintf("must be set for the %s storage", cfg.storage))
+		v.Check(cfg.db.dsn == "" || dbDriver(cfg.db.dsn) == cfg.storage, "db-dsn", fmt.Sprintf("scheme does not match the %s storage", cfg.storage))
+	}
+	v.Check(len(cfg.db.replicaDSNs) == 0 || cfg.storage == "postgres", "db-replica-dsn", "is only supported by the postgres storage")
+	v.Check(cfg.memory.snapshot == "" || cfg.storage == "memory", "memory-snapshot", "is only used by the memory storage")
+
+	v.Check(cfg.db.maxOpenConns > 0, "db-max-open-conns", "must be greater than 0")
+	v.Check(cfg.db.maxIdleConns >= 0, "db-max-idle-conns", "must not be negative")
+	v.Check(cfg.db.maxIdleConns <= cfg.db.maxOpenConns, "db-max-idle-conns", "must not be greater than db-max-open-conns")
+	_, err := time.ParseDuration(cfg.db.maxIdleTime)
+	v.Check(err == nil, "db-max-idle-time", fmt.Sprintf("must be a duration such as 15m, got %q", cfg.db.maxIdleTime))
+	v.Check(cfg.db.stickiness >= 0, "db-read-your-writes", "must not be negative")
+	for _, proxy := range cfg.trustedProxies {
+		_, err := parsePrefix(proxy)
+		v.Check(err == nil, "trusted-proxy", fmt.Sprintf("must be an IP or CIDR prefix, got %q", proxy))
+	}
+
+	v.Check(cfg.cache.size >= 0, "cache-size", "must not be negative")
+	v.Check(cfg.cache.size == 0 || cfg.cache.ttl > 0, "cache-ttl", "must be greater than 0 when the cache is enabled")
+
+	v.Check((cfg.tls.certFile == "") == (cfg.tls.keyFile == ""), "tls-cert", "must be set together with tls-key")
+	v.Check(!cfg.tls.selfSigned || cfg.tls.certFile == "", "tls-self-signed", "must not be combined with tls-cert")
+	v.Check(!cfg.tls.selfSigned || cfg.env == "develop", "tls-self-signed", "is only allowed in the develop environment")
+	v.Check(cfg.tls.redirectPort == 0 || cfg.useTLS(), "http-redirect-port", "requires TLS to be enabled")
+	v.Check(cfg.tls.redirectPort >= 0 && cfg.tls.redirectPort <= 65535, "http-redirect-port", "must be between 1 and 65535")
+	v.Check(cfg.tls.redirectPort != cfg.port, "http-redirect-port", "must differ from port")
+
+	v.Check(cfg.grpc.port >= 0 && cfg.grpc.port <= 65535, "grpc-port", "must be between 1 and 65535")
+	v.Check(cfg.grpc.port == 0 || (cfg.grpc.port != cfg.port && cfg.grpc.port != cfg.tls.redirectPort), "grpc-port", "must differ from port and http-redirect-port")
+
+	v.Check(cfg.idempotency.ttl > 0, "idempotency-ttl", "must be greater than 0")
+	v.Check(cfg.events.replay >= 0, "events-replay", "must not be negative")
+
+	v.Check(cfg.webhooks.pollInterval > 0, "webhook-poll-interval", "must be greater than 0")
+	v.Check(cfg.webhooks.maxAttempts > 0, "webhook-max-attempts", "must be greater than 0")
+	v.Check(cfg.webhooks.disableAfter > 0, "webhook-disable-after", "must be greater than 0")
+
+	v.Check(cfg.shutdownDelay >= 0, "shutdown-delay", "must not be negative")
+
+	if v.Valid() {
+		return nil
+	}
+
+	var lines []string
+	for _, key := range v.Fields() {
+		for _, e := range v.Errors[key] {
+			lines = append(lines, fmt.Sprintf("%s: %s", key, e.Message))
+		}
+	}
+	return errors.New("invalid configuration:\n  " + strings.Join(lines, "\n  "))
+}
+
+func (cfg config) useTLS() bool {
+	return cfg.tls.certFile != "" || cfg.tls.selfSigned
+}
+
+// writeConfig writes the resolved settings in the config file format, with
+// the credentials in DSNs redacted.
+func writeConfig(w io.Writer, fs *flag.FlagSet) error {
+	settings := make(map[string]any)
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "print-config" {
+			return
+		}
+		if list, ok := f.Value.(*stringList); ok {
+			values := make([]string, 0, len(*list))
+			for _, value := range *list {
+				values = append(values, redactDSN(value))
+			}
+			settings[f.Name] = values
+			return
+		}
+		if secretFlags[f.Name] {
+			settings[f.Name] = redactDSN(f.Value.String())
+			return
+		}
+		switch value := f.Value.(flag.Getter).Get().(type) {
+		case time.Duration:
+			settings[f.Name] = value.String()
+		default:
+			settings[f.Name] = value
+		}
+	})
+
+	out, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+var dsnPasswordRX = regexp.MustCompile(`(password=)('[^']*'|[^\s&]+)`)
+
+// redactDSN hides the password of both URL and key=value style DSNs.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		dsn = u.Redacted()
+	}
+	return dsnPasswordRX.ReplaceAllString(dsn, "${1}xxxxx")
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedMediaType is returned by readRequest for bodies in a
+	// format the API does not read.
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+	// errNotEncodable is returned by a format that cannot represent a
+	// response, such as CSV for a single movie. The response falls back to
+	// JSON.
+	errNotEncodable = errors.New("response cannot be encoded in this format")
+)
+
+// format encodes responses and decodes request bodies in one media type.
+// Every format works from the JSON representation of the data, so field
+// names and omitted fields are the same whatever the client asks for.
+type format struct {
+	// mediaTypes lists the media types of the format, the one used in
+	// responses first.
+	mediaTypes []string
+	// encode converts the JSON representation of a response. It is nil for
+	// JSON, which writeJSON writes directly.
+	encode func(js []byte, pretty bool) ([]byte, error)
+	// toJSON converts a request body to JSON for readJSON. dst is what the
+	// body will be decoded into, for formats that carry no types of their
+	// own. It is nil for JSON.
+	toJSON func(body []byte, dst any) ([]byte, error)
+}
+
+var (
+	jsonFormat = &format{
+		mediaTypes: []string{"application/json", problemJSONType},
+	}
+	xmlFormat = &format{
+		mediaTypes: []string{"application/xml", "text/xml", problemXMLType},
+		encode:     encodeXML,
+		toJSON:     decodeXML,
+	}
+	yamlFormat = &format{
+		mediaTypes: []string{"application/yaml", "application/x-yaml", "text/yaml"},
+		encode:     encodeYAML,
+		toJSON:     decodeYAML,
+	}
+	msgpackFormat = &format{
+		mediaTypes: []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"},
+		encode:     encodeMsgpack,
+		toJSON:     decodeMsgpack,
+	}
+	csvFormat = &format{
+		mediaTypes: []string{"text/csv"},
+		encode:     encodeCSV,
+		toJSON:     decodeCSV,
+	}
+
+	// responseFormats are offered by every negotiated route, in order of
+	// preference. listFormats add CSV for the routes answering with a list.
+	responseFormats = []*format{jsonFormat, xmlFormat, yamlFormat, msgpackFormat}
+	listFormats     = []*format{jsonFormat, xmlFormat, yamlFormat, msgpackFormat, csvFormat}
+
+	// requestFormats are the formats readRequest accepts as bodies.
+	requestFormats = []*format{jsonFormat, xmlFormat, yamlFormat, msgpackFormat, csvFormat}
+)
+
+func (f *format) contentType() string {
+	return f.mediaTypes[0]
+}
+
+type contextKey string
+
+const formatContextKey = contextKey("format")
+
+// negotiate picks the response format for the request from formats based on
+// the Accept header, and answers 406 Not Acceptable if the client accepts
+// none of them. This happens before the handler runs, so nothing is changed
+// for a response the client could not read.
+func (app *application) negotiate(formats ...*format) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept")
+			f := negotiateFormat(r.Header.Get("Accept"), formats)
+			if f == nil {
+				app.notAcceptableResponse(w, r, formats)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), formatContextKey, f)))
+		})
+	}
+}
+
+// responseFormat returns the format negotiated for r, JSON for routes that do
+// not negotiate.
+func responseFormat(r *http.Request) *format {
+	f, ok := r.Context().Value(formatContextKey).(*format)
+	if !ok {
+		return jsonFormat
+	}
+	return f
+}
+
+// negotiateFormat returns the format in formats the Accept header prefers,
+// or nil if none is acceptable. Each format gets the quality of the most
+// specific media range matching it, and ties go to the earlier format.
+func negotiateFormat(accept string, formats []*format) *format {
+	if strings.TrimSpace(accept) == "" {
+		return formats[0]
+	}
+
+	ranges := parseAccept(accept)
+	var best *format
+	bestQuality := 0.0
+	for _, f := range formats {
+		quality, specificity := 0.0, -1
+		for _, mediaType := range f.mediaTypes {
+			kind, _, _ := strings.Cut(mediaType, "/")
+			for _, rng := range ranges {
+				s := -1
+				switch rng.mediaType {
+				case mediaType:
+					s = 2
+				case kind + "/*":
+					s = 1
+				case "*/*":
+					s = 0
+				}
+				if s > specificity {
+					quality, specificity = rng.quality, s
+				}
+			}
+		}
+		if quality > bestQuality {
+			best, bestQuality = f, quality
+		}
+	}
+	return best
+}
+
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// parseAccept returns the media ranges of an Accept header, skipping the ones
+// it cannot parse.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+	}
+	return ranges
+}
+
+// writeResponse writes data in the format negotiated for r. A Content-Type in
+// headers replaces the one of the format.
+func (app *application) writeResponse(w http.ResponseWriter, r *http.Request, status int, data any, headers http.Header) error {
+	f := responseFormat(r)
+	if f == jsonFormat {
+		return app.writeJSON(w, status, data, headers)
+	}
+
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	body, err := f.encode(js, app.config.env == "develop")
+	if errors.Is(err, errNotEncodable) {
+		return app.writeJSON(w, status, data, headers)
+	}
+	if err != nil {
+		return err
+	}
+
+	for k, v := range headers {
+		w.Header()[k] = v
+	}
+	if headers.Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", f.contentType())
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+	return nil
+}
+
+// readRequest decodes the body of r into dst according to its Content-Type.
+// Bodies without a Content-Type are read as JSON. Other formats are converted
+// to JSON and then go through readJSON, so the same checks apply to every
+// format.
+func (app *application) readRequest(w http.ResponseWriter, r *http.Request, dst any) error {
+	f, err := requestFormat(r)
+	if err != nil {
+		return err
+	}
+	if f == jsonFormat {
+		return app.readJSON(w, r, dst)
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			return newRequestError("error.body_too_large", maxBodyBytes)
+		default:
+			return err
+		}
+	}
+	// an empty body is left to readJSON to report.
+	if len(bytes.TrimSpace(body)) > 0 {
+		body, err = f.toJSON(body, decodeTarget(r, dst))
+		if err != nil {
+			return err
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return app.readJSON(w, r, dst)
+}
+
+// requestFormat returns the format of the body of r.
+func requestFormat(r *http.Request) (*format, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return jsonFormat, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, unsupportedMediaType("error.invalid_content_type", contentType)
+	}
+	// curl -d labels JSON bodies as a form unless told otherwise, and the API
+	// never took forms, so those bodies are read as JSON as they always were.
+	if strings.HasSuffix(mediaType, "+json") || mediaType == "application/x-www-form-urlencoded" {
+		return jsonFormat, nil
+	}
+	for _, f := range requestFormats {
+		for _, t := range f.mediaTypes {
+			if t == mediaType {
+				return f, nil
+			}
+		}
+	}
+
+	var supported []string
+	for _, f := range requestFormats {
+		supported = append(supported, f.contentType())
+	}
+	return nil, unsupportedMediaType("error.unsupported_media_type", mediaType, strings.Join(supported, ", "))
+}
+
+// decodeTarget returns what the body of r is decoded into for dst. PATCH
+// bodies are decoded according to the patch format in Content-Type.
+func decodeTarget(r *http.Request, dst any) any {
+	if p, ok := dst.(*patch); ok {
+		return p.target(r)
+	}
+	return dst
+}
+
+func encodeYAML(js []byte, pretty bool) ([]byte, error) {
+	// JSON is YAML, so the document keeps the order of its keys. The styles
+	// the JSON syntax leaves on the nodes are cleared to get block YAML.
+	var doc yaml.Node
+	err := yaml.Unmarshal(js, &doc)
+	if err != nil {
+		return nil, err
+	}
+	var clearStyle func(n *yaml.Node)
+	clearStyle = func(n *yaml.Node) {
+		n.Style = 0
+		for _, c := range n.Content {
+			clearStyle(c)
+		}
+	}
+	clearStyle(&doc)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	err = enc.Encode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	err = enc.Close()
+	return buf.Bytes(), err
+}
+
+func decodeYAML(body []byte, dst any) ([]byte, error) {
+	var v any
+	err := yaml.Unmarshal(body, &v)
+	if err != nil {
+		return nil, newRequestError("error.body_badly_formed", "YAML")
+	}
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, newRequestError("error.body_no_json_equivalent", "YAML")
+	}
+	return js, nil
+}
+
+func encodeMsgpack(js []byte, pretty bool) ([]byte, error) {
+	v, err := orderedJSON(js)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	err = encodeMsgpackValue(enc, v)
+	return buf.Bytes(), err
+}
+
+// encodeMsgpackValue encodes a value returned by orderedJSON, keeping the
+// order of object members.
+func encodeMsgpackValue(enc *msgpack.Encoder, v any) error {
+	switch v := v.(type) {
+	case jsonObject:
+		err := enc.EncodeMapLen(len(v))
+		if err != nil {
+			return err
+		}
+		for _, m := range v {
+			err = enc.EncodeString(m.name)
+			if err != nil {
+				return err
+			}
+			err = encodeMsgpackValue(enc, m.value)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		err := enc.EncodeArrayLen(len(v))
+		if err != nil {
+			return err
+		}
+		for _, item := range v {
+			err = encodeMsgpackValue(enc, item)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return enc.EncodeInt(i)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		return enc.EncodeFloat64(f)
+	default:
+		return enc.Encode(v)
+	}
+}
+
+func decodeMsgpack(body []byte, dst any) ([]byte, error) {
+	var v any
+	err := msgpack.Unmarshal(body, &v)
+	if err != nil {
+		return nil, newRequestError("error.body_badly_formed", "MessagePack")
+	}
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, newRequestError("error.body_no_json_equivalent", "MessagePack")
+	}
+	return js, nil
+}
+
+// jsonObject is a JSON object that keeps the order of its members.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	name  string
+	value any
+}
+
+// orderedJSON decodes js into jsonObject, []any, json.Number, string, bool
+// and nil values.
+func orderedJSON(js []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	return decodeOrderedValue(dec)
+}
+
+func decodeOrderedValue(dec *json.Decoder) (any, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		obj := jsonObject{}
+		for dec.More() {
+			name, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, jsonMember{name: name.(string), value: value})
+		}
+		_, err = dec.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			value, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err = dec.Token()
+		return arr, err
+	default:
+		return token, nil
+	}
+}
+
+// textField is a field of a body in a format without types, XML or CSV.
+type textField struct {
+	name   string
+	values []string
+}
+
+// textToJSON converts fields to a JSON object using the types of the fields
+// of dst, found by their JSON names. Values that do not fit the type of their
+// field, and fields dst does not have, are kept as strings so readJSON
+// reports them like it does for JSON bodies. A single value for a list is
+// split on separator, unless it is empty.
+func textToJSON(fields []textField, dst any, formatName, separator string) ([]byte, error) {
+	t := reflect.TypeOf(dst)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, unsupportedMediaType("error.unsupported_body_format", formatName)
+	}
+
+	types := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		types[name] = field.Type
+	}
+
+	obj := make(map[string]any, len(fields))
+	for _, field := range fields {
+		ft, ok := types[field.name]
+		if !ok {
+			obj[field.name] = strings.Join(field.values, " ")
+			continue
+		}
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Slice {
+			texts := field.values
+			if separator != "" && len(texts) == 1 {
+				texts = strings.Split(texts[0], separator)
+			}
+			values := make([]any, len(texts))
+			for i, value := range texts {
+				values[i] = typedText(ft.Elem(), value)
+			}
+			obj[field.name] = values
+			continue
+		}
+		if len(field.values) != 1 {
+			obj[field.name] = field.values
+			continue
+		}
+		obj[field.name] = typedText(ft, field.values[0])
+	}
+	return json.Marshal(obj)
+}
+
+// typedText returns value as the JSON type matching t when it parses as one.
+func typedText(t reflect.Type, value string) any {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		value = strings.TrimSpace(value)
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+			return b
+		}
+	}
+	return value
+}
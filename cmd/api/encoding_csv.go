@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// csvListSeparator joins the items of a list in a single CSV cell.
+const csvListSeparator = ";"
+
+// encodeCSV writes a response holding a single list of objects, such as
+// {"movies": [...]}, with a header row naming the fields and a row per item.
+// Lists are joined with csvListSeparator and nested objects are written as
+// JSON. Any other response, an error for example, is not encodable.
+func encodeCSV(js []byte, pretty bool) ([]byte, error) {
+	v, err := orderedJSON(js)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := v.(jsonObject)
+	if !ok || len(obj) != 1 {
+		return nil, errNotEncodable
+	}
+	var items []any
+	switch list := obj[0].value.(type) {
+	case []any:
+		items = list
+	case nil:
+	default:
+		return nil, errNotEncodable
+	}
+
+	// fields left out of some items, like an empty external_id, still get a
+	// column.
+	var columns []string
+	index := make(map[string]int)
+	for _, item := range items {
+		row, ok := item.(jsonObject)
+		if !ok {
+			return nil, errNotEncodable
+		}
+		for _, m := range row {
+			if _, ok := index[m.name]; !ok {
+				index[m.name] = len(columns)
+				columns = append(columns, m.name)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if len(columns) > 0 {
+		w.Write(columns)
+	}
+	for _, item := range items {
+		record := make([]string, len(columns))
+		for _, m := range item.(jsonObject) {
+			record[index[m.name]], err = csvCell(m.value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func csvCell(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []any:
+		cells := make([]string, len(v))
+		for i, item := range v {
+			if _, ok := item.(jsonObject); ok {
+				return csvJSONCell(v)
+			}
+			cells[i] = fmt.Sprint(item)
+		}
+		return strings.Join(cells, csvListSeparator), nil
+	case jsonObject:
+		return csvJSONCell(v)
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func csvJSONCell(v any) (string, error) {
+	js, err := json.Marshal(toPlainJSON(v))
+	return string(js), err
+}
+
+// toPlainJSON turns a value returned by orderedJSON back into values
+// encoding/json marshals.
+func toPlainJSON(v any) any {
+	switch v := v.(type) {
+	case jsonObject:
+		m := make(map[string]any, len(v))
+		for _, member := range v {
+			m[member.name] = toPlainJSON(member.value)
+		}
+		return m
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = toPlainJSON(item)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+// decodeCSV converts a CSV body with a header row and a single record to
+// JSON for dst. Lists are split on csvListSeparator, and empty cells are left
+// out like missing JSON fields.
+func decodeCSV(body []byte, dst any) ([]byte, error) {
+	records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	if err != nil {
+		return nil, newRequestError("error.body_badly_formed", "CSV")
+	}
+	if len(records) != 2 {
+		return nil, newRequestError("error.body_single_csv_record")
+	}
+
+	var fields []textField
+	for i, name := range records[0] {
+		value := records[1][i]
+		if value == "" {
+			continue
+		}
+		fields = append(fields, textField{name: name, values: []string{value}})
+	}
+	return textToJSON(fields, dst, "CSV", csvListSeparator)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"github.com/vmihailenco/msgpack/v5"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   string
+		formats  []*format
+		expected *format
+	}{
+		{"no accept test", "", responseFormats, jsonFormat},
+		{"any test", "*/*", responseFormats, jsonFormat},
+		{"exact test", "application/xml", responseFormats, xmlFormat},
+		{"alias test", "application/x-yaml", responseFormats, yamlFormat},
+		{"quality test", "application/json;q=0.5, application/msgpack", responseFormats, msgpackFormat},
+		{"specific range wins test", "application/*;q=0.1, application/yaml;q=0.9", responseFormats, yamlFormat},
+		{"excluded test", "application/json;q=0, */*", responseFormats, xmlFormat},
+		{"browser test", "text/html,application/xhtml+xml,*/*;q=0.8", responseFormats, jsonFormat},
+		{"csv for lists test", "text/csv", listFormats, csvFormat},
+		{"csv for a movie test", "text/csv", responseFormats, nil},
+		{"not acceptable test", "text/html", responseFormats, nil},
+	}
+	for _, e := range tests {
+		f := negotiateFormat(e.accept, e.formats)
+		if f != e.expected {
+			t.Errorf("%s: expected %v but got %v", e.name, e.expected, f)
+		}
+	}
+}
+
+func TestResponseFormats(t *testing.T) {
+	tests := []struct {
+		name                string
+		url                 string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+		expectedResponse    string
+	}{
+		{"json test", "/v1/movies/1", "application/json", http.StatusOK, "application/json", "{\"movie\":{\"id\":1,\"title\":\"test\",\"runtime\":100,\"year\":2020,\"genres\":[\"action\",\"adventure\"]}}\n"},
+		{"xml test", "/v1/movies/1", "application/xml", http.StatusOK, "application/xml", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response><movie><id>1</id><title>test</title><runtime>100</runtime><year>2020</year><genres><genre>action</genre><genre>adventure</genre></genres></movie></response>\n"},
+		{"xml list test", "/v1/movies", "application/xml", http.StatusOK, "application/xml", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response><movies><movie><id>1</id><title>test movie 1</title><runtime>100</runtime><year>2020</year><genres><genre>action</genre></genres></movie><movie><id>2</id><title>test movie 2</title><runtime>100</runtime><year>2020</year><genres><genre>adventure</genre></genres></movie></movies></response>\n"},
+		{"yaml test", "/v1/movies/1", "application/yaml", http.StatusOK, "application/yaml", "movie:\n  id: 1\n  title: test\n  runtime: 100\n  year: 2020\n  genres:\n    - action\n    - adventure\n"},
+		{"csv test", "/v1/movies", "text/csv", http.StatusOK, "text/csv", "id,title,runtime,year,genres\n1,test movie 1,100,2020,action\n2,test movie 2,100,2020,adventure\n"},
+		{"xml error test", "/v1/movies/0", "application/xml", http.StatusNotFound, "application/xml", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response><error>the requested resource could not be found</error></response>\n"},
+		{"csv for a movie test", "/v1/movies/1", "text/csv", http.StatusNotAcceptable, "application/json", "{\"error\":\"the resource is only available as application/json, application/xml, application/yaml, application/msgpack\"}\n"},
+		{"not acceptable test", "/v1/movies", "text/html", http.StatusNotAcceptable, "application/json", "{\"error\":\"the resource is only available as application/json, application/xml, application/yaml, application/msgpack, text/csv\"}\n"},
+	}
+	routes := testApp.routes()
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", e.url, nil)
+		req.Header.Set("Accept", e.accept)
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+
+		if e.expectedContentType != rr.Header().Get("Content-Type") {
+			t.Errorf("%s: expected content type %s but got %s", e.name, e.expectedContentType, rr.Header().Get("Content-Type"))
+		}
+
+		if rr.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s: expected Vary: Accept but got %q", e.name, rr.Header().Get("Vary"))
+		}
+
+		if e.expectedResponse != rr.Body.String() {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
+		}
+	}
+}
+
+func TestMsgpackResponse(t *testing.T) {
+	req := httptest.NewRequest("GET", "/v1/movies/1", nil)
+	req.Header.Set("Accept", "application/msgpack")
+	rr := httptest.NewRecorder()
+	testApp.routes().ServeHTTP(rr, req)
+
+	if rr.Header().Get("Content-Type") != "application/msgpack" {
+		t.Fatalf("expected application/msgpack but got %s", rr.Header().Get("Content-Type"))
+	}
+	var body struct {
+		Movie data.Movie `json:"movie"`
+	}
+	dec := msgpack.NewDecoder(rr.Body)
+	dec.SetCustomStructTag("json")
+	err := dec.Decode(&body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := data.Movie{ID: 1, Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action", "adventure"}}
+	if !reflect.DeepEqual(expected, body.Movie) {
+		t.Errorf("expected %v but got %v", expected, body.Movie)
+	}
+}
+
+func TestRequestFormats(t *testing.T) {
+	packed, err := msgpack.Marshal(map[string]any{"title": "test", "runtime": 100, "year": 2020, "genres": []string{"action", "adventure"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		contentType      string
+		body             []byte
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{"curl form test", "application/x-www-form-urlencoded", []byte(`{"title":"test","runtime":100,"year":2020,"genres":["action","adventure"]}`), http.StatusCreated, ""},
+		{"no content type test", "", []byte(`{"title":"test","runtime":100,"year":2020,"genres":["action","adventure"]}`), http.StatusCreated, ""},
+		{"xml test", "application/xml", []byte(`<movie><title>test</title><runtime>100</runtime><year>2020</year><genres><genre>action</genre><genre>adventure</genre></genres></movie>`), http.StatusCreated, ""},
+		{"xml repeated list test", "text/xml; charset=utf-8", []byte(`<movie><title>test</title><runtime>100</runtime><year>2020</year><genres>action</genres><genres>adventure</genres></movie>`), http.StatusCreated, ""},
+		{"yaml test", "application/yaml", []byte("title: test\nruntime: 100\nyear: 2020\ngenres: [action, adventure]\n"), http.StatusCreated, ""},
+		{"csv test", "text/csv", []byte("title,runtime,year,genres\ntest,100,2020,action;adventure\n"), http.StatusCreated, ""},
+		{"msgpack test", "application/msgpack", packed, http.StatusCreated, ""},
+		{"xml wrong type test", "application/xml", []byte(`<movie><title>test</title><runtime>long</runtime></movie>`), http.StatusBadRequest, "{\"error\":\"body contains incorrect JSON type for field \\\"runtime\\\"\"}\n"},
+		{"xml unknown field test", "application/xml", []byte(`<movie><rating>5</rating></movie>`), http.StatusBadRequest, "{\"error\":\"body contains unknown key \\\"rating\\\"\"}\n"},
+		{"bad xml test", "application/xml", []byte(`<movie><title>test</movie>`), http.StatusBadRequest, "{\"error\":\"body contains badly-formed XML\"}\n"},
+		{"csv records test", "text/csv", []byte("title\ntest\nother\n"), http.StatusBadRequest, "{\"error\":\"body must contain a header row and a single CSV record\"}\n"},
+		{"empty yaml test", "application/yaml", nil, http.StatusBadRequest, "{\"error\":\"body must not be empty\"}\n"},
+		{"unsupported test", "text/plain", []byte("test"), http.StatusUnsupportedMediaType, "{\"error\":\"unsupported media type text/plain, use one of application/json, application/xml, application/yaml, application/msgpack, text/csv\"}\n"},
+	}
+	routes := testApp.routes()
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/v1/movies", bytes.NewReader(e.body))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d: %s", e.name, e.expectedStatus, rr.Code, rr.Body.String())
+		}
+
+		if e.expectedResponse != "" && e.expectedResponse != rr.Body.String() {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
+		}
+	}
+}
+
+func TestPatchRequestFormats(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		{"yaml merge patch test", "application/yaml", "genres: [drama]\n", http.StatusOK},
+		{"xml merge patch test", "application/xml", "<movie><genres>drama</genres></movie>", http.StatusUnsupportedMediaType},
+	}
+	routes := testApp.routes()
+	for _, e := range tests {
+		req := httptest.NewRequest("PATCH", "/v1/movies/1", strings.NewReader(e.body))
+		req.Header.Set("Content-Type", e.contentType)
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d: %s", e.name, e.expectedStatus, rr.Code, rr.Body.String())
+		}
+	}
+}
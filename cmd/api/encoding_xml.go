@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// xmlNameRX matches the names written as element names. Other names, such
+// as validation error keys, are written as an entry element with a key
+// attribute.
+var xmlNameRX = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// encodeXML writes the JSON representation of a response as XML, with a
+// response root element, an element per object member and an element per
+// array item named after the singular of the array, e.g.
+// <genres><genre>action</genre></genres>.
+func encodeXML(js []byte, pretty bool) ([]byte, error) {
+	v, err := orderedJSON(js)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	if pretty {
+		enc.Indent("", "\t")
+	}
+	err = encodeXMLElement(enc, "response", v)
+	if err != nil {
+		return nil, err
+	}
+	err = enc.Flush()
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func encodeXMLElement(enc *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !xmlNameRX.MatchString(name) || strings.HasPrefix(strings.ToLower(name), "xml") {
+		start = xml.StartElement{
+			Name: xml.Name{Local: "entry"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+		}
+	}
+	err := enc.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case jsonObject:
+		for _, m := range v {
+			err = encodeXMLElement(enc, m.name, m.value)
+			if err != nil {
+				return err
+			}
+		}
+	case []any:
+		item := singular(name)
+		for _, value := range v {
+			err = encodeXMLElement(enc, item, value)
+			if err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		err = enc.EncodeToken(xml.CharData(fmt.Sprint(v)))
+		if err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// irregularPlurals maps the array names whose singular is not found by
+// dropping the s.
+var irregularPlurals = map[string]string{
+	"deliveries": "delivery",
+}
+
+// singular names the items of an array called name.
+func singular(name string) string {
+	if item, ok := irregularPlurals[name]; ok {
+		return item
+	}
+	switch {
+	case strings.HasSuffix(name, "s") && len(name) > 1:
+		return strings.TrimSuffix(name, "s")
+	default:
+		return "item"
+	}
+}
+
+// decodeXML converts an XML body to JSON for dst. The children of the root
+// element are the fields of the body, and a list is either an element per
+// item or an element wrapping one element per item, as written by encodeXML.
+func decodeXML(body []byte, dst any) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+
+	var fields []textField
+	index := make(map[string]int)
+	depth := 0
+	root := false
+	var name string
+	var text strings.Builder
+	var items []string
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) && depth == 0 && root {
+				break
+			}
+			return nil, newRequestError("error.body_badly_formed", "XML")
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch depth {
+			case 1:
+				if root {
+					return nil, newRequestError("error.body_single_xml_element")
+				}
+				root = true
+			case 2:
+				name = t.Name.Local
+				text.Reset()
+				items = nil
+			case 3:
+				text.Reset()
+			case 4:
+				return nil, newRequestError("error.body_xml_too_deep", name)
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			switch depth {
+			case 3:
+				items = append(items, text.String())
+			case 2:
+				if items == nil {
+					items = []string{text.String()}
+				}
+				i, ok := index[name]
+				if !ok {
+					i = len(fields)
+					index[name] = i
+					fields = append(fields, textField{name: name})
+				}
+				fields[i].values = append(fields[i].values, items...)
+			}
+			depth--
+		}
+	}
+
+	return textToJSON(fields, dst, "XML", "")
+}
@@ -1,44 +1,204 @@
 package main
 
 import (
-	"fmt"
+	"errors"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"github.com/rrebeiz/quickmovies/internal/i18n"
+	"github.com/rrebeiz/quickmovies/internal/validator"
 	"net/http"
+	"strings"
 )
 
+const (
+	problemJSONType = "application/problem+json"
+	problemXMLType  = "application/problem+xml"
+
+	// problemTypePrefix turns an error code into the type URI of its problem
+	// details.
+	problemTypePrefix = "urn:quickmovies:problem:"
+)
+
+// problem holds RFC 7807 problem details. Its code is part of the API and
+// must not change, and its title is the title.<code> catalogue message.
+// Clients opt in to problem details by accepting application/problem+json;
+// everybody else gets the {"error": ...} envelope.
+type problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []fieldError `json:"errors,omitempty"`
+}
+
+// fieldError is a single failed validation in a problem.
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
 func (app *application) logError(r *http.Request, err error) {
-	fmt.Println(err, r.Method)
+	app.errorLog.Printf("%s %s: %s", r.Method, r.URL.Path, err)
+}
+
+// requestLanguage returns the catalogued language preferred by the client.
+func requestLanguage(r *http.Request) string {
+	return i18n.Match(r.Header.Get("Accept-Language"))
+}
+
+// localize returns the text of a catalogue message in the language of r.
+func localize(r *http.Request, key string, args ...any) string {
+	return i18n.Text(requestLanguage(r), key, args...)
 }
 
-func (app *application) errorResponse(w http.ResponseWriter, status int, r *http.Request, message any) {
-	env := envelope{"error": message}
-	err := app.writeJSON(w, status, env, nil)
+func (app *application) errorResponse(w http.ResponseWriter, status int, r *http.Request, code, message string) {
+	app.problemResponse(w, r, problem{Status: status, Code: code, Detail: message}, message)
+}
+
+// problemResponse writes p, or legacy as the error envelope for clients that
+// did not ask for problem details. Messages are expected in the language of
+// r, which is given as the Content-Language.
+func (app *application) problemResponse(w http.ResponseWriter, r *http.Request, p problem, legacy any) {
+	lang := requestLanguage(r)
+	w.Header().Add("Vary", "Accept-Language")
+	headers := make(http.Header)
+	headers.Set("Content-Language", lang)
+
+	var err error
+	if !acceptsProblem(r) {
+		err = app.writeResponse(w, r, p.Status, envelope{"error": legacy}, headers)
+	} else {
+		p.Type = problemTypePrefix + p.Code
+		p.Title = i18n.Text(lang, "title."+p.Code)
+		p.Instance = r.URL.Path
+
+		switch responseFormat(r) {
+		case xmlFormat:
+			headers.Set("Content-Type", problemXMLType)
+		case yamlFormat, msgpackFormat:
+		default:
+			headers.Set("Content-Type", problemJSONType)
+		}
+		err = app.writeResponse(w, r, p.Status, p, headers)
+	}
 	if err != nil {
 		app.logError(r, err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
+// acceptsProblem reports whether the client asked for problem details.
+func acceptsProblem(r *http.Request) bool {
+	for _, rng := range parseAccept(r.Header.Get("Accept")) {
+		if (rng.mediaType == problemJSONType || rng.mediaType == problemXMLType) && rng.quality > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
-	message := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, http.StatusInternalServerError, r, message)
+	message := localize(r, "error.internal")
+	app.errorResponse(w, http.StatusInternalServerError, r, "internal_error", message)
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
-	message := "the requested resource could not be found"
-	app.errorResponse(w, http.StatusNotFound, r, message)
+	message := localize(r, "error.not_found")
+	app.errorResponse(w, http.StatusNotFound, r, "not_found", message)
 }
 
-func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, http.StatusUnprocessableEntity, r, errors)
+func (app *application) movieNotFoundResponse(w http.ResponseWriter, r *http.Request) {
+	message := localize(r, "error.not_found")
+	app.errorResponse(w, http.StatusNotFound, r, "movie_not_found", message)
+}
+
+func (app *application) webhookNotFoundResponse(w http.ResponseWriter, r *http.Request) {
+	message := localize(r, "error.not_found")
+	app.errorResponse(w, http.StatusNotFound, r, "webhook_not_found", message)
+}
+
+func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
+	message := localize(r, "error.method_not_allowed", r.Method)
+	app.errorResponse(w, http.StatusMethodNotAllowed, r, "method_not_allowed", message)
+}
+
+func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator) {
+	lang := requestLanguage(r)
+	p := problem{
+		Status: http.StatusUnprocessableEntity,
+		Code:   "validation_failed",
+		Detail: localize(r, "error.validation_failed"),
+	}
+	for _, field := range v.Fields() {
+		for _, e := range v.Errors[field] {
+			p.Errors = append(p.Errors, fieldError{Field: field, Code: e.Code, Message: e.Localize(lang)})
+		}
+	}
+	// The error envelope has always held a single message per field.
+	app.problemResponse(w, r, p, v.FirstErrors(lang))
+}
+
+// checkViolationResponse reports a movie rejected by a check constraint of the
+// database like a failed validation of the field it checks.
+func (app *application) checkViolationResponse(w http.ResponseWriter, r *http.Request, err *data.CheckViolationError) {
+	app.logError(r, err)
+	app.failedValidationResponse(w, r, checkViolationValidator(err))
+}
+
+func checkViolationValidator(err *data.CheckViolationError) *validator.Validator {
+	v := validator.NewValidator()
+	v.AddMessage(err.Field, "constraint_violation", "validation.constraint_violation")
+	return v
 }
 
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
-	app.errorResponse(w, http.StatusBadRequest, r, err.Error())
+	// Errors that are not request errors, like a connection closed while the
+	// body was read, have no message in the catalogue.
+	message := localize(r, "error.bad_request")
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		message = reqErr.message.Text(requestLanguage(r))
+	}
+	// readRequest reports bodies in formats it cannot read like any other bad
+	// body.
+	if errors.Is(err, ErrUnsupportedMediaType) {
+		app.errorResponse(w, http.StatusUnsupportedMediaType, r, "unsupported_media_type", message)
+		return
+	}
+	app.errorResponse(w, http.StatusBadRequest, r, "bad_request", message)
+}
+
+func (app *application) notAcceptableResponse(w http.ResponseWriter, r *http.Request, formats []*format) {
+	mediaTypes := make([]string, len(formats))
+	for i, f := range formats {
+		mediaTypes[i] = f.contentType()
+	}
+	message := localize(r, "error.not_acceptable", strings.Join(mediaTypes, ", "))
+	app.errorResponse(w, http.StatusNotAcceptable, r, "not_acceptable", message)
+}
+
+func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	message := localize(r, "error.invalid_authentication_token")
+	app.errorResponse(w, http.StatusUnauthorized, r, "invalid_authentication_token", message)
+}
+
+func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	message := localize(r, "error.authentication_required")
+	app.errorResponse(w, http.StatusUnauthorized, r, "authentication_required", message)
+}
+
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := localize(r, "error.not_permitted")
+	app.errorResponse(w, http.StatusForbidden, r, "not_permitted", message)
 }
 
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
-	message := "unable to update the record due to an edit conflict, please try again"
-	app.errorResponse(w, http.StatusConflict, r, message)
+	message := localize(r, "error.edit_conflict")
+	app.errorResponse(w, http.StatusConflict, r, "edit_conflict", message)
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProblemResponses(t *testing.T) {
+	tests := []struct {
+		name                string
+		method              string
+		url                 string
+		accept              string
+		body                string
+		expectedStatus      int
+		expectedContentType string
+		expectedResponse    string
+	}{
+		{"movie not found test", "GET", "/v1/movies/0", "application/problem+json", "", http.StatusNotFound, "application/problem+json", "{\"type\":\"urn:quickmovies:problem:movie_not_found\",\"title\":\"Movie not found\",\"status\":404,\"detail\":\"the requested resource could not be found\",\"instance\":\"/v1/movies/0\",\"code\":\"movie_not_found\"}\n"},
+		{"legacy not found test", "GET", "/v1/movies/0", "application/json", "", http.StatusNotFound, "application/json", "{\"error\":\"the requested resource could not be found\"}\n"},
+		{"validation failed test", "POST", "/v1/movies", "application/json, application/problem+json", `{"title":"test","runtime":-1,"genres":["action","action"]}`, http.StatusUnprocessableEntity, "application/problem+json", "{\"type\":\"urn:quickmovies:problem:validation_failed\",\"title\":\"Validation failed\",\"status\":422,\"detail\":\"the request failed validation\",\"instance\":\"/v1/movies\",\"code\":\"validation_failed\",\"errors\":[{\"field\":\"genres\",\"code\":\"duplicate\",\"message\":\"must not contain duplicate genres\"},{\"field\":\"runtime\",\"code\":\"not_positive\",\"message\":\"should be a positive number\"},{\"field\":\"year\",\"code\":\"required\",\"message\":\"should not be empty\"}]}\n"},
+		{"several errors per field test", "POST", "/v1/movies", "application/problem+json", `{"title":"test","runtime":100,"year":2020,"genres":["action","action","comedy","drama","horror","western"]}`, http.StatusUnprocessableEntity, "application/problem+json", "{\"type\":\"urn:quickmovies:problem:validation_failed\",\"title\":\"Validation failed\",\"status\":422,\"detail\":\"the request failed validation\",\"instance\":\"/v1/movies\",\"code\":\"validation_failed\",\"errors\":[{\"field\":\"genres\",\"code\":\"too_many\",\"message\":\"should not contain more than 5 genres\"},{\"field\":\"genres\",\"code\":\"duplicate\",\"message\":\"must not contain duplicate genres\"},{\"field\":\"genres[5]\",\"code\":\"not_permitted\",\"message\":\"please use the following permitted genres [action adventure comedy horror drama]\"}]}\n"},
+		{"legacy validation failed test", "POST", "/v1/movies", "", `{"title":"test","runtime":-1,"genres":["action","action"]}`, http.StatusUnprocessableEntity, "application/json", "{\"error\":{\"genres\":\"must not contain duplicate genres\",\"runtime\":\"should be a positive number\",\"year\":\"should not be empty\"}}\n"},
+		{"bad request test", "POST", "/v1/movies", "application/problem+json", `{"rating":5}`, http.StatusBadRequest, "application/problem+json", "{\"type\":\"urn:quickmovies:problem:bad_request\",\"title\":\"Bad request\",\"status\":400,\"detail\":\"body contains unknown key \\\"rating\\\"\",\"instance\":\"/v1/movies\",\"code\":\"bad_request\"}\n"},
+		{"route not found test", "GET", "/v1/unknown", "application/problem+json", "", http.StatusNotFound, "application/problem+json", "{\"type\":\"urn:quickmovies:problem:not_found\",\"title\":\"Resource not found\",\"status\":404,\"detail\":\"the requested resource could not be found\",\"instance\":\"/v1/unknown\",\"code\":\"not_found\"}\n"},
+		{"method not allowed test", "DELETE", "/v1/movies", "", "", http.StatusMethodNotAllowed, "application/json", "{\"error\":\"the DELETE method is not supported for this resource\"}\n"},
+		{"edit conflict test", "PATCH", "/v1/movies/1", "application/problem+json", `[{"op":"test","path":"/title","value":"other"}]`, http.StatusConflict, "application/problem+json", "{\"type\":\"urn:quickmovies:problem:patch_test_failed\",\"title\":\"Patch test failed\",\"status\":409,\"detail\":\"the movie does not match the test operation of the patch\",\"instance\":\"/v1/movies/1\",\"code\":\"patch_test_failed\"}\n"},
+		{"xml problem test", "GET", "/v1/movies/0", "application/problem+xml", "", http.StatusNotFound, "application/problem+xml", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response><type>urn:quickmovies:problem:movie_not_found</type><title>Movie not found</title><status>404</status><detail>the requested resource could not be found</detail><instance>/v1/movies/0</instance><code>movie_not_found</code></response>\n"},
+	}
+	routes := testApp.routes()
+	for _, e := range tests {
+		req := httptest.NewRequest(e.method, e.url, strings.NewReader(e.body))
+		if e.accept != "" {
+			req.Header.Set("Accept", e.accept)
+		}
+		if e.method == "PATCH" {
+			req.Header.Set("Content-Type", jsonPatchType)
+		}
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+
+		if e.expectedContentType != rr.Header().Get("Content-Type") {
+			t.Errorf("%s: expected content type %s but got %s", e.name, e.expectedContentType, rr.Header().Get("Content-Type"))
+		}
+
+		if e.expectedResponse != rr.Body.String() {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
+		}
+	}
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		url              string
+		acceptLanguage   string
+		accept           string
+		body             string
+		expectedStatus   int
+		expectedLanguage string
+		expectedResponse string
+	}{
+		{"french validation test", "POST", "/v1/movies", "fr-FR,fr;q=0.9,en;q=0.8", "", `{"title":"test","runtime":-1,"year":2020,"genres":["action"]}`, http.StatusUnprocessableEntity, "fr", "{\"error\":{\"runtime\":\"doit être un nombre positif\"}}\n"},
+		{"french parameter test", "POST", "/v1/movies", "fr", "", `{"title":"` + strings.Repeat("x", 501) + `","runtime":100,"year":2020,"genres":["action"]}`, http.StatusUnprocessableEntity, "fr", "{\"error\":{\"title\":\"ne doit pas dépasser 500 octets\"}}\n"},
+		{"arabic problem test", "GET", "/v1/movies/0", "ar-LB", "application/problem+json", "", http.StatusNotFound, "ar", "{\"type\":\"urn:quickmovies:problem:movie_not_found\",\"title\":\"الفيلم غير موجود\",\"status\":404,\"detail\":\"تعذر العثور على المورد المطلوب\",\"instance\":\"/v1/movies/0\",\"code\":\"movie_not_found\"}\n"},
+		{"french method test", "DELETE", "/v1/movies", "fr", "", "", http.StatusMethodNotAllowed, "fr", "{\"error\":\"la méthode DELETE n'est pas prise en charge pour cette ressource\"}\n"},
+		{"french body test", "POST", "/v1/movies", "fr", "", "", http.StatusBadRequest, "fr", "{\"error\":\"le corps ne doit pas être vide\"}\n"},
+		{"arabic unknown key test", "POST", "/v1/movies", "ar", "", `{"rating":5}`, http.StatusBadRequest, "ar", "{\"error\":\"يحتوي المحتوى على مفتاح غير معروف \\\"rating\\\"\"}\n"},
+		{"french patch test", "PATCH", "/v1/movies/1", "fr", "", `{"id":5}`, http.StatusUnprocessableEntity, "fr", "{\"error\":\"l'id du film ne peut pas être modifié\"}\n"},
+		{"fallback test", "GET", "/v1/movies/0", "de-DE", "", "", http.StatusNotFound, "en", "{\"error\":\"the requested resource could not be found\"}\n"},
+	}
+	routes := testApp.routes()
+	for _, e := range tests {
+		req := httptest.NewRequest(e.method, e.url, strings.NewReader(e.body))
+		req.Header.Set("Accept-Language", e.acceptLanguage)
+		if e.accept != "" {
+			req.Header.Set("Accept", e.accept)
+		}
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+
+		if e.expectedLanguage != rr.Header().Get("Content-Language") {
+			t.Errorf("%s: expected language %s but got %s", e.name, e.expectedLanguage, rr.Header().Get("Content-Language"))
+		}
+
+		if e.expectedResponse != rr.Body.String() {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
+		}
+	}
+}
+
+func TestBadRequestResponseFallback(t *testing.T) {
+	req := httptest.NewRequest("POST", "/v1/movies", nil)
+	req.Header.Set("Accept-Language", "fr")
+	rr := httptest.NewRecorder()
+	testApp.badRequestResponse(rr, req, errors.New("read tcp: connection reset by peer"))
+
+	expected := "{\"error\":\"la requête n'a pas pu être lue\"}\n"
+	if rr.Code != http.StatusBadRequest || rr.Body.String() != expected {
+		t.Errorf("expected %d %s but got %d %s", http.StatusBadRequest, expected, rr.Code, rr.Body.String())
+	}
+}
+
+func TestLogError(t *testing.T) {
+	var buf bytes.Buffer
+	app := testApp
+	app.errorLog = log.New(&buf, "", 0)
+	app.logError(httptest.NewRequest("GET", "/v1/movies/1", nil), errors.New("connection refused"))
+
+	expected := "GET /v1/movies/1: connection refused\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q but got %q", expected, buf.String())
+	}
+}
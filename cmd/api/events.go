@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// sseHeartbeatInterval keeps idle connections from being closed by
+	// proxies, and lets the server notice clients that went away.
+	sseHeartbeatInterval = 15 * time.Second
+	// sseWriteTimeout bounds each write to the stream. It replaces the
+	// server-wide WriteTimeout, which would otherwise end every stream after
+	// 15 seconds.
+	sseWriteTimeout = 10 * time.Second
+)
+
+// movieEventsHandler streams movie changes as server-sent events, using the
+// outbox IDs of the events as SSE IDs. Clients that reconnect with a
+// Last-Event-ID header, to this or any other instance, are sent the events
+// they missed from the outbox, or a reset event if they should reload their
+// data instead.
+func (app *application) movieEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var lastID int64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || id < 0 {
+			app.badRequestResponse(w, r, newRequestError("error.invalid_last_event_id", header))
+			return
+		}
+		lastID = id
+	}
+
+	// subscribe before reading the outbox, so no event is published in
+	// between unseen. Events that are both replayed and published are only
+	// sent once.
+	sub := app.feed.Subscribe()
+	defer app.feed.Unsubscribe(sub)
+
+	var replay []data.MovieEvent
+	reset := false
+	if lastID > 0 {
+		var err error
+		replay, reset, err = app.missedEvents(r.Context(), lastID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(format string, args ...any) bool {
+		err := rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return false
+		}
+		_, err = fmt.Fprintf(w, format, args...)
+		if err == nil {
+			err = rc.Flush()
+		}
+		return err == nil
+	}
+	send := func(event data.MovieEvent) bool {
+		js, err := json.Marshal(event)
+		if err != nil {
+			app.logError(r, err)
+			return false
+		}
+		return write("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, js)
+	}
+
+	if !write("retry: 5000\n\n") {
+		return
+	}
+	if reset && !write("event: reset\ndata: {}\n\n") {
+		return
+	}
+	replayed := make(map[int64]bool, len(replay))
+	for _, event := range replay {
+		if !send(event) {
+			return
+		}
+		replayed[event.ID] = true
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-app.shutdown:
+			// Shutdown waits for handlers to return, so streams have to end
+			// on their own.
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// the client fell behind, it resumes from Last-Event-ID when
+				// it reconnects.
+				return
+			}
+			if replayed[event.ID] {
+				continue
+			}
+			if !send(event) {
+				return
+			}
+		case <-heartbeat.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		}
+	}
+}
+
+// missedEvents returns the events recorded after lastID. reset is true when
+// the client should reload its data instead, because it missed more than
+// -events-replay events, the events after lastID are no longer kept, or lastID
+// is newer than any event, as happens after the memory storage restarts.
+func (app *application) missedEvents(ctx context.Context, lastID int64) (events []data.MovieEvent, reset bool, err error) {
+	oldest, err := app.models.Events.FirstEventID(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	newest, err := app.models.Events.LastEventID(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if lastID > newest || lastID < oldest-1 {
+		return nil, true, nil
+	}
+	events, err = app.models.Events.GetEventsAfter(ctx, lastID, app.config.events.replay+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(events) > app.config.events.replay {
+		return nil, true, nil
+	}
+	return events, false, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"github.com/rrebeiz/quickmovies/internal/feed"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvent reads lines from the stream up to the next blank line.
+func readEvent(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read the stream %s", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return strings.Join(lines, "\n")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestMovieEventsHandler(t *testing.T) {
+	app := testApp
+	app.feed = feed.NewBroker()
+	app.shutdown = make(chan struct{})
+	app.config.events.replay = 1
+	store := data.NewMemoryMovieModel()
+	_, err := store.UpsertMovie(context.Background(), &data.Movie{ID: 1, Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action", "adventure"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.models = data.NewMemoryModels(store)
+	app.models.Movies, err = data.NewNotifyingMovieModel(context.Background(), store, app.models.Events, app.feed.Publish)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sseHeartbeatInterval = 50 * time.Millisecond
+	defer func() { sseHeartbeatInterval = 15 * time.Second }()
+
+	srv := httptest.NewServer(app.routes())
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/v1/movies/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("expected text/event-stream but got %s", res.Header.Get("Content-Type"))
+	}
+	stream := bufio.NewReader(res.Body)
+	if e := readEvent(t, stream); e != "retry: 5000" {
+		t.Errorf("expected the retry interval but got %q", e)
+	}
+
+	body := strings.NewReader(`{"title":"test","runtime":100,"year":2020,"genres":["action"]}`)
+	_, err = http.Post(srv.URL+"/v1/movies", "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("DELETE", srv.URL+"/v1/movies/1", nil)
+	_, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"id: 2\nevent: movie.created\ndata: {\"type\":\"movie.created\",\"movie\":{\"id\":2,\"title\":\"test\",\"runtime\":100,\"year\":2020,\"genres\":[\"action\"]},\"version\":1,",
+		"id: 3\nevent: movie.deleted\ndata: {\"type\":\"movie.deleted\",\"movie\":{\"id\":1,\"title\":\"test\",\"runtime\":100,\"year\":2020,\"genres\":[\"action\",\"adventure\"]},\"version\":1,",
+		": heartbeat",
+	}
+	for _, e := range expected {
+		actual := readEvent(t, stream)
+		if !strings.HasPrefix(actual, e) {
+			t.Errorf("expected %q but got %q", e, actual)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		expected    string
+	}{
+		{"resume test", "2", "id: 3\nevent: movie.deleted"},
+		{"too many missed test", "1", "event: reset\ndata: {}"},
+		{"unknown id test", "99", "event: reset\ndata: {}"},
+	}
+	for _, e := range tests {
+		req, _ := http.NewRequest("GET", srv.URL+"/v1/movies/events", nil)
+		req.Header.Set("Last-Event-ID", e.lastEventID)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream := bufio.NewReader(res.Body)
+		readEvent(t, stream)
+		if actual := readEvent(t, stream); !strings.HasPrefix(actual, e.expected) {
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, actual)
+		}
+		res.Body.Close()
+	}
+}
+
+// keptEvents is a data.Events holding events first to last, one per ID.
+type keptEvents struct {
+	first, last int64
+}
+
+func (e keptEvents) GetEventsAfter(ctx context.Context, afterID int64, limit int) ([]data.MovieEvent, error) {
+	events := []data.MovieEvent{}
+	for id := afterID + 1; id <= e.last && len(events) < limit; id++ {
+		if id >= e.first {
+			events = append(events, data.MovieEvent{ID: id, Type: data.EventMovieUpdated})
+		}
+	}
+	return events, nil
+}
+
+func (e keptEvents) FirstEventID(ctx context.Context) (int64, error) {
+	return e.first, nil
+}
+
+func (e keptEvents) LastEventID(ctx context.Context) (int64, error) {
+	return e.last, nil
+}
+
+func TestMissedEvents(t *testing.T) {
+	app := testApp
+	app.config.events.replay = 3
+	app.models = newTestModels()
+	app.models.Events = keptEvents{first: 10, last: 12}
+
+	tests := []struct {
+		name          string
+		lastID        int64
+		expectedReset bool
+		expected      int
+	}{
+		{"up to date test", 12, false, 0},
+		{"resume test", 10, false, 2},
+		{"oldest kept test", 9, false, 3},
+		{"removed test", 8, true, 0},
+		{"unknown id test", 13, true, 0},
+	}
+	for _, e := range tests {
+		events, reset, err := app.missedEvents(context.Background(), e.lastID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reset != e.expectedReset || len(events) != e.expected {
+			t.Errorf("%s: expected reset %t and %d events but got %t and %d", e.name, e.expectedReset, e.expected, reset, len(events))
+		}
+	}
+}
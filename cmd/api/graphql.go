@@ -0,0 +1,593 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"github.com/rrebeiz/quickmovies/internal/i18n"
+	"github.com/rrebeiz/quickmovies/internal/validator"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxQueryDepth and maxQueryComplexity bound the size of a GraphQL query
+	// before it is executed. Complexity is the number of selected fields,
+	// with movies counting for more.
+	maxQueryDepth      = 5
+	maxQueryComplexity = 100
+	// moviesComplexity is what a movies field counts for, since each one
+	// loads the whole catalogue (see resolveMovies).
+	moviesComplexity = 20
+
+	// maxIntrospectionDepth and maxIntrospectionComplexity bound the fields
+	// selected under __schema and __type instead, leaving room for the
+	// introspection query of GraphiQL.
+	maxIntrospectionDepth      = 20
+	maxIntrospectionComplexity = 500
+
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+//go:embed graphql/graphiql.html
+var graphiQLFiles embed.FS
+
+// languageContextKey holds the language of the GraphQL request, for the
+// resolvers reporting validation errors.
+const languageContextKey = contextKey("language")
+
+// graphQLError is a GraphQL error carrying a machine readable code, and the
+// messages of every failed field for failed validations, in its extensions.
+type graphQLError struct {
+	message string
+	code    string
+	fields  map[string][]string
+}
+
+func (e graphQLError) Error() string {
+	return e.message
+}
+
+func (e graphQLError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.code}
+	if e.fields != nil {
+		extensions["fields"] = e.fields
+	}
+	return extensions
+}
+
+var errGraphQLNotFound = graphQLError{message: "the requested resource could not be found", code: "NOT_FOUND"}
+
+// errIntrospectionDisabled is returned for queries selecting __schema or
+// __type outside the develop environment.
+var errIntrospectionDisabled = errors.New("introspection is disabled")
+
+func (app *application) graphQLHandler(schema graphql.Schema) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Query         string         `json:"query"`
+			OperationName string         `json:"operationName"`
+			Variables     map[string]any `json:"variables"`
+			// extensions are sent by clients like Apollo for features such
+			// as persisted queries, which are not supported, so they are
+			// accepted and ignored.
+			Extensions json.RawMessage `json:"extensions"`
+		}
+
+		err := app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		err = checkQueryLimits(input.Query, app.config.env == "develop")
+		if err != nil {
+			code := "QUERY_TOO_COMPLEX"
+			if errors.Is(err, errIntrospectionDisabled) {
+				code = "INTROSPECTION_DISABLED"
+			}
+			env := envelope{"errors": []envelope{{"message": err.Error(), "extensions": envelope{"code": code}}}}
+			err = app.writeJSON(w, http.StatusBadRequest, env, nil)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  input.Query,
+			VariableValues: input.Variables,
+			OperationName:  input.OperationName,
+			Context:        context.WithValue(r.Context(), languageContextKey, requestLanguage(r)),
+		})
+
+		w.Header().Add("Vary", "Accept-Language")
+		env := envelope{"data": result.Data}
+		if len(result.Errors) > 0 {
+			env["errors"] = result.Errors
+		}
+		err = app.writeJSON(w, http.StatusOK, env, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+}
+
+// graphiQLHandler serves the GraphiQL IDE in the develop environment only.
+func (app *application) graphiQLHandler(w http.ResponseWriter, r *http.Request) {
+	if app.config.env != "develop" {
+		app.notFoundResponse(w, r)
+		return
+	}
+	content, err := graphiQLFiles.ReadFile("graphql/graphiql.html")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
+
+func (app *application) newGraphQLSchema() (graphql.Schema, error) {
+	genreEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name: "Genre",
+		Values: graphql.EnumValueConfigMap{
+			"action":    {Value: "action"},
+			"adventure": {Value: "adventure"},
+			"comedy":    {Value: "comedy"},
+			"horror":    {Value: "horror"},
+			"drama":     {Value: "drama"},
+		},
+	})
+
+	movieType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Movie",
+		Fields: graphql.Fields{
+			"id":      {Type: graphql.NewNonNull(graphql.Int)},
+			"title":   {Type: graphql.NewNonNull(graphql.String)},
+			"runtime": {Type: graphql.NewNonNull(graphql.Int)},
+			"year":    {Type: graphql.NewNonNull(graphql.Int)},
+			"genres":  {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(genreEnum)))},
+		},
+	})
+
+	filterInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "MovieFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":  {Type: graphql.String, Description: "case insensitive substring of the title"},
+			"year":   {Type: graphql.Int},
+			"genres": {Type: graphql.NewList(graphql.NewNonNull(genreEnum)), Description: "movies having all of these genres"},
+		},
+	})
+
+	pageInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "Page",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"number": {Type: graphql.Int, DefaultValue: 1},
+			"size":   {Type: graphql.Int, DefaultValue: defaultPageSize},
+		},
+	})
+
+	movieInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "MovieInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":   {Type: graphql.NewNonNull(graphql.String)},
+			"runtime": {Type: graphql.NewNonNull(graphql.Int)},
+			"year":    {Type: graphql.NewNonNull(graphql.Int)},
+			"genres":  {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(genreEnum)))},
+		},
+	})
+
+	movieUpdateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "MovieUpdateInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":   {Type: graphql.String},
+			"runtime": {Type: graphql.Int},
+			"year":    {Type: graphql.Int},
+			"genres":  {Type: graphql.NewList(graphql.NewNonNull(genreEnum))},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"movie": {
+				Type: movieType,
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: app.resolveMovie,
+			},
+			"movies": {
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(movieType))),
+				Description: "Loads the whole catalogue before filtering, sorting and paging it",
+				Args: graphql.FieldConfigArgument{
+					"filter": {Type: filterInput},
+					"sort":   {Type: graphql.String, DefaultValue: "id", Description: "id, title, year or runtime, prefixed with - for descending order"},
+					"page":   {Type: pageInput},
+				},
+				Resolve: app.resolveMovies,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createMovie": {
+				Type: graphql.NewNonNull(movieType),
+				Args: graphql.FieldConfigArgument{
+					"input": {Type: graphql.NewNonNull(movieInput)},
+				},
+				Resolve: app.resolveCreateMovie,
+			},
+			"updateMovie": {
+				Type: graphql.NewNonNull(movieType),
+				Args: graphql.FieldConfigArgument{
+					"id":    {Type: graphql.NewNonNull(graphql.Int)},
+					"input": {Type: graphql.NewNonNull(movieUpdateInput)},
+				},
+				Resolve: app.resolveUpdateMovie,
+			},
+			"deleteMovie": {
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: app.resolveDeleteMovie,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+}
+
+func (app *application) resolveMovie(p graphql.ResolveParams) (interface{}, error) {
+	id := int64(p.Args["id"].(int))
+	movie, err := app.models.Movies.GetMovie(p.Context, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			return nil, nil
+		default:
+			return nil, app.graphQLServerError(err)
+		}
+	}
+	return movie, nil
+}
+
+// resolveMovies filters, sorts and pages the whole catalogue in memory, like
+// the REST list, so every query loads all movies from storage. That is fine
+// for a catalogue in the thousands, and moviesComplexity keeps a query from
+// asking for it more than a few times.
+func (app *application) resolveMovies(p graphql.ResolveParams) (interface{}, error) {
+	filter, _ := p.Args["filter"].(map[string]interface{})
+	sortBy, _ := p.Args["sort"].(string)
+	page := 1
+	pageSize := defaultPageSize
+	if input, ok := p.Args["page"].(map[string]interface{}); ok {
+		page, _ = input["number"].(int)
+		pageSize, _ = input["size"].(int)
+	}
+
+	sortSafelist := []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	v := validator.NewValidator()
+	v.CheckMessage(validator.PermittedValue(sortBy, sortSafelist...), "sort", "not_permitted", "validation.not_permitted", sortSafelist)
+	v.CheckMessage(page > 0, "page", "not_positive", "validation.not_positive")
+	v.CheckMessage(pageSize > 0 && pageSize <= maxPageSize, "size", "out_of_range", "validation.out_of_range", 1, maxPageSize)
+	if !v.Valid() {
+		return nil, graphQLValidationError(p.Context, v)
+	}
+
+	movies, err := app.models.Movies.GetAllMovies(p.Context)
+	if err != nil {
+		return nil, app.graphQLServerError(err)
+	}
+
+	movies = filterMovies(movies, filter)
+	sortMovies(movies, sortBy)
+
+	start := (page - 1) * pageSize
+	if start >= len(movies) {
+		return []*data.Movie{}, nil
+	}
+	end := start + pageSize
+	if end > len(movies) {
+		end = len(movies)
+	}
+	return movies[start:end], nil
+}
+
+func (app *application) resolveCreateMovie(p graphql.ResolveParams) (interface{}, error) {
+	input := p.Args["input"].(map[string]interface{})
+	movie := &data.Movie{
+		Title:   input["title"].(string),
+		Runtime: int32(input["runtime"].(int)),
+		Year:    int32(input["year"].(int)),
+		Genres:  stringSlice(input["genres"]),
+	}
+
+	v := validator.NewValidator()
+	data.ValidateMovie(v, movie)
+	if !v.Valid() {
+		return nil, graphQLValidationError(p.Context, v)
+	}
+
+	err := app.models.Movies.CreateMovie(p.Context, movie)
+	if err != nil {
+		var checkErr *data.CheckViolationError
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, graphQLError{message: "unable to update the record due to an edit conflict, please try again", code: "EDIT_CONFLICT"}
+		case errors.As(err, &checkErr):
+			return nil, graphQLValidationError(p.Context, checkViolationValidator(checkErr))
+		default:
+			return nil, app.graphQLServerError(err)
+		}
+	}
+	return movie, nil
+}
+
+func (app *application) resolveUpdateMovie(p graphql.ResolveParams) (interface{}, error) {
+	id := int64(p.Args["id"].(int))
+	input := p.Args["input"].(map[string]interface{})
+
+	movie, err := app.models.Movies.GetMovie(p.Context, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			return nil, errGraphQLNotFound
+		default:
+			return nil, app.graphQLServerError(err)
+		}
+	}
+
+	if title, ok := input["title"].(string); ok {
+		movie.Title = title
+	}
+	if runtime, ok := input["runtime"].(int); ok {
+		movie.Runtime = int32(runtime)
+	}
+	if year, ok := input["year"].(int); ok {
+		movie.Year = int32(year)
+	}
+	if genres, ok := input["genres"]; ok && genres != nil {
+		movie.Genres = stringSlice(genres)
+	}
+
+	v := validator.NewValidator()
+	data.ValidateMovie(v, movie)
+	if !v.Valid() {
+		return nil, graphQLValidationError(p.Context, v)
+	}
+
+	err = app.models.Movies.UpdateMovie(p.Context, movie)
+	if err != nil {
+		var checkErr *data.CheckViolationError
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, graphQLError{message: "unable to update the record due to an edit conflict, please try again", code: "EDIT_CONFLICT"}
+		case errors.As(err, &checkErr):
+			return nil, graphQLValidationError(p.Context, checkViolationValidator(checkErr))
+		default:
+			return nil, app.graphQLServerError(err)
+		}
+	}
+	return movie, nil
+}
+
+func (app *application) resolveDeleteMovie(p graphql.ResolveParams) (interface{}, error) {
+	id := int64(p.Args["id"].(int))
+	err := app.models.Movies.DeleteMovie(p.Context, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			return nil, errGraphQLNotFound
+		default:
+			return nil, app.graphQLServerError(err)
+		}
+	}
+	return true, nil
+}
+
+func (app *application) graphQLServerError(err error) error {
+	app.errorLog.Println(err)
+	return graphQLError{message: "the server encountered a problem and could not process your request", code: "INTERNAL"}
+}
+
+// graphQLValidationError reports every failed check of v in the language of
+// the request.
+func graphQLValidationError(ctx context.Context, v *validator.Validator) error {
+	lang, ok := ctx.Value(languageContextKey).(string)
+	if !ok {
+		lang = i18n.DefaultLanguage
+	}
+	fields := make(map[string][]string, len(v.Errors))
+	for field, errs := range v.Errors {
+		for _, e := range errs {
+			fields[field] = append(fields[field], e.Localize(lang))
+		}
+	}
+	return graphQLError{message: "failed validation", code: "VALIDATION_FAILED", fields: fields}
+}
+
+func stringSlice(value interface{}) []string {
+	items, _ := value.([]interface{})
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		values = append(values, item.(string))
+	}
+	return values
+}
+
+func filterMovies(movies []*data.Movie, filter map[string]interface{}) []*data.Movie {
+	if filter == nil {
+		return movies
+	}
+	title, _ := filter["title"].(string)
+	year, hasYear := filter["year"].(int)
+	genres := stringSlice(filter["genres"])
+
+	filtered := make([]*data.Movie, 0, len(movies))
+	for _, movie := range movies {
+		if title != "" && !strings.Contains(strings.ToLower(movie.Title), strings.ToLower(title)) {
+			continue
+		}
+		if hasYear && movie.Year != int32(year) {
+			continue
+		}
+		if !hasGenres(movie, genres) {
+			continue
+		}
+		filtered = append(filtered, movie)
+	}
+	return filtered
+}
+
+func hasGenres(movie *data.Movie, genres []string) bool {
+	for _, genre := range genres {
+		if !validator.PermittedValue(genre, movie.Genres...) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortMovies(movies []*data.Movie, sortBy string) {
+	desc := strings.HasPrefix(sortBy, "-")
+	key := strings.TrimPrefix(sortBy, "-")
+
+	less := func(a, b *data.Movie) bool {
+		switch key {
+		case "title":
+			if a.Title != b.Title {
+				return a.Title < b.Title
+			}
+		case "year":
+			if a.Year != b.Year {
+				return a.Year < b.Year
+			}
+		case "runtime":
+			if a.Runtime != b.Runtime {
+				return a.Runtime < b.Runtime
+			}
+		}
+		return a.ID < b.ID
+	}
+
+	sort.SliceStable(movies, func(i, j int) bool {
+		if desc {
+			return less(movies[j], movies[i])
+		}
+		return less(movies[i], movies[j])
+	})
+}
+
+// checkQueryLimits rejects queries that are nested deeper than maxQueryDepth
+// or select more than maxQueryComplexity fields, not counting the fields under
+// __schema and __type, which have their own limits. Introspection is only
+// allowed when introspection is true. Queries that do not parse are left for
+// graphql.Do to report.
+func checkQueryLimits(query string, introspection bool) error {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return nil
+	}
+
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok && fragment.Name != nil {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	for _, def := range doc.Definitions {
+		operation, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		cost, introspectionCost := measureSelections(operation.SelectionSet, fragments, map[string]bool{})
+		if introspectionCost.complexity > 0 && !introspection {
+			return errIntrospectionDisabled
+		}
+		if cost.depth > maxQueryDepth {
+			return fmt.Errorf("query depth %d exceeds the maximum of %d", cost.depth, maxQueryDepth)
+		}
+		if cost.complexity > maxQueryComplexity {
+			return fmt.Errorf("query complexity %d exceeds the maximum of %d", cost.complexity, maxQueryComplexity)
+		}
+		if introspectionCost.depth > maxIntrospectionDepth {
+			return fmt.Errorf("introspection depth %d exceeds the maximum of %d", introspectionCost.depth, maxIntrospectionDepth)
+		}
+		if introspectionCost.complexity > maxIntrospectionComplexity {
+			return fmt.Errorf("introspection complexity %d exceeds the maximum of %d", introspectionCost.complexity, maxIntrospectionComplexity)
+		}
+	}
+	return nil
+}
+
+// queryCost is the depth and complexity of a selection set.
+type queryCost struct {
+	depth      int
+	complexity int
+}
+
+func (c *queryCost) add(other queryCost) {
+	if other.depth > c.depth {
+		c.depth = other.depth
+	}
+	c.complexity += other.complexity
+}
+
+// measureSelections returns the cost of set, and separately the cost of the
+// __schema and __type fields in it including everything selected under them.
+// __typename is counted like any other field.
+func measureSelections(set *ast.SelectionSet, fragments map[string]*ast.FragmentDefinition, visiting map[string]bool) (cost, introspection queryCost) {
+	if set == nil {
+		return cost, introspection
+	}
+	for _, selection := range set.Selections {
+		var c, i queryCost
+		switch selection := selection.(type) {
+		case *ast.Field:
+			c, i = measureSelections(selection.SelectionSet, fragments, visiting)
+			if name := selection.Name; name != nil && (name.Value == "__schema" || name.Value == "__type") {
+				i.add(c)
+				c = queryCost{}
+				i = queryCost{depth: i.depth + 1, complexity: i.complexity + 1}
+			} else if name != nil && name.Value == "movies" {
+				c = queryCost{depth: c.depth + 1, complexity: c.complexity + moviesComplexity}
+			} else {
+				c = queryCost{depth: c.depth + 1, complexity: c.complexity + 1}
+			}
+		case *ast.InlineFragment:
+			c, i = measureSelections(selection.SelectionSet, fragments, visiting)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := fragments[name]
+			if !ok || visiting[name] {
+				continue
+			}
+			visiting[name] = true
+			c, i = measureSelections(fragment.SelectionSet, fragments, visiting)
+			delete(visiting, name)
+		}
+		cost.add(c)
+		introspection.add(i)
+	}
+	return cost, introspection
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"github.com/graphql-go/graphql/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGraphQLHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{"movie query test", `{"query":"{ movie(id: 1) { id title genres } }"}`, http.StatusOK, "{\"data\":{\"movie\":{\"genres\":[\"action\",\"adventure\"],\"id\":1,\"title\":\"test\"}}}\n"},
+		{"movie not found test", `{"query":"{ movie(id: 0) { id } }"}`, http.StatusOK, "{\"data\":{\"movie\":null}}\n"},
+		{"movies filter and sort test", `{"query":"query($g: [Genre!]) { movies(filter: {genres: $g}, sort: \"-id\") { id } }","variables":{"g":["adventure"]}}`, http.StatusOK, "{\"data\":{\"movies\":[{\"id\":2}]}}\n"},
+		{"invalid sort test", `{"query":"{ movies(sort: \"banana\") { id } }"}`, http.StatusOK, "{\"data\":null,\"errors\":[{\"message\":\"failed validation\",\"locations\":[{\"line\":1,\"column\":3}],\"path\":[\"movies\"],\"extensions\":{\"code\":\"VALIDATION_FAILED\",\"fields\":{\"sort\":[\"please use one of [id title year runtime -id -title -year -runtime]\"]}}}]}\n"},
+		{"create validation failed test", `{"query":"mutation { createMovie(input: {title: \"\", runtime: 100, year: 2020, genres: []}) { id } }"}`, http.StatusOK, "{\"data\":null,\"errors\":[{\"message\":\"failed validation\",\"locations\":[{\"line\":1,\"column\":12}],\"path\":[\"createMovie\"],\"extensions\":{\"code\":\"VALIDATION_FAILED\",\"fields\":{\"genres\":[\"should contain at least 1 genre\"],\"title\":[\"should not be empty\"]}}}]}\n"},
+		{"create test", `{"query":"mutation { createMovie(input: {title: \"test\", runtime: 100, year: 2020, genres: [action]}) { id } }"}`, http.StatusOK, "{\"data\":{\"createMovie\":{\"id\":2}}}\n"},
+		{"delete not found test", `{"query":"mutation { deleteMovie(id: 0) }"}`, http.StatusOK, "{\"data\":null,\"errors\":[{\"message\":\"the requested resource could not be found\",\"locations\":[{\"line\":1,\"column\":12}],\"path\":[\"deleteMovie\"],\"extensions\":{\"code\":\"NOT_FOUND\"}}]}\n"},
+		{"inline fragment test", `{"query":"{ a: movie(id: 1) { ... on Movie { ... on Movie { id } } } b: movies { id } }"}`, http.StatusOK, "{\"data\":{\"a\":{\"id\":1},\"b\":[{\"id\":1},{\"id\":2}]}}\n"},
+		{"complexity limit test", `{"query":"{ ` + strings.Repeat("movie(id: 1) { id } ", 1) + strings.Repeat("m: movie(id: 1) { id title runtime year genres } ", 20) + `}"}`, http.StatusBadRequest, "{\"errors\":[{\"extensions\":{\"code\":\"QUERY_TOO_COMPLEX\"},\"message\":\"query complexity 122 exceeds the maximum of 100\"}]}\n"},
+		{"introspection disabled test", `{"query":"{ __schema { queryType { name } } }"}`, http.StatusBadRequest, "{\"errors\":[{\"extensions\":{\"code\":\"INTROSPECTION_DISABLED\"},\"message\":\"introspection is disabled\"}]}\n"},
+		{"extensions test", `{"query":"{ movie(id: 1) { id } }","extensions":{"persistedQuery":{"version":1,"sha256Hash":"abc"}}}`, http.StatusOK, "{\"data\":{\"movie\":{\"id\":1}}}\n"},
+		{"unknown member test", `{"query":"{ movie(id: 1) { id } }","banana":1}`, http.StatusBadRequest, ""},
+		{"empty body test", ``, http.StatusBadRequest, "{\"error\":\"body must not be empty\"}\n"},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/v1/graphql", strings.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		testApp.routes().ServeHTTP(rr, req)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+
+		if e.expectedResponse != "" && e.expectedResponse != rr.Body.String() {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
+		}
+	}
+}
+
+func TestGraphQLValidationLanguage(t *testing.T) {
+	body := `{"query":"mutation { createMovie(input: {title: \"test\", runtime: 100, year: 2020, genres: [action, action, action, action, action, action]}) { id } }"}`
+	req, _ := http.NewRequest("POST", "/v1/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "fr")
+	rr := httptest.NewRecorder()
+	testApp.routes().ServeHTTP(rr, req)
+
+	expected := "{\"data\":null,\"errors\":[{\"message\":\"failed validation\",\"locations\":[{\"line\":1,\"column\":12}],\"path\":[\"createMovie\"],\"extensions\":{\"code\":\"VALIDATION_FAILED\",\"fields\":{\"genres\":[\"ne doit pas contenir plus de 5 genres\",\"ne doit pas contenir de genres en double\"]}}}]}\n"
+	if rr.Body.String() != expected {
+		t.Errorf("expected %s but got %s", expected, rr.Body.String())
+	}
+}
+
+func TestCheckQueryLimits(t *testing.T) {
+	deepIntrospection := `{ __schema { types { ` + strings.Repeat("fields { type { ", 10) + "name" + strings.Repeat(" } }", 10) + " } } }"
+	tests := []struct {
+		name          string
+		query         string
+		introspection bool
+		expectError   bool
+	}{
+		{"shallow query test", `{ movie(id: 1) { id } }`, false, false},
+		{"fragment cycle test", `query { movie(id: 1) { ...A } } fragment A on Movie { ...A }`, false, false},
+		{"introspection test", `{ __schema { types { fields { type { ofType { ofType { ofType { name } } } } } } } }`, true, false},
+		{"introspection query test", testutil.IntrospectionQuery, true, false},
+		{"introspection disabled test", `{ __schema { types { name } } }`, false, true},
+		{"type introspection disabled test", `{ movie(id: 1) { id } __type(name: "Movie") { name } }`, false, true},
+		{"introspection too deep test", deepIntrospection, true, true},
+		{"typename test", `{ __typename movie(id: 1) { __typename id } }`, false, false},
+		{"typename too deep test", `{ movie(id: 1) { a { b { c { d { __typename } } } } } }`, false, true},
+		{"too deep test", `{ movie(id: 1) { a { b { c { d { e } } } } } }`, false, true},
+		{"movies test", `{ a: movies { id } b: movies { id } c: movies { id } d: movies { id } }`, false, false},
+		{"too many movies test", `{ a: movies { id } b: movies { id } c: movies { id } d: movies { id } e: movies { id } }`, false, true},
+	}
+
+	for _, e := range tests {
+		err := checkQueryLimits(e.query, e.introspection)
+		if (err != nil) != e.expectError {
+			t.Errorf("%s: expected error %t but got %v", e.name, e.expectError, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"github.com/rrebeiz/quickmovies/internal/moviespb"
+	"github.com/rrebeiz/quickmovies/internal/validator"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// movieServer implements moviespb.MovieServiceServer on top of app.models,
+// mirroring the REST movie handlers.
+type movieServer struct {
+	moviespb.UnimplementedMovieServiceServer
+	app *application
+}
+
+// newGRPCServer returns a gRPC server exposing the MovieService. When creds
+// is nil the server runs without TLS.
+func (app *application) newGRPCServer(creds credentials.TransportCredentials) *grpc.Server {
+	var opts []grpc.ServerOption
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	srv := grpc.NewServer(opts...)
+	moviespb.RegisterMovieServiceServer(srv, &movieServer{app: app})
+	return srv
+}
+
+func (s *movieServer) GetMovie(ctx context.Context, req *moviespb.GetMovieRequest) (*moviespb.Movie, error) {
+	movie, err := s.app.models.Movies.GetMovie(ctx, req.GetId())
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+	return toProtoMovie(movie), nil
+}
+
+func (s *movieServer) ListMovies(req *moviespb.ListMoviesRequest, stream moviespb.MovieService_ListMoviesServer) error {
+	movies, err := s.app.models.Movies.GetAllMovies(stream.Context())
+	if err != nil {
+		return s.statusError(err)
+	}
+	for _, movie := range movies {
+		err = stream.Send(toProtoMovie(movie))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *movieServer) CreateMovie(ctx context.Context, req *moviespb.CreateMovieRequest) (*moviespb.Movie, error) {
+	movie := &data.Movie{
+		Title:   req.GetTitle(),
+		Runtime: req.GetRuntime(),
+		Year:    req.GetYear(),
+		Genres:  req.GetGenres(),
+	}
+
+	v := validator.NewValidator()
+	data.ValidateMovie(v, movie)
+	if !v.Valid() {
+		return nil, invalidArgumentError(v)
+	}
+
+	err := s.app.models.Movies.CreateMovie(ctx, movie)
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+	return toProtoMovie(movie), nil
+}
+
+func (s *movieServer) UpdateMovie(ctx context.Context, req *moviespb.UpdateMovieRequest) (*moviespb.Movie, error) {
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		paths = []string{"title", "runtime", "year", "genres"}
+	}
+
+	v := validator.NewValidator()
+	for _, path := range paths {
+		v.CheckMessage(validator.PermittedValue(path, "title", "runtime", "year", "genres"), "update_mask", "not_permitted", "validation.update_mask_not_permitted")
+	}
+	if !v.Valid() {
+		return nil, invalidArgumentError(v)
+	}
+
+	movie, err := s.app.models.Movies.GetMovie(ctx, req.GetId())
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+
+	for _, path := range paths {
+		switch path {
+		case "title":
+			movie.Title = req.GetTitle()
+		case "runtime":
+			movie.Runtime = req.GetRuntime()
+		case "year":
+			movie.Year = req.GetYear()
+		case "genres":
+			movie.Genres = req.GetGenres()
+		}
+	}
+
+	data.ValidateMovie(v, movie)
+	if !v.Valid() {
+		return nil, invalidArgumentError(v)
+	}
+
+	err = s.app.models.Movies.UpdateMovie(ctx, movie)
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+	return toProtoMovie(movie), nil
+}
+
+func (s *movieServer) DeleteMovie(ctx context.Context, req *moviespb.DeleteMovieRequest) (*moviespb.DeleteMovieResponse, error) {
+	err := s.app.models.Movies.DeleteMovie(ctx, req.GetId())
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+	return &moviespb.DeleteMovieResponse{}, nil
+}
+
+// statusError maps data layer errors to gRPC status codes. Unexpected errors
+// are logged and reported as Internal without leaking their details.
+func (s *movieServer) statusError(err error) error {
+	var checkErr *data.CheckViolationError
+	switch {
+	case errors.Is(err, data.ErrNoRecordFound):
+		return status.Error(codes.NotFound, "the requested resource could not be found")
+	case errors.Is(err, data.ErrEditConflict):
+		return status.Error(codes.Aborted, "unable to update the record due to an edit conflict, please try again")
+	case errors.As(err, &checkErr):
+		return invalidArgumentError(checkViolationValidator(checkErr))
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		s.app.errorLog.Println(err)
+		return status.Error(codes.Internal, "the server encountered a problem and could not process your request")
+	}
+}
+
+// invalidArgumentError reports the validator errors as BadRequest field
+// violations.
+func invalidArgumentError(v *validator.Validator) error {
+	details := &errdetails.BadRequest{}
+	for _, field := range v.Fields() {
+		for _, e := range v.Errors[field] {
+			details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field,
+				Description: e.Message,
+			})
+		}
+	}
+
+	st, err := status.New(codes.InvalidArgument, "failed validation").WithDetails(details)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "failed validation")
+	}
+	return st.Err()
+}
+
+func toProtoMovie(movie *data.Movie) *moviespb.Movie {
+	return &moviespb.Movie{
+		Id:      movie.ID,
+		Title:   movie.Title,
+		Runtime: movie.Runtime,
+		Year:    movie.Year,
+		Genres:  movie.Genres,
+		Version: movie.Version,
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/rrebeiz/quickmovies/internal/moviespb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"io"
+	"net"
+	"testing"
+)
+
+func newTestMovieClient(t *testing.T) moviespb.MovieServiceClient {
+	listener := bufconn.Listen(1024 * 1024)
+	srv := testApp.newGRPCServer(nil)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return moviespb.NewMovieServiceClient(conn)
+}
+
+func TestGRPCMovieService(t *testing.T) {
+	client := newTestMovieClient(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		call         func() error
+		expectedCode codes.Code
+	}{
+		{"get movie test", func() error {
+			movie, err := client.GetMovie(ctx, &moviespb.GetMovieRequest{Id: 1})
+			if err == nil && movie.GetTitle() != "test" {
+				return errors.New("unexpected movie " + movie.String())
+			}
+			return err
+		}, codes.OK},
+		{"get movie not found test", func() error {
+			_, err := client.GetMovie(ctx, &moviespb.GetMovieRequest{Id: 0})
+			return err
+		}, codes.NotFound},
+		{"get movie server error test", func() error {
+			_, err := client.GetMovie(ctx, &moviespb.GetMovieRequest{Id: 2})
+			return err
+		}, codes.Internal},
+		{"create movie test", func() error {
+			_, err := client.CreateMovie(ctx, &moviespb.CreateMovieRequest{Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action"}})
+			return err
+		}, codes.OK},
+		{"update movie test", func() error {
+			movie, err := client.UpdateMovie(ctx, &moviespb.UpdateMovieRequest{Id: 1, Title: "new test", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"title"}}})
+			if err == nil && (movie.GetTitle() != "new test" || movie.GetRuntime() != 100) {
+				return errors.New("unexpected movie " + movie.String())
+			}
+			return err
+		}, codes.OK},
+		{"update movie invalid mask test", func() error {
+			_, err := client.UpdateMovie(ctx, &moviespb.UpdateMovieRequest{Id: 1, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"id"}}})
+			return err
+		}, codes.InvalidArgument},
+		{"delete movie test", func() error {
+			_, err := client.DeleteMovie(ctx, &moviespb.DeleteMovieRequest{Id: 1})
+			return err
+		}, codes.OK},
+		{"delete movie not found test", func() error {
+			_, err := client.DeleteMovie(ctx, &moviespb.DeleteMovieRequest{Id: 0})
+			return err
+		}, codes.NotFound},
+	}
+
+	for _, e := range tests {
+		err := e.call()
+		if status.Code(err) != e.expectedCode {
+			t.Errorf("%s: expected %s but got %v", e.name, e.expectedCode, err)
+		}
+	}
+}
+
+func TestGRPCCreateMovieValidation(t *testing.T) {
+	client := newTestMovieClient(t)
+
+	_, err := client.CreateMovie(context.Background(), &moviespb.CreateMovieRequest{Runtime: 100, Year: 2020})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("expected %s but got %s", codes.InvalidArgument, st.Code())
+	}
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations = badRequest.GetFieldViolations()
+		}
+	}
+	expected := map[string]string{
+		"genres": "should not be empty",
+		"title":  "should not be empty",
+	}
+	if len(violations) != len(expected) {
+		t.Fatalf("expected %d field violations but got %v", len(expected), violations)
+	}
+	for _, violation := range violations {
+		if expected[violation.GetField()] != violation.GetDescription() {
+			t.Errorf("%s: expected %q but got %q", violation.GetField(), expected[violation.GetField()], violation.GetDescription())
+		}
+	}
+}
+
+func TestGRPCListMovies(t *testing.T) {
+	client := newTestMovieClient(t)
+
+	stream, err := client.ListMovies(context.Background(), &moviespb.ListMoviesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for {
+		movie, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, movie.GetTitle())
+	}
+	if len(titles) != 2 || titles[0] != "test movie 1" || titles[1] != "test movie 2" {
+		t.Errorf("expected the two test movies but got %v", titles)
+	}
+}
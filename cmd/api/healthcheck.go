@@ -1,19 +1,154 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 )
 
+// readinessTimeout bounds each dependency check of the readiness probe.
+var readinessTimeout = 2 * time.Second
+
+// dependency is a service the API needs to serve requests. The API is not
+// ready while a dependency is down, unless it is optional.
+type dependency struct {
+	name     string
+	optional bool
+	// check returns details reported along with the status, such as the
+	// schema version of a database.
+	check func(ctx context.Context) (envelope, error)
+}
+
+// liveHandler reports that the process is up. It does not look at any
+// dependency, so that a restart is not triggered by a database outage.
+func (app *application) liveHandler(w http.ResponseWriter, r *http.Request) {
+	status := envelope{
+		"status":  "alive",
+		"version": version,
+		"commit":  commit,
+		"uptime":  app.uptime(),
+	}
+	err := app.writeResponse(w, r, http.StatusOK, status, noStore())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readyHandler reports whether the API can serve requests, with the status
+// and latency of every dependency. It answers 503 when a required dependency
+// is down and as soon as the server starts shutting down.
+func (app *application) readyHandler(w http.ResponseWriter, r *http.Request) {
+	ready, dependencies := app.checkDependencies(r.Context())
+	status := envelope{
+		"status":       "ready",
+		"version":      version,
+		"commit":       commit,
+		"uptime":       app.uptime(),
+		"dependencies": dependencies,
+	}
+	code := http.StatusOK
+	switch {
+	case app.shuttingDown():
+		status["status"] = "shutting_down"
+		code = http.StatusServiceUnavailable
+	case !ready:
+		status["status"] = "not_ready"
+		code = http.StatusServiceUnavailable
+	}
+	err := app.writeResponse(w, r, code, status, noStore())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// healthCheckHandler is the original health check, kept for existing
+// clients. Like the liveness probe it does not look at any dependency, as it
+// is polled often, but it answers 503 once the server is shutting down.
 func (app *application) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	status := envelope{
 		"environment": app.config.env,
 		"status":      "healthy",
 		"port":        fmt.Sprintf("%d", app.config.port),
+		"version":     version,
+	}
+	code := http.StatusOK
+	if app.shuttingDown() {
+		status["status"] = "unavailable"
+		code = http.StatusServiceUnavailable
 	}
-	err := app.writeJSON(w, http.StatusOK, status, nil)
+	err := app.writeResponse(w, r, code, status, noStore())
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
-		return
 	}
 }
+
+// checkDependencies runs the dependency checks concurrently, each within
+// readinessTimeout, and reports whether every required dependency is up. The
+// checks are skipped once the server is shutting down, and the errors of the
+// failed ones are logged rather than reported.
+func (app *application) checkDependencies(ctx context.Context) (bool, envelope) {
+	results := envelope{}
+	if app.shuttingDown() {
+		return false, results
+	}
+
+	ready := true
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, d := range app.dependencies {
+		wg.Add(1)
+		go func(d dependency) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+			defer cancel()
+
+			start := time.Now()
+			details, err := d.check(ctx)
+			latency := time.Since(start)
+
+			result := envelope{
+				"status":   "up",
+				"optional": d.optional,
+				"latency":  latency.Round(time.Microsecond).String(),
+			}
+			for k, v := range details {
+				result[k] = v
+			}
+			if err != nil {
+				// the error can reveal hosts and credentials, so it is only
+				// logged.
+				result["status"] = "down"
+				app.errorLog.Printf("dependency %s is down %s", d.name, err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[d.name] = result
+			if err != nil && !d.optional {
+				ready = false
+			}
+		}(d)
+	}
+	wg.Wait()
+	return ready, results
+}
+
+func (app *application) shuttingDown() bool {
+	select {
+	case <-app.shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+func (app *application) uptime() string {
+	return time.Since(app.startedAt).Round(time.Second).String()
+}
+
+// noStore keeps proxies from caching probe responses.
+func noStore() http.Header {
+	return http.Header{"Cache-Control": []string{"no-store"}}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthHandlers(t *testing.T) {
+	up := dependency{name: "database", check: func(ctx context.Context) (envelope, error) {
+		return envelope{"schema_version": 4}, nil
+	}}
+	down := dependency{name: "database", check: func(ctx context.Context) (envelope, error) {
+		return envelope{"schema_version": 0}, errors.New("connection refused")
+	}}
+	replicaDown := dependency{name: "replica_1", optional: true, check: func(ctx context.Context) (envelope, error) {
+		return nil, errors.New("connection refused")
+	}}
+	slow := dependency{name: "database", check: func(ctx context.Context) (envelope, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+
+	tests := []struct {
+		name           string
+		url            string
+		dependencies   []dependency
+		shuttingDown   bool
+		expectedStatus int
+		expectedBody   []string
+	}{
+		{"live test", "/v1/health/live", []dependency{down}, false, http.StatusOK, []string{`"status":"alive"`, `"version":"dev"`, `"commit":"unknown"`, `"uptime":"`}},
+		{"live shutting down test", "/v1/health/live", nil, true, http.StatusOK, []string{`"status":"alive"`}},
+		{"ready test", "/v1/health/ready", []dependency{up}, false, http.StatusOK, []string{`"status":"ready"`, `"database":{"latency":"`, `"optional":false,"schema_version":4,"status":"up"}`}},
+		{"ready without dependencies test", "/v1/health/ready", nil, false, http.StatusOK, []string{`"dependencies":{}`, `"status":"ready"`}},
+		{"database down test", "/v1/health/ready", []dependency{down, replicaDown}, false, http.StatusServiceUnavailable, []string{`"status":"not_ready"`, `"database":{"latency":"`, `"optional":false,"schema_version":0,"status":"down"}`}},
+		{"optional down test", "/v1/health/ready", []dependency{up, replicaDown}, false, http.StatusOK, []string{`"status":"ready"`, `"replica_1":{"latency":"`, `"optional":true,"status":"down"}`}},
+		{"timeout test", "/v1/health/ready", []dependency{slow}, false, http.StatusServiceUnavailable, []string{`"status":"not_ready"`, `"status":"down"`}},
+		{"shutting down test", "/v1/health/ready", []dependency{up}, true, http.StatusServiceUnavailable, []string{`"dependencies":{}`, `"status":"shutting_down"`}},
+		{"legacy test", "/v1/healthcheck", []dependency{up}, false, http.StatusOK, []string{`"status":"healthy"`, `"version":"dev"`}},
+		{"legacy database down test", "/v1/healthcheck", []dependency{down}, false, http.StatusOK, []string{`"status":"healthy"`}},
+		{"legacy shutting down test", "/v1/healthcheck", []dependency{up}, true, http.StatusServiceUnavailable, []string{`"status":"unavailable"`}},
+	}
+
+	readinessTimeout = 50 * time.Millisecond
+	defer func() { readinessTimeout = 2 * time.Second }()
+
+	for _, e := range tests {
+		app := testApp
+		app.dependencies = e.dependencies
+		app.shutdown = make(chan struct{})
+		if e.shuttingDown {
+			close(app.shutdown)
+		}
+
+		req, _ := http.NewRequest("GET", e.url, nil)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if strings.Contains(rr.Body.String(), "connection refused") || strings.Contains(rr.Body.String(), "deadline") {
+			t.Errorf("%s: expected no dependency error in %s", e.name, rr.Body.String())
+		}
+		for _, expected := range e.expectedBody {
+			if !strings.Contains(rr.Body.String(), expected) {
+				t.Errorf("%s: expected %s in %s", e.name, expected, rr.Body.String())
+			}
+		}
+		if rr.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("%s: expected Cache-Control no-store but got %q", e.name, rr.Header().Get("Cache-Control"))
+		}
+	}
+}
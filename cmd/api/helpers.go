@@ -3,8 +3,8 @@ package main
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/go-chi/chi/v5"
+	"github.com/rrebeiz/quickmovies/internal/i18n"
 	"io"
 	"net/http"
 	"strconv"
@@ -13,10 +13,39 @@ import (
 
 type envelope map[string]any
 
+// maxBodyBytes limits the size of request bodies.
+const maxBodyBytes = 1_048_576
+
 var (
 	ErrInvalidParamID = errors.New("invalid param ID")
 )
 
+// requestError is a request the API cannot read, reported to the client as a
+// catalogue message. Its Error is the English text, for the logs.
+type requestError struct {
+	message i18n.Message
+	// err is a sentinel error the request error matches with errors.Is.
+	err error
+}
+
+func newRequestError(key string, args ...any) error {
+	return &requestError{message: i18n.Message{Key: key, Args: args}}
+}
+
+// unsupportedMediaType returns a request error matching
+// ErrUnsupportedMediaType.
+func unsupportedMediaType(key string, args ...any) error {
+	return &requestError{message: i18n.Message{Key: key, Args: args}, err: ErrUnsupportedMediaType}
+}
+
+func (e *requestError) Error() string {
+	return e.message.Text(i18n.DefaultLanguage)
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
 func (app *application) readIDParam(r *http.Request) (int64, error) {
 	id, err := strconv.ParseInt(chi.URLParamFromCtx(r.Context(), "id"), 10, 64)
 	if err != nil || id <= 0 {
@@ -25,7 +54,7 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
-func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
 	var js []byte
 
 	if app.config.env == "develop" {
@@ -46,19 +75,20 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 	for k, v := range headers {
 		w.Header()[k] = v
 	}
-	w.Header().Set("Content-Type", "application/json")
+	if headers.Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
 	w.WriteHeader(status)
 	w.Write(js)
 	return nil
 }
 
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, data any) error {
-	maxBytes := 1_048_576
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 
-	err := dec.Decode(data)
+	err := dec.Decode(decodeTarget(r, data))
 
 	if err != nil {
 		var unmarshalTypeError *json.UnmarshalTypeError
@@ -68,26 +98,26 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, data an
 
 		switch {
 		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+			return newRequestError("error.body_badly_formed_at", "JSON", syntaxError.Offset)
 			// sometimes this error is returned
 		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("body contains badly-formed JSON")
+			return newRequestError("error.body_badly_formed", "JSON")
 			// check if the type is wrong
 		case errors.As(err, &unmarshalTypeError):
 			if unmarshalTypeError.Field != "" {
-				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+				return newRequestError("error.body_incorrect_type", unmarshalTypeError.Field)
 			}
-			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+			return newRequestError("error.body_incorrect_type_at", unmarshalTypeError.Offset)
 
 		// check if request body is empty
 		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
+			return newRequestError("error.body_empty")
 		case strings.HasPrefix(err.Error(), "json: unknown field "):
 			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
-			return fmt.Errorf("body contains unknown key %s", fieldName)
+			return newRequestError("error.body_unknown_key", fieldName)
 
 		case errors.As(err, &maxBytesError):
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+			return newRequestError("error.body_too_large", maxBodyBytes)
 
 		// check for developer error
 		case errors.As(err, &invalidUnmarshalError):
@@ -96,9 +126,9 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, data an
 			return err
 		}
 	}
-	err = dec.Decode(struct{}{})
+	err = dec.Decode(&struct{}{})
 	if err != io.EOF {
-		return errors.New("body must only contain a single JSON value")
+		return newRequestError("error.body_single_value")
 	}
 	return nil
 }
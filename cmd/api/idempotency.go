@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	maxIdempotencyKeyLength = 255
+	// idempotencyLockTimeout is how long a key stays reserved by a request
+	// that never finished, e.g. because the instance crashed. It is well above
+	// the server WriteTimeout.
+	idempotencyLockTimeout = time.Minute
+)
+
+// idempotent makes next safe to retry for clients sending an Idempotency-Key
+// header. The first response for a key is stored and replayed for every
+// retry with the same request, for idempotency-ttl. Server errors are not
+// stored, so the request can be retried. Keys are scoped to the client set by
+// identifyClient, so clients choosing the same key don't see each other's
+// responses.
+func (app *application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			app.badRequestResponse(w, r, newRequestError("error.idempotency_key_too_long", maxIdempotencyKeyLength))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesError):
+				app.badRequestResponse(w, r, newRequestError("error.body_too_large", maxBodyBytes))
+			default:
+				app.badRequestResponse(w, r, err)
+			}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		key = data.ClientFromContext(r.Context()) + " " + key
+
+		stored, err := app.models.IdempotencyKeys.ReserveKey(r.Context(), key, requestFingerprint(r, body), idempotencyLockTimeout)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrIdempotencyKeyReused):
+				app.errorResponse(w, http.StatusUnprocessableEntity, r, "idempotency_key_reused", localize(r, "error.idempotency_key_reused"))
+			case errors.Is(err, data.ErrIdempotencyKeyInFlight):
+				app.errorResponse(w, http.StatusConflict, r, "idempotency_key_in_flight", localize(r, "error.idempotency_key_in_flight"))
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if stored != nil {
+			for k, v := range stored.Headers {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next(rec, r)
+
+		// The response is stored even if the client went away, since that is
+		// exactly when it will retry.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if rec.status >= http.StatusInternalServerError {
+			err = app.models.IdempotencyKeys.ReleaseKey(ctx, key)
+		} else {
+			err = app.models.IdempotencyKeys.SaveResponse(ctx, key, rec.response(), app.config.idempotency.ttl)
+		}
+		if err != nil {
+			app.logError(r, err)
+		}
+	}
+}
+
+// requestFingerprint identifies a request, so a key reused for a different
+// request can be told apart from a retry. The Accept and Content-Type headers
+// are included, since the stored response is in the format they negotiated.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n%s\n%s\n", r.Method, r.URL.Path, r.Header.Get("Accept"), r.Header.Get("Content-Type"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status  int
+	headers http.Header
+	body    bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		rec.headers = rec.Header().Clone()
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *responseRecorder) response() *data.StoredResponse {
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &data.StoredResponse{Status: status, Headers: rec.headers, Body: rec.body.Bytes()}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotentCreateMovie(t *testing.T) {
+	app := testApp
+	app.config.idempotency.ttl = time.Hour
+	app.models = newTestModels()
+	routes := app.routes()
+
+	valid := `{"title":"test","runtime":100,"year":2020,"genres":["action","adventure"]}`
+	inFlight := httptest.NewRequest("POST", "/v1/movies", nil)
+	inFlight.Header.Set("Content-Type", "application/json")
+	_, err := app.models.IdempotencyKeys.ReserveKey(context.Background(), "addr:192.0.2.1 in-flight", requestFingerprint(inFlight, []byte(valid)), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := "{\"movie\":{\"id\":2,\"title\":\"test\",\"runtime\":100,\"year\":2020,\"genres\":[\"action\",\"adventure\"]}}\n"
+	tests := []struct {
+		name             string
+		key              string
+		remoteAddr       string
+		accept           string
+		body             string
+		expectedStatus   int
+		expectedReplayed bool
+		expectedResponse string
+	}{
+		{"first request test", "key-1", "192.0.2.1:1234", "", valid, http.StatusCreated, false, created},
+		{"retry test", "key-1", "192.0.2.1:1234", "", valid, http.StatusCreated, true, created},
+		{"other client test", "key-1", "198.51.100.7:1234", "", valid, http.StatusCreated, false, created},
+		{"different format test", "key-1", "192.0.2.1:1234", "application/xml", valid, http.StatusUnprocessableEntity, false, ""},
+		{"different body test", "key-1", "192.0.2.1:1234", "", `{"title":"other"}`, http.StatusUnprocessableEntity, false, "{\"error\":\"the Idempotency-Key was already used for a different request\"}\n"},
+		{"in flight test", "in-flight", "192.0.2.1:1234", "", valid, http.StatusConflict, false, "{\"error\":\"a request with this Idempotency-Key is still being processed, please try again later\"}\n"},
+		{"stored validation error test", "key-2", "192.0.2.1:1234", "", `{"title":""}`, http.StatusUnprocessableEntity, false, ""},
+		{"replayed validation error test", "key-2", "192.0.2.1:1234", "", `{"title":""}`, http.StatusUnprocessableEntity, true, ""},
+		{"server error test", "key-3", "192.0.2.1:1234", "", `{"title":"fails","runtime":100,"year":2020,"genres":["action"]}`, http.StatusInternalServerError, false, ""},
+		{"server error retry test", "key-3", "192.0.2.1:1234", "", `{"title":"fails","runtime":100,"year":2020,"genres":["action"]}`, http.StatusInternalServerError, false, ""},
+		{"no key test", "", "192.0.2.1:1234", "", valid, http.StatusCreated, false, created},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/v1/movies", strings.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = e.remoteAddr
+		if e.accept != "" {
+			req.Header.Set("Accept", e.accept)
+		}
+		if e.key != "" {
+			req.Header.Set("Idempotency-Key", e.key)
+		}
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if replayed := rr.Header().Get("Idempotent-Replayed") == "true"; replayed != e.expectedReplayed {
+			t.Errorf("%s: expected replayed %t but got %t", e.name, e.expectedReplayed, replayed)
+		}
+		if e.expectedResponse != "" && e.expectedResponse != rr.Body.String() {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
+		}
+		if e.expectedStatus == http.StatusCreated && rr.Header().Get("Location") != "/v1/movies/2" {
+			t.Errorf("%s: expected the Location header but got %q", e.name, rr.Header().Get("Location"))
+		}
+	}
+}
@@ -3,67 +3,229 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	_ "github.com/lib/pq"
 	"github.com/rrebeiz/quickmovies/internal/data"
+	"github.com/rrebeiz/quickmovies/internal/feed"
+	"github.com/rrebeiz/quickmovies/internal/webhook"
 	"log"
+	_ "modernc.org/sqlite"
+	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
-type config struct {
-	env  string
-	port int
-	db   struct {
-		dsn          string
-		maxOpenConns int
-		maxIdleConns int
-		maxIdleTime  string
-	}
-}
+const sqliteScheme = "sqlite://"
+
+// version and commit are set at build time with -ldflags, see the Makefile.
+var (
+	version = "dev"
+	commit  = "unknown"
+)
 
 type application struct {
 	config   config
 	infoLog  *log.Logger
 	errorLog *log.Logger
 	models   data.Models
+	feed     *feed.Broker
+	// cache is nil unless -cache-size is set.
+	cache *data.CachedMovieModel
+	// dependencies are checked by the readiness probe.
+	dependencies []dependency
+	startedAt    time.Time
+	// shutdown is closed once the server starts shutting down.
+	shutdown chan struct{}
 }
 
 func main() {
-	var cfg config
-	flag.StringVar(&cfg.env, "environment", "develop", "default app environment")
-	flag.IntVar(&cfg.port, "port", 4000, "the default app port")
-	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("DSN"), "the database DSN")
-	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "db max open connections")
-	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "db max idle connections")
-	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "db max idle time")
-	flag.Parse()
+	cfg, fs, err := loadConfig(os.Args[1:], os.Getenv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if cfg.printConfig {
+		err = writeConfig(os.Stdout, fs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	switch fs.Arg(0) {
+	case "":
+	case "admin":
+		os.Exit(runAdmin(cfg, fs.Args()[1:]))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected admin\n", fs.Arg(0))
+		os.Exit(2)
+	}
 
 	infoLog := log.New(os.Stdout, "INFO", log.Ltime|log.Ldate|log.Llongfile)
 	errorLog := log.New(os.Stdout, "ERROR", log.Ltime|log.Ltime|log.Lshortfile)
 
-	db, err := openDB(cfg)
-	if err != nil {
-		log.Fatalf("failed to start the db connection %s", err)
+	app := application{
+		config:    cfg,
+		infoLog:   infoLog,
+		errorLog:  errorLog,
+		feed:      feed.NewBroker(),
+		startedAt: time.Now(),
+		shutdown:  make(chan struct{}),
 	}
-	defer db.Close()
 
-	app := application{
-		config:   cfg,
-		infoLog:  infoLog,
-		errorLog: errorLog,
-		models:   data.NewModels(db),
+	var memory *data.MemoryMovieModel
+	var listener *data.MovieListener
+
+	switch cfg.storage {
+	case "memory":
+		memory = data.NewMemoryMovieModel()
+		if cfg.memory.snapshot != "" {
+			err := memory.LoadSnapshot(cfg.memory.snapshot)
+			if err != nil {
+				log.Fatalf("failed to load the memory snapshot %s", err)
+			}
+		}
+		app.models = data.NewMemoryModels(memory)
+	case "postgres", "sqlite":
+		db, err := openDB(cfg, cfg.db.dsn)
+		if err != nil {
+			log.Fatalf("failed to start the db connection %s", err)
+		}
+		defer db.Close()
+		app.dependencies = append(app.dependencies, databaseDependency("database", db, false))
+		if cfg.storage == "postgres" {
+			listener, err = data.NewMovieListener(cfg.db.dsn, time.Second, time.Minute, errorLog)
+			if err != nil {
+				log.Fatalf("failed to listen for movie changes %s", err)
+			}
+			defer listener.Close()
+		}
+		switch {
+		case cfg.storage == "sqlite":
+			app.models = data.NewSQLiteModels(db)
+		case len(cfg.db.replicaDSNs) > 0:
+			var replicas []data.Replica
+			for i, dsn := range cfg.db.replicaDSNs {
+				replica, err := openReplica(cfg, dsn)
+				if err != nil {
+					log.Fatalf("failed to open the replica db connection %s", err)
+				}
+				defer replica.Close()
+				// reads fall back to the primary, so a replica being down
+				// does not make the API unready.
+				app.dependencies = append(app.dependencies, databaseDependency(fmt.Sprintf("replica_%d", i+1), replica, true))
+				replicas = append(replicas, data.Replica{Movies: data.NewMovieModel(replica), DB: replica})
+			}
+			movies := data.NewReplicatedMovieModel(data.NewMovieModel(db), replicas, cfg.db.stickiness)
+			defer movies.Close()
+			app.models = data.NewReplicatedModels(movies, db)
+		default:
+			app.models = data.NewModels(db)
+		}
+	default:
+		log.Fatalf("unknown storage %q, expected memory, postgres or sqlite", cfg.storage)
+	}
+
+	var cache *data.CachedMovieModel
+	if cfg.cache.size > 0 {
+		cache = data.NewCachedMovieModel(app.models.Movies, cfg.cache.size, cfg.cache.ttl)
+		app.models.Movies = cache
+		app.cache = cache
+	}
+
+	ctx, stopBackground := context.WithCancel(context.Background())
+	var background sync.WaitGroup
+
+	if listener != nil {
+		// Postgres announces every change, including our own, to all
+		// instances, so the feed and the cache follow the notifications.
+		listener.Subscribe(app.feed.Publish, nil)
+		if cache != nil {
+			listener.Subscribe(func(event data.MovieEvent) {
+				cache.Invalidate(event.Movie.ID)
+			}, cache.Purge)
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			listener.Run(ctx)
+		}()
+	} else {
+		movies, err := data.NewNotifyingMovieModel(ctx, app.models.Movies, app.models.Events, app.feed.Publish)
+		if err != nil {
+			log.Fatalf("failed to read the outbox %s", err)
+		}
+		app.models.Movies = movies
+	}
+
+	dispatcher := webhook.NewDispatcher(app.models.Webhooks, errorLog)
+	dispatcher.PollInterval = cfg.webhooks.pollInterval
+	dispatcher.MaxAttempts = cfg.webhooks.maxAttempts
+	dispatcher.DisableAfter = cfg.webhooks.disableAfter
+	if cfg.webhooks.allowPrivate {
+		dispatcher.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		dispatcher.Run(ctx)
+	}()
+
+	// the databases are swept with the purge admin command instead.
+	if keys, ok := app.models.IdempotencyKeys.(*data.MemoryIdempotencyKeyModel); ok {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			keys.Run(ctx)
+		}()
 	}
 
 	err = app.serve()
+	stopBackground()
+	background.Wait()
 	if err != nil {
 		app.errorLog.Fatal("failed to start the server")
 	}
 
+	if memory != nil && cfg.memory.snapshot != "" {
+		err = memory.SaveSnapshot(cfg.memory.snapshot)
+		if err != nil {
+			app.errorLog.Fatalf("failed to save the memory snapshot %s", err)
+		}
+	}
+}
+
+// dbDriver returns the storage name for dsn. DSNs using the sqlite:// scheme
+// are served by SQLite, everything else is handed to Postgres.
+func dbDriver(dsn string) string {
+	if strings.HasPrefix(dsn, sqliteScheme) {
+		return "sqlite"
+	}
+	return "postgres"
 }
 
-func openDB(cfg config) (*sql.DB, error) {
-	db, err := sql.Open("postgres", cfg.db.dsn)
+func openDB(cfg config, dsn string) (*sql.DB, error) {
+	var db *sql.DB
+	var err error
+
+	switch dbDriver(dsn) {
+	case "sqlite":
+		path := strings.TrimPrefix(dsn, sqliteScheme)
+		if path == "" {
+			return nil, errors.New("sqlite dsn must contain a file path")
+		}
+		db, err = sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	default:
+		db, err = sql.Open("postgres", dsn)
+	}
 
 	if err != nil {
 		return nil, err
@@ -86,3 +248,37 @@ func openDB(cfg config) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// databaseDependency checks that db is reachable and migrated to at least
+// data.SchemaVersion.
+func databaseDependency(name string, db *sql.DB, optional bool) dependency {
+	return dependency{
+		name:     name,
+		optional: optional,
+		check: func(ctx context.Context) (envelope, error) {
+			version, err := data.CheckSchema(ctx, db)
+			return envelope{"schema_version": version}, err
+		},
+	}
+}
+
+// openReplica opens a replica connection pool. Unlike openDB it does not fail
+// when the replica is unreachable, since the replica health checks route reads
+// to the primary until it comes back.
+func openReplica(cfg config, dsn string) (*sql.DB, error) {
+	if dbDriver(dsn) != "postgres" {
+		return nil, errors.New("replicas are only supported for postgres")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.db.maxOpenConns)
+	db.SetMaxIdleConns(cfg.db.maxIdleConns)
+	duration, err := time.ParseDuration(cfg.db.maxIdleTime)
+	if err != nil {
+		return nil, err
+	}
+	db.SetConnMaxIdleTime(duration)
+	return db, nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http"
+)
+
+// cacheStatsHandler reports the movie cache counters. Only these are served,
+// the rest of the process state stays private.
+func (app *application) cacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if app.cache == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"movies_cache": app.cache.Stats()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheStatsHandler(t *testing.T) {
+	cache := data.NewCachedMovieModel(data.NewMemoryMovieModel(), 10, time.Minute)
+	cache.GetMovie(context.Background(), 1)
+
+	tests := []struct {
+		name             string
+		cache            *data.CachedMovieModel
+		token            string
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{"cache test", cache, writerToken, http.StatusOK, "{\"movies_cache\":{\"hits\":0,\"misses\":1,\"size\":1}}\n"},
+		{"no cache test", nil, writerToken, http.StatusNotFound, "{\"error\":\"the requested resource could not be found\"}\n"},
+		{"anonymous test", cache, "", http.StatusUnauthorized, "{\"error\":\"you must be authenticated to access this resource\"}\n"},
+		{"no permission test", cache, readerToken, http.StatusForbidden, "{\"error\":\"your account does not have the permissions needed to access this resource\"}\n"},
+	}
+
+	for _, e := range tests {
+		app := testApp
+		app.cache = e.cache
+		req := httptest.NewRequest("GET", "/v1/metrics/cache", nil)
+		if e.token != "" {
+			req.Header.Set("Authorization", "Bearer "+e.token)
+		}
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if e.expectedResponse != rr.Body.String() {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	testApp.routes().ServeHTTP(rr, httptest.NewRequest("GET", "/debug/vars", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected /debug/vars to be gone but got %d", rr.Code)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// sessionHeader lets anonymous clients that share an address, like browsers
+// behind the same NAT, keep their read-your-writes windows apart. Sessions are
+// handed out by the server and signed, so a client can't pick another
+// client's session.
+const sessionHeader = "X-Session-ID"
+
+// identifyClient tags the request context with the identity of the client so
+// the data layer can keep a client's reads consistent with its own writes. It
+// must run after authenticate.
+func (app *application) identifyClient(next http.Handler) http.Handler {
+	var proxies []netip.Prefix
+	for _, proxy := range app.config.trustedProxies {
+		// validated with the config.
+		prefix, _ := parsePrefix(proxy)
+		proxies = append(proxies, prefix)
+	}
+	// sessions only need to outlive the read-your-writes windows kept by
+	// this process, so the key is not persisted.
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contextUser(r) == nil && !validSession(key, r.Header.Get(sessionHeader)) {
+			w.Header().Set(sessionHeader, newSession(key))
+		}
+		ctx := data.WithClient(r.Context(), clientIdentity(r, proxies, key))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// clientIdentity is the authenticated user, else the session header if it
+// was signed with key, else the client address.
+func clientIdentity(r *http.Request, proxies []netip.Prefix, key []byte) string {
+	if user := contextUser(r); user != nil {
+		return fmt.Sprintf("user:%d", user.ID)
+	}
+	if session := r.Header.Get(sessionHeader); validSession(key, session) {
+		return "session:" + session
+	}
+	return "addr:" + clientAddr(r, proxies)
+}
+
+// newSession returns a random session ID followed by its signature.
+func newSession(key []byte) string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(id)
+	return encoded + "." + sessionSignature(key, encoded)
+}
+
+func validSession(key []byte, session string) bool {
+	id, signature, ok := strings.Cut(session, ".")
+	if !ok {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(sessionSignature(key, id)))
+}
+
+func sessionSignature(key []byte, id string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// clientAddr returns the address the request came from. When that is a
+// trusted proxy, X-Forwarded-For is followed from the right to the first
+// address that is not one.
+func clientAddr(r *http.Request, proxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !trustedProxy(addr, proxies) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		host = hop.Unmap().String()
+		if !trustedProxy(hop, proxies) {
+			break
+		}
+	}
+	return host
+}
+
+func trustedProxy(addr netip.Addr, proxies []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
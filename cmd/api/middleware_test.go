@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIdentity(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.0.2.1/32")}
+	key := []byte("session key")
+	session := newSession(key)
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		forwardedFor  []string
+		session       string
+		user          *data.User
+		expectedIdent string
+	}{
+		{"user test", "10.0.0.1:1234", []string{"203.0.113.9"}, "abc", &data.User{ID: 7}, "user:7"},
+		{"session test", "10.0.0.1:1234", []string{"203.0.113.9"}, session, nil, "session:" + session},
+		{"unsigned session test", "203.0.113.9:1234", nil, "abc", nil, "addr:203.0.113.9"},
+		{"forged session test", "203.0.113.9:1234", nil, newSession([]byte("other key")), nil, "addr:203.0.113.9"},
+		{"direct test", "203.0.113.9:1234", nil, "", nil, "addr:203.0.113.9"},
+		{"untrusted forwarded test", "203.0.113.9:1234", []string{"198.51.100.7"}, "", nil, "addr:203.0.113.9"},
+		{"trusted proxy test", "10.0.0.1:1234", []string{"198.51.100.7"}, "", nil, "addr:198.51.100.7"},
+		{"proxy chain test", "10.0.0.1:1234", []string{"6.6.6.6, 198.51.100.7", "192.0.2.1"}, "", nil, "addr:198.51.100.7"},
+		{"malformed forwarded test", "10.0.0.1:1234", []string{"unknown"}, "", nil, "addr:10.0.0.1"},
+		{"only proxies test", "10.0.0.1:1234", []string{"10.0.0.2"}, "", nil, "addr:10.0.0.2"},
+	}
+
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/v1/movies/1", nil)
+		req.RemoteAddr = e.remoteAddr
+		for _, value := range e.forwardedFor {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+		if e.session != "" {
+			req.Header.Set(sessionHeader, e.session)
+		}
+		if e.user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), userContextKey, e.user))
+		}
+
+		if got := clientIdentity(req, proxies, key); got != e.expectedIdent {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expectedIdent, got)
+		}
+	}
+}
+
+func TestIdentifyClientIssuesSession(t *testing.T) {
+	var client string
+	handler := testApp.identifyClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client = data.ClientFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/v1/movies/1", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	session := rr.Header().Get(sessionHeader)
+	if session == "" {
+		t.Fatal("expected a session to be issued")
+	}
+	if client != "addr:192.0.2.1" {
+		t.Errorf("expected addr:192.0.2.1 but got %s", client)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/movies/1", nil)
+	req.Header.Set(sessionHeader, session)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Header().Get(sessionHeader) != "" {
+		t.Errorf("expected no new session but got %s", rr.Header().Get(sessionHeader))
+	}
+	if client != "session:"+session {
+		t.Errorf("expected session:%s but got %s", session, client)
+	}
+}
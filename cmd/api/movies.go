@@ -3,9 +3,12 @@ package main
 import (
 	"errors"
 	"fmt"
+	"github.com/go-chi/chi/v5"
 	"github.com/rrebeiz/quickmovies/internal/data"
 	"github.com/rrebeiz/quickmovies/internal/validator"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
 func (app *application) getMovieHandler(w http.ResponseWriter, r *http.Request) {
@@ -13,7 +16,7 @@ func (app *application) getMovieHandler(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrInvalidParamID):
-			app.notFoundResponse(w, r)
+			app.movieNotFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -24,14 +27,14 @@ func (app *application) getMovieHandler(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
-			app.notFoundResponse(w, r)
+			app.movieNotFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": movie}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -44,7 +47,7 @@ func (app *application) getAllMoviesHandler(w http.ResponseWriter, r *http.Reque
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies}, nil)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movies": movies}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -59,7 +62,7 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		Genres  []string `json:"genres"`
 	}
 
-	err := app.readJSON(w, r, &input)
+	err := app.readRequest(w, r, &input)
 
 	if err != nil {
 		app.badRequestResponse(w, r, err)
@@ -77,13 +80,21 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	data.ValidateMovie(v, movie)
 
 	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
 	err = app.models.Movies.CreateMovie(r.Context(), movie)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		var checkErr *data.CheckViolationError
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.As(err, &checkErr):
+			app.checkViolationResponse(w, r, checkErr)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 	headers := make(http.Header)
@@ -91,7 +102,7 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	location := fmt.Sprintf("/v1/movies/%d", movie.ID)
 
 	headers.Set("Location", location)
-	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"movie": movie}, headers)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -105,22 +116,15 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrInvalidParamID):
-			app.notFoundResponse(w, r)
+			app.movieNotFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	var input struct {
-		Title   *string  `json:"title"`
-		Runtime *int32   `json:"runtime"`
-		Year    *int32   `json:"year"`
-		Genres  []string `json:"genres"`
-	}
-
-	err = app.readJSON(w, r, &input)
-
+	var input patch
+	err = app.readRequest(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -130,54 +134,175 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
-			app.notFoundResponse(w, r)
+			app.movieNotFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = patchMovie(movie, &input)
+	if err != nil {
+		var patchErr *patchError
+		switch {
+		case errors.Is(err, ErrPatchTestFailed):
+			app.errorResponse(w, http.StatusConflict, r, "patch_test_failed", localize(r, "error.patch_test_failed"))
+		case errors.As(err, &patchErr):
+			app.errorResponse(w, http.StatusUnprocessableEntity, r, "invalid_patch", patchErr.message.Text(requestLanguage(r)))
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
+
 	v := validator.NewValidator()
+	data.ValidateMovie(v, movie)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
 
-	if input.Title != nil {
-		movie.Title = *input.Title
+	err = app.models.Movies.UpdateMovie(r.Context(), movie)
+	if err != nil {
+		var checkErr *data.CheckViolationError
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.As(err, &checkErr):
+			app.checkViolationResponse(w, r, checkErr)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
 
-	if input.Year != nil {
-		v.Check(*input.Year > 0, "year", "should be a positive number")
-		movie.Year = *input.Year
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
+}
 
-	if input.Runtime != nil {
-		v.Check(*input.Runtime > 0, "runtime", "should be a positive number")
-		movie.Runtime = *input.Runtime
+// replaceMovieHandler replaces the movie with the id in the URL with the
+// complete representation in the body, creating it if it does not exist.
+func (app *application) replaceMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidParamID):
+			app.movieNotFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// id and external_id are accepted so a movie can be sent back as it was
+	// read, but neither can be changed.
+	var input struct {
+		ID         int64    `json:"id"`
+		ExternalID string   `json:"external_id"`
+		Title      string   `json:"title"`
+		Runtime    int32    `json:"runtime"`
+		Year       int32    `json:"year"`
+		Genres     []string `json:"genres"`
+	}
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
-	if input.Genres != nil {
-		v.Check(validator.Unique(input.Genres), "genres", "must not contain duplicate genres")
-		permittedGenres := []string{"action", "adventure", "comedy", "horror", "drama"}
-		for _, genre := range input.Genres {
-			v.Check(validator.PermittedValue(genre, permittedGenres...), "genre", fmt.Sprintf("please use the following genres %s", permittedGenres))
-			movie.Genres = input.Genres
+	v := validator.NewValidator()
+	v.CheckMessage(input.ID == 0 || input.ID == id, "id", "mismatch", "validation.id_mismatch")
+	v.CheckMessage(id <= data.MaxMovieID, "id", "too_large", "validation.too_large", strconv.FormatInt(data.MaxMovieID, 10))
+	if input.ExternalID != "" {
+		stored, err := app.models.Movies.GetMovie(r.Context(), id)
+		switch {
+		case err == nil:
+			v.CheckMessage(stored.ExternalID == input.ExternalID, "external_id", "immutable", "validation.immutable")
+		case !errors.Is(err, data.ErrNoRecordFound):
+			app.serverErrorResponse(w, r, err)
+			return
 		}
 	}
 
+	movie := &data.Movie{
+		ID:         id,
+		ExternalID: input.ExternalID,
+		Title:      input.Title,
+		Runtime:    input.Runtime,
+		Year:       input.Year,
+		Genres:     input.Genres,
+	}
+	app.upsertMovie(w, r, v, movie)
+}
+
+// replaceExternalMovieHandler is replaceMovieHandler for movies identified by
+// the external ID of an upstream catalogue.
+func (app *application) replaceExternalMovieHandler(w http.ResponseWriter, r *http.Request) {
+	externalID, err := url.PathUnescape(chi.URLParam(r, "externalID"))
+	if err != nil || externalID == "" {
+		app.movieNotFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		ExternalID string   `json:"external_id"`
+		Title      string   `json:"title"`
+		Runtime    int32    `json:"runtime"`
+		Year       int32    `json:"year"`
+		Genres     []string `json:"genres"`
+	}
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.NewValidator()
+	v.CheckMessage(input.ExternalID == "" || input.ExternalID == externalID, "external_id", "mismatch", "validation.external_id_mismatch")
+
+	movie := &data.Movie{
+		ExternalID: externalID,
+		Title:      input.Title,
+		Runtime:    input.Runtime,
+		Year:       input.Year,
+		Genres:     input.Genres,
+	}
+	app.upsertMovie(w, r, v, movie)
+}
+
+// upsertMovie validates and stores movie for the PUT handlers, answering with
+// 201 if the movie was created and 200 if it was replaced.
+func (app *application) upsertMovie(w http.ResponseWriter, r *http.Request, v *validator.Validator, movie *data.Movie) {
+	data.ValidateMovie(v, movie)
 	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
-	err = app.models.Movies.UpdateMovie(r.Context(), movie)
+	created, err := app.models.Movies.UpsertMovie(r.Context(), movie)
 	if err != nil {
+		var checkErr *data.CheckViolationError
 		switch {
-		case errors.Is(err, data.ErrEditConflict):
-			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			app.errorResponse(w, http.StatusConflict, r, "duplicate_external_id", localize(r, "error.duplicate_external_id"))
+		case errors.As(err, &checkErr):
+			app.checkViolationResponse(w, r, checkErr)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	status := http.StatusOK
+	headers := make(http.Header)
+	if created {
+		status = http.StatusCreated
+		headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	}
+	err = app.writeResponse(w, r, status, envelope{"movie": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -189,7 +314,7 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrInvalidParamID):
-			app.notFoundResponse(w, r)
+			app.movieNotFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -199,14 +324,17 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
-			app.notFoundResponse(w, r)
+			app.movieNotFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
-	message := fmt.Sprintf("movie with the id %d has been deleted", id)
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": message}, nil)
+	w.Header().Add("Vary", "Accept-Language")
+	headers := make(http.Header)
+	headers.Set("Content-Language", requestLanguage(r))
+	message := localize(r, "message.movie_deleted", id)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": message}, headers)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
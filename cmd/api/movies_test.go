@@ -2,34 +2,93 @@ package main
 
 import (
 	"context"
-	"github.com/go-chi/chi/v5"
+	"errors"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"github.com/rrebeiz/quickmovies/internal/data/datatest"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
+var errTestStore = errors.New("connection refused")
+
+const serverErrorResponse = "{\"error\":\"the server encountered a problem and could not process your request\"}\n"
+const notFoundResponse = "{\"error\":\"the requested resource could not be found\"}\n"
+
+// newTestMovies returns a store holding the movie with the id 1, and the
+// movies with the external ids tt0000001 and tt0000002.
+func newTestMovies(t *testing.T) *datatest.FakeMovies {
+	return datatest.NewFakeMovies(t,
+		&data.Movie{ID: 1, Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action", "adventure"}},
+		&data.Movie{ID: 2, ExternalID: "tt0000001", Title: "external test", Runtime: 100, Year: 2020, Genres: []string{"drama"}},
+		&data.Movie{ID: 3, ExternalID: "tt0000002", Title: "other external test", Runtime: 100, Year: 2020, Genres: []string{"comedy"}},
+	)
+}
+
+// failing makes method of the store fail with err.
+func failing(method string, err error) func(*datatest.FakeMovies) {
+	return func(movies *datatest.FakeMovies) {
+		movies.Fail(method, err)
+	}
+}
+
+// serveMovies sends a request through the routes of an application storing
+// its movies in movies.
+func serveMovies(movies data.Movies, method, url, body string) *httptest.ResponseRecorder {
+	app := testApp
+	app.models = newTestModels()
+	app.models.Movies = movies
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, url, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+	return rr
+}
+
+func checkMovieCalls(t *testing.T, name string, movies *datatest.FakeMovies, expected []string) {
+	t.Helper()
+	if expected == nil {
+		expected = []string{}
+	}
+	if !reflect.DeepEqual(expected, movies.Methods()) {
+		t.Errorf("%s: expected calls %v but got %v", name, expected, movies.Methods())
+	}
+}
+
 func TestGetMovieHandler(t *testing.T) {
 	tests := []struct {
 		name             string
-		id               string
+		url              string
+		setup            func(*datatest.FakeMovies)
 		expectedStatus   int
+		expectedCalls    []string
 		expectedResponse string
 	}{
-		{"valid test", "1", http.StatusOK, "{\"movie\":{\"id\":1,\"title\":\"test\",\"runtime\":100,\"year\":2020,\"genres\":[\"action\",\"adventure\"]}}\n"},
-		{"not found test", "0", http.StatusNotFound, "{\"error\":\"the requested resource could not be found\"}\n"},
-		{"no id test", "", http.StatusNotFound, "{\"error\":\"the requested resource could not be found\"}\n"},
+		{"valid test", "/v1/movies/1", nil, http.StatusOK, []string{"GetMovie"}, "{\"movie\":{\"id\":1,\"title\":\"test\",\"runtime\":100,\"year\":2020,\"genres\":[\"action\",\"adventure\"]}}\n"},
+		{"not found test", "/v1/movies/42", nil, http.StatusNotFound, []string{"GetMovie"}, notFoundResponse},
+		{"zero id test", "/v1/movies/0", nil, http.StatusNotFound, nil, notFoundResponse},
+		{"invalid id test", "/v1/movies/abc", nil, http.StatusNotFound, nil, notFoundResponse},
+		{"no id test", "/v1/movies/", nil, http.StatusNotFound, nil, notFoundResponse},
+		{"server error test", "/v1/movies/1", failing("GetMovie", errTestStore), http.StatusInternalServerError, []string{"GetMovie"}, serverErrorResponse},
 	}
 
 	for _, e := range tests {
-		req, _ := http.NewRequest("GET", "/v1/movies/1", nil)
-		chiCtx := chi.NewRouteContext()
-		chiCtx.URLParams.Add("id", e.id)
-		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
-
-		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(testApp.getMovieHandler)
-		handler.ServeHTTP(rr, req)
+		movies := newTestMovies(t)
+		if e.setup != nil {
+			e.setup(movies)
+		}
+		rr := serveMovies(movies, "GET", e.url, "")
 
 		if e.expectedStatus != rr.Code {
 			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
@@ -38,71 +97,201 @@ func TestGetMovieHandler(t *testing.T) {
 		if e.expectedResponse != rr.Body.String() {
 			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
 		}
+		checkMovieCalls(t, e.name, movies, e.expectedCalls)
+	}
+}
+
+func TestGetMovieHandlerCanceled(t *testing.T) {
+	movies := newTestMovies(t)
+	movies.Delay("GetMovie", time.Minute)
+	app := testApp
+	app.models = newTestModels()
+	app.models.Movies = movies
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "/v1/movies/1", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d but got %d", http.StatusInternalServerError, rr.Code)
 	}
+	checkMovieCalls(t, "canceled test", movies, []string{"GetMovie"})
 }
 
 func TestCreateMovieHandler(t *testing.T) {
+	checkViolation := &data.CheckViolationError{Constraint: "movies_runtime_check", Field: "runtime"}
 	tests := []struct {
 		name             string
 		body             string
+		setup            func(*datatest.FakeMovies)
 		expectedStatus   int
+		expectedLocation string
+		expectedCalls    []string
 		expectedResponse string
 	}{
-		{"valid test", `{"title":"test","runtime":100,"year":2020,"genres":["action","adventure"]}`, http.StatusCreated, "{\"movie\":{\"id\":2,\"title\":\"test\",\"runtime\":100,\"year\":2020,\"genres\":[\"action\",\"adventure\"]}}\n"},
-		{"invalid empty body test", ``, http.StatusBadRequest, "{\"error\":\"body must not be empty\"}\n"},
-		{"invalid empty data test", `{"title":"", "runtime":0, "year":0, "genres":[]}`, http.StatusUnprocessableEntity, "{\"error\":{\"genres\":\"should contain at least 1 genre\",\"runtime\":\"should not be empty\",\"title\":\"should not be empty\",\"year\":\"should not be empty\"}}\n"},
+		{"valid test", `{"title":"new test","runtime":100,"year":2020,"genres":["action","adventure"]}`, nil, http.StatusCreated, "/v1/movies/4", []string{"CreateMovie"}, "{\"movie\":{\"id\":4,\"title\":\"new test\",\"runtime\":100,\"year\":2020,\"genres\":[\"action\",\"adventure\"]}}\n"},
+		{"invalid empty body test", ``, nil, http.StatusBadRequest, "", nil, "{\"error\":\"body must not be empty\"}\n"},
+		{"invalid empty data test", `{"title":"", "runtime":0, "year":0, "genres":[]}`, nil, http.StatusUnprocessableEntity, "", nil, "{\"error\":{\"genres\":\"should contain at least 1 genre\",\"runtime\":\"should not be empty\",\"title\":\"should not be empty\",\"year\":\"should not be empty\"}}\n"},
+		{"check violation test", `{"title":"new test","runtime":100,"year":2020,"genres":["action"]}`, failing("CreateMovie", checkViolation), http.StatusUnprocessableEntity, "", []string{"CreateMovie"}, "{\"error\":{\"runtime\":\"is not accepted by the database\"}}\n"},
+		{"edit conflict test", `{"title":"new test","runtime":100,"year":2020,"genres":["action"]}`, failing("CreateMovie", data.ErrEditConflict), http.StatusConflict, "", []string{"CreateMovie"}, "{\"error\":\"unable to update the record due to an edit conflict, please try again\"}\n"},
+		{"server error test", `{"title":"new test","runtime":100,"year":2020,"genres":["action"]}`, failing("CreateMovie", errTestStore), http.StatusInternalServerError, "", []string{"CreateMovie"}, serverErrorResponse},
 	}
 
 	for _, e := range tests {
-		req, _ := http.NewRequest("POST", "/v1/movies", strings.NewReader(e.body))
-		req.Header.Set("Content-Type", "application/json")
-		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(testApp.createMovieHandler)
-
-		handler.ServeHTTP(rr, req)
+		movies := newTestMovies(t)
+		if e.setup != nil {
+			e.setup(movies)
+		}
+		rr := serveMovies(movies, "POST", "/v1/movies", e.body)
 
 		if e.expectedStatus != rr.Code {
 			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
 		}
 
+		if e.expectedLocation != rr.Header().Get("Location") {
+			t.Errorf("%s: expected location %q but got %q", e.name, e.expectedLocation, rr.Header().Get("Location"))
+		}
+
 		if e.expectedResponse != rr.Body.String() {
 			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
 		}
+		checkMovieCalls(t, e.name, movies, e.expectedCalls)
 	}
 }
 
 func TestUpdateMovieHandler(t *testing.T) {
+	valid := `{"title": "new test","runtime":150,"year":2021,"genres":["action"]}`
 	tests := []struct {
 		name             string
 		id               string
 		body             string
+		setup            func(*datatest.FakeMovies)
 		expectedStatus   int
+		expectedCalls    []string
 		expectedResponse string
 	}{
-		{"valid test", "1", `{"title": "new test","runtime":150,"year":2021,"genres":["action"]}`, http.StatusOK, "{\"movie\":{\"id\":1,\"title\":\"new test\",\"runtime\":150,\"year\":2021,\"genres\":[\"action\"]}}\n"},
-		{"not found test", "0", `{"title": "new test","runtime":150,"year":2021,"genres":["action"]}`, http.StatusNotFound, "{\"error\":\"the requested resource could not be found\"}\n"},
-		{"validation failed test", "1", `{"runtime":-15,"year":0,"genres":["banana", "banana"]}`, http.StatusUnprocessableEntity, "{\"error\":{\"genre\":\"please use the following genres [action adventure comedy horror drama]\",\"genres\":\"must not contain duplicate genres\",\"runtime\":\"should be a positive number\",\"year\":\"should be a positive number\"}}\n"},
-		{"server error test", "2", `{"title": "new test","runtime":150,"year":2021,"genres":["action"]}`, http.StatusInternalServerError, "{\"error\":\"the server encountered a problem and could not process your request\"}\n"},
+		{"valid test", "1", valid, nil, http.StatusOK, []string{"GetMovie", "UpdateMovie"}, "{\"movie\":{\"id\":1,\"title\":\"new test\",\"runtime\":150,\"year\":2021,\"genres\":[\"action\"]}}\n"},
+		{"not found test", "42", valid, nil, http.StatusNotFound, []string{"GetMovie"}, notFoundResponse},
+		{"invalid id test", "abc", valid, nil, http.StatusNotFound, nil, notFoundResponse},
+		{"validation failed test", "1", `{"runtime":-15,"year":0,"genres":["banana", "banana"]}`, nil, http.StatusUnprocessableEntity, []string{"GetMovie"}, "{\"error\":{\"genres\":\"must not contain duplicate genres\",\"genres[0]\":\"please use the following permitted genres [action adventure comedy horror drama]\",\"genres[1]\":\"please use the following permitted genres [action adventure comedy horror drama]\",\"runtime\":\"should be a positive number\",\"year\":\"should not be empty\"}}\n"},
+		{"empty title test", "1", `{"title":""}`, nil, http.StatusUnprocessableEntity, []string{"GetMovie"}, "{\"error\":{\"title\":\"should not be empty\"}}\n"},
+		{"long title test", "1", `{"title":"` + strings.Repeat("x", 501) + `"}`, nil, http.StatusUnprocessableEntity, []string{"GetMovie"}, "{\"error\":{\"title\":\"should not be greater than 500 bytes\"}}\n"},
+		{"too many genres test", "1", `{"genres":["action","adventure","comedy","horror","drama","action"]}`, nil, http.StatusUnprocessableEntity, []string{"GetMovie"}, "{\"error\":{\"genres\":\"should not contain more than 5 genres\"}}\n"},
+		{"edit conflict test", "1", valid, failing("UpdateMovie", data.ErrEditConflict), http.StatusConflict, []string{"GetMovie", "UpdateMovie"}, "{\"error\":\"unable to update the record due to an edit conflict, please try again\"}\n"},
+		{"server error test", "1", valid, failing("GetMovie", errTestStore), http.StatusInternalServerError, []string{"GetMovie"}, serverErrorResponse},
 	}
 	for _, e := range tests {
-		req, _ := http.NewRequest("PATCH", "/v1/movies/1", strings.NewReader(e.body))
-		chiCtx := chi.NewRouteContext()
-		chiCtx.URLParams.Add("id", e.id)
-		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
-		req.Header.Set("Content-Type", "application/json")
+		movies := newTestMovies(t)
+		if e.setup != nil {
+			e.setup(movies)
+		}
+		rr := serveMovies(movies, "PATCH", "/v1/movies/"+e.id, e.body)
 
-		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(testApp.updateMovieHandler)
-		handler.ServeHTTP(rr, req)
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+
+		if e.expectedResponse != rr.Body.String() {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
+		}
+		checkMovieCalls(t, e.name, movies, e.expectedCalls)
+	}
+}
+
+func TestUpdateMovieHandlerSendsVersion(t *testing.T) {
+	movies := newTestMovies(t)
+	serveMovies(movies, "PATCH", "/v1/movies/1", `{"title":"first"}`)
+	serveMovies(movies, "PATCH", "/v1/movies/1", `{"title":"second"}`)
+
+	updates := movies.Calls("UpdateMovie")
+	if len(updates) != 2 {
+		t.Fatalf("expected 2 updates but got %d", len(updates))
+	}
+	for i, title := range []string{"first", "second"} {
+		movie := updates[i].Movie
+		if movie.ID != 1 || movie.Title != title || movie.Version != int32(i+1) {
+			t.Errorf("expected update %d to send %s at version %d but got %+v", i+1, title, i+1, *movie)
+		}
+	}
+}
+
+func TestReplaceMovieHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		id               string
+		body             string
+		setup            func(*datatest.FakeMovies)
+		expectedStatus   int
+		expectedLocation string
+		expectedCalls    []string
+		expectedResponse string
+	}{
+		{"replaced test", "1", `{"id":1,"title":"new test","runtime":150,"year":2021,"genres":["action"]}`, nil, http.StatusOK, "", []string{"UpsertMovie"}, "{\"movie\":{\"id\":1,\"title\":\"new test\",\"runtime\":150,\"year\":2021,\"genres\":[\"action\"]}}\n"},
+		{"created test", "7", `{"title":"new test","runtime":150,"year":2021,"genres":["action"]}`, nil, http.StatusCreated, "/v1/movies/7", []string{"UpsertMovie"}, "{\"movie\":{\"id\":7,\"title\":\"new test\",\"runtime\":150,\"year\":2021,\"genres\":[\"action\"]}}\n"},
+		{"incomplete test", "1", `{"title":"new test"}`, nil, http.StatusUnprocessableEntity, "", nil, "{\"error\":{\"genres\":\"should not be empty\",\"runtime\":\"should not be empty\",\"year\":\"should not be empty\"}}\n"},
+		{"id mismatch test", "1", `{"id":2,"title":"new test","runtime":150,"year":2021,"genres":["action"]}`, nil, http.StatusUnprocessableEntity, "", nil, "{\"error\":{\"id\":\"must match the id in the URL\"}}\n"},
+		{"id too large test", "9007199254740992", `{"title":"new test","runtime":150,"year":2021,"genres":["action"]}`, nil, http.StatusUnprocessableEntity, "", nil, "{\"error\":{\"id\":\"should not be greater than 9007199254740991\"}}\n"},
+		{"external id changed test", "2", `{"external_id":"tt0000009","title":"new test","runtime":150,"year":2021,"genres":["action"]}`, nil, http.StatusUnprocessableEntity, "", []string{"GetMovie"}, "{\"error\":{\"external_id\":\"cannot be changed\"}}\n"},
+		{"duplicate test", "7", `{"external_id":"tt0000002","title":"new test","runtime":150,"year":2021,"genres":["action"]}`, nil, http.StatusConflict, "", []string{"GetMovie", "UpsertMovie"}, "{\"error\":\"a different movie already has this external_id\"}\n"},
+		{"invalid id test", "abc", `{"title":"new test","runtime":150,"year":2021,"genres":["action"]}`, nil, http.StatusNotFound, "", nil, notFoundResponse},
+		{"server error test", "1", `{"title":"new test","runtime":150,"year":2021,"genres":["action"]}`, failing("UpsertMovie", errTestStore), http.StatusInternalServerError, "", []string{"UpsertMovie"}, serverErrorResponse},
+	}
+	for _, e := range tests {
+		movies := newTestMovies(t)
+		if e.setup != nil {
+			e.setup(movies)
+		}
+		rr := serveMovies(movies, "PUT", "/v1/movies/"+e.id, e.body)
 
 		if e.expectedStatus != rr.Code {
 			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
 		}
 
+		if e.expectedLocation != rr.Header().Get("Location") {
+			t.Errorf("%s: expected location %q but got %q", e.name, e.expectedLocation, rr.Header().Get("Location"))
+		}
+
 		if e.expectedResponse != rr.Body.String() {
 			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
 		}
+		checkMovieCalls(t, e.name, movies, e.expectedCalls)
+	}
+}
 
+func TestReplaceExternalMovieHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		externalID       string
+		body             string
+		setup            func(*datatest.FakeMovies)
+		expectedStatus   int
+		expectedCalls    []string
+		expectedResponse string
+	}{
+		{"replaced test", "tt0000001", `{"title":"new test","runtime":150,"year":2021,"genres":["action"]}`, nil, http.StatusOK, []string{"UpsertMovie"}, "{\"movie\":{\"id\":2,\"title\":\"new test\",\"runtime\":150,\"year\":2021,\"genres\":[\"action\"],\"external_id\":\"tt0000001\"}}\n"},
+		{"created test", "tt0000003", `{"external_id":"tt0000003","title":"new test","runtime":150,"year":2021,"genres":["action"]}`, nil, http.StatusCreated, []string{"UpsertMovie"}, "{\"movie\":{\"id\":4,\"title\":\"new test\",\"runtime\":150,\"year\":2021,\"genres\":[\"action\"],\"external_id\":\"tt0000003\"}}\n"},
+		{"escaped test", "tt%2F0000003", `{"title":"new test","runtime":150,"year":2021,"genres":["action"]}`, nil, http.StatusCreated, []string{"UpsertMovie"}, "{\"movie\":{\"id\":4,\"title\":\"new test\",\"runtime\":150,\"year\":2021,\"genres\":[\"action\"],\"external_id\":\"tt/0000003\"}}\n"},
+		{"external id mismatch test", "tt0000001", `{"external_id":"tt0000003","title":"new test","runtime":150,"year":2021,"genres":["action"]}`, nil, http.StatusUnprocessableEntity, nil, "{\"error\":{\"external_id\":\"must match the external_id in the URL\"}}\n"},
+		{"duplicate test", "tt0000003", `{"title":"new test","runtime":150,"year":2021,"genres":["action"]}`, failing("UpsertMovie", data.ErrDuplicateExternalID), http.StatusConflict, []string{"UpsertMovie"}, "{\"error\":\"a different movie already has this external_id\"}\n"},
+		{"server error test", "tt0000001", `{"title":"new test","runtime":150,"year":2021,"genres":["action"]}`, failing("UpsertMovie", errTestStore), http.StatusInternalServerError, []string{"UpsertMovie"}, serverErrorResponse},
+	}
+	for _, e := range tests {
+		movies := newTestMovies(t)
+		if e.setup != nil {
+			e.setup(movies)
+		}
+		rr := serveMovies(movies, "PUT", "/v1/movies/external/"+e.externalID, e.body)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+
+		if e.expectedResponse != rr.Body.String() {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
+		}
+		checkMovieCalls(t, e.name, movies, e.expectedCalls)
 	}
 }
 
@@ -110,23 +299,23 @@ func TestDeleteMovieHandler(t *testing.T) {
 	tests := []struct {
 		name             string
 		id               string
+		setup            func(*datatest.FakeMovies)
 		expectedStatus   int
+		expectedCalls    []string
 		expectedResponse string
 	}{
-		{"valid test", "1", http.StatusOK, "{\"message\":\"movie with the id 1 has been deleted\"}\n"},
-		{"not found test", "0", http.StatusNotFound, "{\"error\":\"the requested resource could not be found\"}\n"},
-		{"non valid id should return not found test", "asd", http.StatusNotFound, "{\"error\":\"the requested resource could not be found\"}\n"},
-		{"should fail test", "2", http.StatusInternalServerError, "{\"error\":\"the server encountered a problem and could not process your request\"}\n"},
+		{"valid test", "1", nil, http.StatusOK, []string{"DeleteMovie"}, "{\"message\":\"movie with the id 1 has been deleted\"}\n"},
+		{"not found test", "42", nil, http.StatusNotFound, []string{"DeleteMovie"}, notFoundResponse},
+		{"non valid id should return not found test", "asd", nil, http.StatusNotFound, nil, notFoundResponse},
+		{"should fail test", "1", failing("DeleteMovie", errTestStore), http.StatusInternalServerError, []string{"DeleteMovie"}, serverErrorResponse},
 	}
 
 	for _, e := range tests {
-		req, _ := http.NewRequest("DELETE", "/v1/movies/1", nil)
-		chiCtx := chi.NewRouteContext()
-		chiCtx.URLParams.Add("id", e.id)
-		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
-		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(testApp.deleteMovieHandler)
-		handler.ServeHTTP(rr, req)
+		movies := newTestMovies(t)
+		if e.setup != nil {
+			e.setup(movies)
+		}
+		rr := serveMovies(movies, "DELETE", "/v1/movies/"+e.id, "")
 
 		if e.expectedStatus != rr.Code {
 			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
@@ -134,23 +323,60 @@ func TestDeleteMovieHandler(t *testing.T) {
 		if e.expectedResponse != rr.Body.String() {
 			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
 		}
+		checkMovieCalls(t, e.name, movies, e.expectedCalls)
+	}
+}
+
+func TestDeleteMovieHandlerRemovesMovie(t *testing.T) {
+	movies := newTestMovies(t)
+	serveMovies(movies, "DELETE", "/v1/movies/2", "")
+	rr := serveMovies(movies, "GET", "/v1/movies/2", "")
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected %d after deleting but got %d", http.StatusNotFound, rr.Code)
+	}
+	deletes := movies.Calls("DeleteMovie")
+	if len(deletes) != 1 || deletes[0].ID != 2 {
+		t.Errorf("expected the id from the URL to be deleted but got %+v", deletes)
+	}
+}
+
+func TestDeleteMovieHandlerLanguage(t *testing.T) {
+	app := testApp
+	app.models = newTestModels()
+	app.models.Movies = newTestMovies(t)
+	req := httptest.NewRequest("DELETE", "/v1/movies/1", nil)
+	req.Header.Set("Accept-Language", "fr")
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	expected := "{\"message\":\"le film avec l'id 1 a été supprimé\"}\n"
+	if rr.Body.String() != expected {
+		t.Errorf("expected %s but got %s", expected, rr.Body.String())
+	}
+	if rr.Header().Get("Content-Language") != "fr" {
+		t.Errorf("expected language fr but got %s", rr.Header().Get("Content-Language"))
 	}
 }
 
 func TestGetAllMoviesHandler(t *testing.T) {
 	tests := []struct {
 		name             string
+		movies           *datatest.FakeMovies
+		setup            func(*datatest.FakeMovies)
 		expectedStatus   int
 		expectedResponse string
 	}{
-		{"valid test", http.StatusOK, "{\"movies\":[{\"id\":1,\"title\":\"test movie 1\",\"runtime\":100,\"year\":2020,\"genres\":[\"action\"]},{\"id\":2,\"title\":\"test movie 2\",\"runtime\":100,\"year\":2020,\"genres\":[\"adventure\"]}]}\n"},
+		{"valid test", datatest.NewFakeMovies(t, &data.Movie{Title: "test movie 1", Runtime: 100, Year: 2020, Genres: []string{"action"}}, &data.Movie{Title: "test movie 2", Runtime: 100, Year: 2020, Genres: []string{"adventure"}}), nil, http.StatusOK, "{\"movies\":[{\"id\":1,\"title\":\"test movie 1\",\"runtime\":100,\"year\":2020,\"genres\":[\"action\"]},{\"id\":2,\"title\":\"test movie 2\",\"runtime\":100,\"year\":2020,\"genres\":[\"adventure\"]}]}\n"},
+		{"empty test", datatest.NewFakeMovies(t), nil, http.StatusOK, "{\"movies\":[]}\n"},
+		{"server error test", datatest.NewFakeMovies(t), failing("GetAllMovies", errTestStore), http.StatusInternalServerError, serverErrorResponse},
 	}
 
 	for _, e := range tests {
-		req, _ := http.NewRequest("GET", "/v1/movies", nil)
-		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(testApp.getAllMoviesHandler)
-		handler.ServeHTTP(rr, req)
+		if e.setup != nil {
+			e.setup(e.movies)
+		}
+		rr := serveMovies(e.movies, "GET", "/v1/movies", "")
 
 		if e.expectedStatus != rr.Code {
 			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
@@ -159,5 +385,6 @@ func TestGetAllMoviesHandler(t *testing.T) {
 		if e.expectedResponse != rr.Body.String() {
 			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
 		}
+		checkMovieCalls(t, e.name, e.movies, []string{"GetAllMovies"})
 	}
 }
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"embed"
+	"net/http"
+)
+
+//go:embed openapi/openapi.json openapi/docs.html
+var openAPIFiles embed.FS
+
+func (app *application) openAPISpecHandler(w http.ResponseWriter, r *http.Request) {
+	app.serveOpenAPIFile(w, r, "openapi/openapi.json", "application/json")
+}
+
+func (app *application) apiDocsHandler(w http.ResponseWriter, r *http.Request) {
+	app.serveOpenAPIFile(w, r, "openapi/docs.html", "text/html; charset=utf-8")
+}
+
+func (app *application) serveOpenAPIFile(w http.ResponseWriter, r *http.Request, name, contentType string) {
+	content, err := openAPIFiles.ReadFile(name)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
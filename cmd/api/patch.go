@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"github.com/rrebeiz/quickmovies/internal/i18n"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const jsonPatchType = "application/json-patch+json"
+
+var (
+	// ErrPatchTestFailed is returned when a JSON Patch test operation does
+	// not match the current movie.
+	ErrPatchTestFailed = errors.New("patch test operation failed")
+)
+
+// patchError reports a well-formed patch that cannot be applied to the
+// current document, as a catalogue message. Its Error is the English text.
+type patchError struct {
+	message i18n.Message
+}
+
+func (e *patchError) Error() string {
+	return e.message.Text(i18n.DefaultLanguage)
+}
+
+func newPatchError(key string, args ...any) error {
+	return &patchError{message: i18n.Message{Key: key, Args: args}}
+}
+
+// patch is a PATCH request body. readJSON fills in operations for
+// application/json-patch+json (RFC 6902), and merge for every other body,
+// which is applied as a JSON Merge Patch (RFC 7396). Plain application/json
+// bodies are treated as merge patches too, which keeps the partial updates
+// clients already send working.
+type patch struct {
+	operations []patchOperation
+	merge      any
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+	From  string          `json:"from"`
+}
+
+// target returns what readJSON should decode the body of r into for p.
+func (p *patch) target(r *http.Request) any {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == jsonPatchType {
+		return &p.operations
+	}
+	return &p.merge
+}
+
+// apply applies the patch to doc, a decoded JSON document.
+func (p *patch) apply(doc any) (any, error) {
+	if p.operations == nil {
+		return mergePatch(doc, p.merge), nil
+	}
+	var err error
+	for i, op := range p.operations {
+		doc, err = op.apply(doc)
+		if err != nil {
+			var patchErr *patchError
+			if !errors.As(err, &patchErr) {
+				return nil, err
+			}
+			return nil, newPatchError("error.patch_operation", i, patchErr.message)
+		}
+	}
+	return doc, nil
+}
+
+// mergePatch implements the MergePatch function of RFC 7396.
+func mergePatch(target, patch any) any {
+	fields, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	doc, ok := target.(map[string]any)
+	if !ok {
+		doc = make(map[string]any)
+	}
+	for name, value := range fields {
+		if value == nil {
+			delete(doc, name)
+		} else {
+			doc[name] = mergePatch(doc[name], value)
+		}
+	}
+	return doc
+}
+
+func (op patchOperation) apply(doc any) (any, error) {
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, newPatchError("error.patch_value_required", op.Op)
+		}
+		err = json.Unmarshal(op.Value, &value)
+		if err != nil {
+			return nil, newPatchError("error.patch_invalid_value", err.Error())
+		}
+	case "remove":
+	default:
+		return nil, newPatchError("error.patch_unsupported_op", op.Op)
+	}
+
+	if op.Op == "test" {
+		current, err := resolvePointer(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, ErrPatchTestFailed
+		}
+		return doc, nil
+	}
+	return patchPointer(doc, tokens, op.Op, value)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, newPatchError("error.patch_path_start", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func resolvePointer(doc any, tokens []string) (any, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, newPatchError("error.patch_path_missing", token)
+			}
+			doc = value
+		case []any:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, newPatchError("error.patch_path_missing", token)
+		}
+	}
+	return doc, nil
+}
+
+// patchPointer adds, replaces or removes the value at tokens, returning the
+// updated document. Arrays are copied since adding and removing elements
+// changes their length.
+func patchPointer(doc any, tokens []string, op string, value any) (any, error) {
+	if len(tokens) == 0 {
+		if op == "remove" {
+			return nil, newPatchError("error.patch_remove_document")
+		}
+		return value, nil
+	}
+
+	token := tokens[0]
+	last := len(tokens) == 1
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[token]
+		if last {
+			if !ok && op != "add" {
+				return nil, newPatchError("error.patch_path_missing", token)
+			}
+			if op == "remove" {
+				delete(node, token)
+			} else {
+				node[token] = value
+			}
+			return node, nil
+		}
+		if !ok {
+			return nil, newPatchError("error.patch_path_missing", token)
+		}
+		child, err := patchPointer(child, tokens[1:], op, value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+	case []any:
+		if last && op == "add" {
+			i := len(node)
+			if token != "-" {
+				var err error
+				i, err = arrayIndex(token, len(node))
+				if err != nil {
+					return nil, err
+				}
+			}
+			result := make([]any, 0, len(node)+1)
+			result = append(result, node[:i]...)
+			result = append(result, value)
+			return append(result, node[i:]...), nil
+		}
+		i, err := arrayIndex(token, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			if op == "remove" {
+				result := make([]any, 0, len(node)-1)
+				result = append(result, node[:i]...)
+				return append(result, node[i+1:]...), nil
+			}
+			node[i] = value
+			return node, nil
+		}
+		child, err := patchPointer(node[i], tokens[1:], op, value)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	default:
+		return nil, newPatchError("error.patch_path_missing", token)
+	}
+}
+
+// arrayIndex parses an array index token that must be between 0 and max.
+func arrayIndex(token string, max int) (int, error) {
+	if token == "-" {
+		return 0, newPatchError("error.patch_append_only")
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || (len(token) > 1 && token[0] == '0') || i < 0 {
+		return 0, newPatchError("error.patch_invalid_index", token)
+	}
+	if i > max {
+		return 0, newPatchError("error.patch_index_out_of_range", i)
+	}
+	return i, nil
+}
+
+// patchMovie applies p to the JSON representation of movie and copies the
+// result back into movie.
+func patchMovie(movie *data.Movie, p *patch) error {
+	js, err := json.Marshal(movie)
+	if err != nil {
+		return err
+	}
+	var doc any
+	err = json.Unmarshal(js, &doc)
+	if err != nil {
+		return err
+	}
+
+	doc, err = p.apply(doc)
+	if err != nil {
+		return err
+	}
+
+	js, err = json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var patched struct {
+		ID         int64    `json:"id"`
+		ExternalID string   `json:"external_id"`
+		Title      string   `json:"title"`
+		Runtime    int32    `json:"runtime"`
+		Year       int32    `json:"year"`
+		Genres     []string `json:"genres"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&patched)
+	if err != nil {
+		var unmarshalTypeError *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &unmarshalTypeError) && unmarshalTypeError.Field != "":
+			return newPatchError("error.patched_incorrect_type", unmarshalTypeError.Field)
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return newPatchError("error.patched_unknown_key", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		default:
+			return newPatchError("error.patched_not_object")
+		}
+	}
+	if patched.ID != movie.ID {
+		return newPatchError("error.patched_id_changed")
+	}
+	if patched.ExternalID != movie.ExternalID {
+		return newPatchError("error.patched_external_id_changed")
+	}
+
+	movie.Title = patched.Title
+	movie.Runtime = patched.Runtime
+	movie.Year = patched.Year
+	movie.Genres = patched.Genres
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUpdateMovieHandlerPatchFormats(t *testing.T) {
+	tests := []struct {
+		name             string
+		contentType      string
+		body             string
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{"merge patch test", "application/merge-patch+json", `{"title":"new test","genres":["drama"]}`, http.StatusOK, "{\"movie\":{\"id\":1,\"title\":\"new test\",\"runtime\":100,\"year\":2020,\"genres\":[\"drama\"]}}\n"},
+		{"merge patch clear genres test", "application/merge-patch+json", `{"genres":null}`, http.StatusUnprocessableEntity, "{\"error\":{\"genres\":\"should not be empty\"}}\n"},
+		{"merge patch id test", "application/merge-patch+json", `{"id":5}`, http.StatusUnprocessableEntity, "{\"error\":\"the movie id cannot be changed\"}\n"},
+		{"merge patch unknown key test", "application/merge-patch+json", `{"rating":5}`, http.StatusUnprocessableEntity, "{\"error\":\"patched movie contains unknown key \\\"rating\\\"\"}\n"},
+		{"json patch append test", "application/json-patch+json", `[{"op":"test","path":"/title","value":"test"},{"op":"add","path":"/genres/-","value":"drama"}]`, http.StatusOK, "{\"movie\":{\"id\":1,\"title\":\"test\",\"runtime\":100,\"year\":2020,\"genres\":[\"action\",\"adventure\",\"drama\"]}}\n"},
+		{"json patch insert and remove test", "application/json-patch+json", `[{"op":"add","path":"/genres/0","value":"comedy"},{"op":"remove","path":"/genres/2"},{"op":"replace","path":"/runtime","value":90}]`, http.StatusOK, "{\"movie\":{\"id\":1,\"title\":\"test\",\"runtime\":90,\"year\":2020,\"genres\":[\"comedy\",\"action\"]}}\n"},
+		{"json patch failed test test", "application/json-patch+json", `[{"op":"test","path":"/year","value":1999},{"op":"replace","path":"/year","value":2000}]`, http.StatusConflict, "{\"error\":\"the movie does not match the test operation of the patch\"}\n"},
+		{"json patch missing path test", "application/json-patch+json", `[{"op":"replace","path":"/rating","value":5}]`, http.StatusUnprocessableEntity, "{\"error\":\"operation 0: path member \\\"rating\\\" does not exist\"}\n"},
+		{"json patch out of range test", "application/json-patch+json", `[{"op":"remove","path":"/genres/5"}]`, http.StatusUnprocessableEntity, "{\"error\":\"operation 0: array index 5 is out of range\"}\n"},
+		{"json patch unsupported op test", "application/json-patch+json", `[{"op":"move","from":"/title","path":"/genres/0"}]`, http.StatusUnprocessableEntity, "{\"error\":\"operation 0: unsupported op \\\"move\\\", expected add, remove, replace or test\"}\n"},
+		{"json patch validation test", "application/json-patch+json", `[{"op":"add","path":"/genres/-","value":"action"}]`, http.StatusUnprocessableEntity, "{\"error\":{\"genres\":\"must not contain duplicate genres\"}}\n"},
+		{"json patch type test", "application/json-patch+json", `[{"op":"replace","path":"/runtime","value":"long"}]`, http.StatusUnprocessableEntity, "{\"error\":\"patched movie contains incorrect JSON type for field \\\"runtime\\\"\"}\n"},
+		{"json patch not an array test", "application/json-patch+json", `{"title":"new test"}`, http.StatusBadRequest, "{\"error\":\"body contains incorrect JSON type (at character 1)\"}\n"},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("PATCH", "/v1/movies/1", strings.NewReader(e.body))
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		req.Header.Set("Content-Type", e.contentType)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(testApp.updateMovieHandler)
+		handler.ServeHTTP(rr, req)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+
+		if e.expectedResponse != rr.Body.String() {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expectedResponse, rr.Body.String())
+		}
+	}
+}
@@ -6,12 +6,45 @@ import (
 )
 
 func (app *application) routes() http.Handler {
+	schema, err := app.newGraphQLSchema()
+	if err != nil {
+		// the schema is static, so this is a programming error.
+		panic(err)
+	}
+
 	router := chi.NewRouter()
-	router.Get("/v1/healthcheck", app.healthCheckHandler)
-	router.Get("/v1/movies/{id}", app.getMovieHandler)
-	router.Get("/v1/movies", app.getAllMoviesHandler)
-	router.Post("/v1/movies", app.createMovieHandler)
-	router.Patch("/v1/movies/{id}", app.updateMovieHandler)
-	router.Delete("/v1/movies/{id}", app.deleteMovieHandler)
+	router.NotFound(app.notFoundResponse)
+	router.MethodNotAllowed(app.methodNotAllowedResponse)
+	router.Use(app.authenticate)
+	router.Use(app.identifyClient)
+	router.Get("/v1/openapi.json", app.openAPISpecHandler)
+	router.Get("/v1/docs", app.apiDocsHandler)
+	router.Get("/v1/movies/events", app.movieEventsHandler)
+	router.Group(func(router chi.Router) {
+		router.Use(app.negotiate(responseFormats...))
+		router.Get("/v1/healthcheck", app.healthCheckHandler)
+		router.Get("/v1/health/live", app.liveHandler)
+		router.Get("/v1/health/ready", app.readyHandler)
+		router.Get("/v1/metrics/cache", app.requirePermission("metrics:read", app.cacheStatsHandler))
+		router.Get("/v1/movies/{id}", app.getMovieHandler)
+		router.Post("/v1/movies", app.idempotent(app.createMovieHandler))
+		router.Put("/v1/movies/{id}", app.replaceMovieHandler)
+		router.Put("/v1/movies/external/{externalID}", app.replaceExternalMovieHandler)
+		router.Patch("/v1/movies/{id}", app.updateMovieHandler)
+		router.Delete("/v1/movies/{id}", app.deleteMovieHandler)
+		router.Post("/v1/webhooks", app.requirePermission("webhooks:write", app.createWebhookHandler))
+		router.Get("/v1/webhooks/{id}", app.requirePermission("webhooks:read", app.getWebhookHandler))
+		router.Patch("/v1/webhooks/{id}", app.requirePermission("webhooks:write", app.updateWebhookHandler))
+		router.Delete("/v1/webhooks/{id}", app.requirePermission("webhooks:write", app.deleteWebhookHandler))
+	})
+	// lists can also be downloaded as CSV.
+	router.Group(func(router chi.Router) {
+		router.Use(app.negotiate(listFormats...))
+		router.Get("/v1/movies", app.getAllMoviesHandler)
+		router.Get("/v1/webhooks", app.requirePermission("webhooks:read", app.getAllWebhooksHandler))
+		router.Get("/v1/webhooks/{id}/deliveries", app.requirePermission("webhooks:read", app.getWebhookDeliveriesHandler))
+	})
+	router.Post("/v1/graphql", app.graphQLHandler(schema))
+	router.Get("/v1/graphql", app.graphiQLHandler)
 	return router
 }
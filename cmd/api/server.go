@@ -1,8 +1,17 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -14,5 +23,139 @@ func (app *application) serve() error {
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  10 * time.Second,
 	}
-	return srv.ListenAndServe()
+	var redirect *http.Server
+	var grpcCreds credentials.TransportCredentials
+	if app.config.useTLS() {
+		getCertificate, err := app.certificateSource()
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = newTLSConfig(getCertificate)
+		grpcCreds = credentials.NewTLS(newTLSConfig(getCertificate))
+
+		if app.config.tls.redirectPort != 0 {
+			redirect = &http.Server{
+				Addr:         fmt.Sprintf(":%d", app.config.tls.redirectPort),
+				Handler:      app.redirectToHTTPS(getCertificate),
+				ReadTimeout:  5 * time.Second,
+				WriteTimeout: 5 * time.Second,
+				IdleTimeout:  10 * time.Second,
+			}
+		}
+	}
+
+	var grpcSrv *grpc.Server
+	var grpcListener net.Listener
+	if app.config.grpc.port != 0 {
+		var err error
+		grpcListener, err = net.Listen("tcp", fmt.Sprintf(":%d", app.config.grpc.port))
+		if err != nil {
+			return err
+		}
+		grpcSrv = app.newGRPCServer(grpcCreds)
+	}
+
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+		app.infoLog.Printf("shutting down the server, signal %s", s)
+
+		// the readiness probe fails from here on, and the server keeps
+		// serving for shutdownDelay so load balancers stop routing to it
+		// before it closes its listeners.
+		close(app.shutdown)
+		if app.config.shutdownDelay > 0 {
+			app.infoLog.Printf("waiting %s before closing the listeners", app.config.shutdownDelay)
+			time.Sleep(app.config.shutdownDelay)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if redirect != nil {
+			_ = redirect.Shutdown(ctx)
+		}
+		if grpcSrv != nil {
+			stopGRPC(ctx, grpcSrv)
+		}
+		shutdownError <- srv.Shutdown(ctx)
+	}()
+
+	if redirect != nil {
+		go func() {
+			app.infoLog.Printf("redirecting http on port %d to https", app.config.tls.redirectPort)
+			err := redirect.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.errorLog.Printf("http redirect server failed %s", err)
+			}
+		}()
+	}
+
+	if grpcSrv != nil {
+		go func() {
+			app.infoLog.Printf("starting the grpc server on port %d", app.config.grpc.port)
+			err := grpcSrv.Serve(grpcListener)
+			if err != nil {
+				app.errorLog.Printf("grpc server failed %s", err)
+			}
+		}()
+	}
+
+	var err error
+	if srv.TLSConfig != nil {
+		app.infoLog.Printf("starting the %s server on port %d with tls", app.config.env, app.config.port)
+		// the certificate comes from TLSConfig.GetCertificate.
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		app.infoLog.Printf("starting the %s server on port %d", app.config.env, app.config.port)
+		err = srv.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+	app.infoLog.Println("server stopped")
+	return nil
+}
+
+// stopGRPC waits for in-flight RPCs to finish, and cancels them once ctx is
+// done.
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+	}
+}
+
+// certificateSource returns the GetCertificate callback for the configured
+// certificate files, or a self-signed certificate in develop mode.
+func (app *application) certificateSource() (func(*tls.ClientHelloInfo) (*tls.Certificate, error), error) {
+	if app.config.tls.selfSigned {
+		cert, err := selfSignedCertificate()
+		if err != nil {
+			return nil, err
+		}
+		app.infoLog.Println("serving a generated self-signed certificate")
+		return func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return cert, nil
+		}, nil
+	}
+
+	reloader, err := newCertReloader(app.config.tls.certFile, app.config.tls.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return reloader.GetCertificate, nil
 }
@@ -2,8 +2,12 @@ package main
 
 import (
 	"github.com/rrebeiz/quickmovies/internal/data"
+	"github.com/rrebeiz/quickmovies/internal/feed"
+	"io"
+	"log"
 	"os"
 	"testing"
+	"time"
 )
 
 var testApp application
@@ -14,6 +18,11 @@ func TestMain(m *testing.M) {
 	testConfig.port = 4000
 	testApp.config = testConfig
 	testApp.models = newTestModels()
+	testApp.infoLog = log.New(io.Discard, "", 0)
+	testApp.errorLog = log.New(io.Discard, "", 0)
+	testApp.feed = feed.NewBroker()
+	testApp.startedAt = time.Now()
+	testApp.shutdown = make(chan struct{})
 
 	os.Exit(m.Run())
 
@@ -21,6 +30,10 @@ func TestMain(m *testing.M) {
 
 func newTestModels() data.Models {
 	return data.Models{
-		Movies: data.NewMockMovieModel(),
+		Movies:          data.NewMockMovieModel(),
+		Events:          data.NewMemoryEventModel(data.NewMemoryMovieModel()),
+		Webhooks:        data.NewMemoryWebhookModel(data.NewMemoryMovieModel()),
+		IdempotencyKeys: data.NewMemoryIdempotencyKeyModel(),
+		Users:           testUsers{},
 	}
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeKeyPair(t *testing.T, certFile, keyFile string, modTime time.Time) []byte {
+	cert, err := selfSignedCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: cert.Certificate[0]},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: key},
+	}
+	for file, block := range files {
+		err = os.WriteFile(file, pem.EncodeToMemory(block), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(file, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cert.Certificate[0]
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	first := writeKeyPair(t, certFile, keyFile, time.Now().Add(-time.Hour))
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := reloader.GetCertificate(nil)
+	if !bytes.Equal(cert.Certificate[0], first) {
+		t.Error("expected the certificate on disk to be served")
+	}
+
+	second := writeKeyPair(t, certFile, keyFile, time.Now())
+	reloader.lastCheck = time.Time{}
+	cert, _ = reloader.GetCertificate(nil)
+	if !bytes.Equal(cert.Certificate[0], second) {
+		t.Error("expected the renewed certificate to be served")
+	}
+
+	err = os.WriteFile(certFile, []byte("not a certificate"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader.lastCheck = time.Time{}
+	cert, _ = reloader.GetCertificate(nil)
+	if !bytes.Equal(cert.Certificate[0], second) {
+		t.Error("expected a broken certificate file to keep the previous certificate")
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	cert, err := selfSignedCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect := testApp.redirectToHTTPS(func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return cert, nil
+	})
+
+	tests := []struct {
+		name             string
+		host             string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{"host test", "localhost", http.StatusPermanentRedirect, "https://localhost:4000/v1/movies?page=2"},
+		{"host and port test", "localhost:80", http.StatusPermanentRedirect, "https://localhost:4000/v1/movies?page=2"},
+		{"ip test", "127.0.0.1:80", http.StatusPermanentRedirect, "https://127.0.0.1:4000/v1/movies?page=2"},
+		{"other host test", "evil.example.com", http.StatusMisdirectedRequest, ""},
+		{"other host and port test", "evil.example.com:80", http.StatusMisdirectedRequest, ""},
+	}
+
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/v1/movies?page=2", nil)
+		req.Host = e.host
+		rr := httptest.NewRecorder()
+		redirect.ServeHTTP(rr, req)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if e.expectedLocation != rr.Header().Get("Location") {
+			t.Errorf("%s: expected Location %q but got %q", e.name, e.expectedLocation, rr.Header().Get("Location"))
+		}
+	}
+}
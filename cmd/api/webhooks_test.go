@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookHandlers(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"create test", "POST", "/v1/webhooks", `{"url":"https://93.184.216.34/hook","events":["movie.created"],"secret":"0123456789abcdef"}`, http.StatusCreated, `"secret":"0123456789abcdef"`},
+		{"create generated secret test", "POST", "/v1/webhooks", `{"url":"https://93.184.216.34/hook","events":["movie.deleted"]}`, http.StatusCreated, `"secret":"`},
+		{"invalid create test", "POST", "/v1/webhooks", `{"url":"example.com","events":["movie.watched"],"secret":"short"}`, http.StatusUnprocessableEntity, `{"error":{"events":"please use the following events [movie.created movie.updated movie.deleted]","secret":"should be at least 16 bytes long","url":"should be an absolute http or https URL"}}`},
+		{"loopback test", "POST", "/v1/webhooks", `{"url":"http://127.0.0.1:8080/hook","events":["movie.created"]}`, http.StatusUnprocessableEntity, `{"error":{"url":"must resolve to a public address"}}`},
+		{"metadata service test", "POST", "/v1/webhooks", `{"url":"http://169.254.169.254/latest/meta-data","events":["movie.created"]}`, http.StatusUnprocessableEntity, `{"error":{"url":"must resolve to a public address"}}`},
+		{"get test", "GET", "/v1/webhooks/1", ``, http.StatusOK, `{"webhook":{"id":1,"url":"https://93.184.216.34/hook","events":["movie.created"],"active":true,"failure_count":0,`},
+		{"list test", "GET", "/v1/webhooks", ``, http.StatusOK, `"id":2`},
+		{"private update test", "PATCH", "/v1/webhooks/1", `{"url":"http://10.0.0.1/hook"}`, http.StatusUnprocessableEntity, `{"error":{"url":"must resolve to a public address"}}`},
+		{"update test", "PATCH", "/v1/webhooks/1", `{"active":false}`, http.StatusOK, `"active":false`},
+		{"deliveries test", "GET", "/v1/webhooks/1/deliveries", ``, http.StatusOK, `{"deliveries":[]}`},
+		{"delete test", "DELETE", "/v1/webhooks/1", ``, http.StatusOK, `{"message":"webhook with the id 1 has been deleted"}`},
+		{"not found test", "GET", "/v1/webhooks/1", ``, http.StatusNotFound, `{"error":"the requested resource could not be found"}`},
+	}
+
+	app := testApp
+	app.models = newTestModels()
+	routes := app.routes()
+
+	for _, e := range tests {
+		req, _ := http.NewRequest(e.method, e.url, strings.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+writerToken)
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if e.expectedStatus != rr.Code {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+
+		if !strings.Contains(rr.Body.String(), e.expectedBody) {
+			t.Errorf("%s: expected %s in %s", e.name, e.expectedBody, rr.Body.String())
+		}
+		if strings.Contains(rr.Body.String(), "secret") && e.method != "POST" {
+			t.Errorf("%s: expected the secret to be hidden but got %s", e.name, rr.Body.String())
+		}
+	}
+}
+
+func TestWebhookAllowPrivate(t *testing.T) {
+	app := testApp
+	app.config.webhooks.allowPrivate = true
+	app.models = newTestModels()
+
+	req, _ := http.NewRequest("POST", "/v1/webhooks", strings.NewReader(`{"url":"http://127.0.0.1:8080/hook","events":["movie.created"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+writerToken)
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected %d but got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+}
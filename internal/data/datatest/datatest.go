@@ -0,0 +1,120 @@
+// Package datatest checks that implementations of the data interfaces behave
+// the same, and opens the test databases to run them against.
+package datatest
+
+import (
+	"database/sql"
+	"fmt"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// migrationsDir returns the migrations directory of the repository, found
+// relative to this file so it works from any package.
+func migrationsDir(driver string) string {
+	_, file, _, _ := runtime.Caller(0)
+	dir := filepath.Join(filepath.Dir(file), "..", "..", "..", "migrations")
+	if driver == "sqlite" {
+		dir = filepath.Join(dir, "sqlite")
+	}
+	return dir
+}
+
+// migrate applies every up migration of driver to db.
+func migrate(t *testing.T, db *sql.DB, driver string) {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(migrationsDir(driver), "*.up.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.Exec(string(migration))
+		if err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+	}
+}
+
+// NewSQLiteDB opens an in-memory SQLite database with every migration
+// applied. It is closed when the test ends.
+func NewSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file::memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: gets its own database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrate(t, db, "sqlite")
+	return db
+}
+
+// NewPostgresDB opens the Postgres database in the TEST_DSN environment
+// variable and skips the test when it is not set. Every call gets its own
+// schema with every migration applied, which is dropped when the test ends,
+// so tests do not see each other's data.
+func NewPostgresDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DSN is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("datatest_%d", time.Now().UnixNano())
+	_, err = admin.Exec(`create schema ` + schema)
+	if err != nil {
+		t.Fatalf("creating the test schema: %s", err)
+	}
+	t.Cleanup(func() {
+		_, err := admin.Exec(`drop schema ` + schema + ` cascade`)
+		if err != nil {
+			t.Errorf("dropping the test schema: %s", err)
+		}
+	})
+
+	db, err := sql.Open("postgres", withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// closed before the schema is dropped, since cleanups run last in first
+	// out.
+	t.Cleanup(func() { db.Close() })
+
+	migrate(t, db, "postgres")
+	return db
+}
+
+// withSearchPath sets the search_path run-time parameter of a URL or
+// key=value DSN.
+func withSearchPath(dsn, schema string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return dsn
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
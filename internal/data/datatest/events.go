@@ -0,0 +1,163 @@
+package datatest
+
+import (
+	"context"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"sync"
+	"testing"
+)
+
+// EventsFactory returns an empty Movies implementation and the Events reading
+// its outbox, for one test.
+type EventsFactory func(t *testing.T) (data.Movies, data.Events)
+
+// RunEventsSuite checks that the implementations returned by newEvents read
+// the outbox like every other data.Events implementation.
+func RunEventsSuite(t *testing.T, newEvents EventsFactory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, movies data.Movies, events data.Events)
+	}{
+		{"read events", testReadEvents},
+		{"concurrent writes", testConcurrentWrites},
+	}
+	for _, e := range tests {
+		t.Run(e.name, func(t *testing.T) {
+			movies, events := newEvents(t)
+			e.run(t, movies, events)
+		})
+	}
+}
+
+func testReadEvents(t *testing.T, movies data.Movies, events data.Events) {
+	ctx := context.Background()
+
+	last, err := events.LastEventID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := events.FirstEventID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != 0 || first != 0 {
+		t.Errorf("expected no events but got %d to %d", first, last)
+	}
+
+	movie := create(t, movies, newMovie())
+	movie.Title = "Aliens"
+	err = movies.UpdateMovie(ctx, movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = movies.DeleteMovie(ctx, movie.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := events.GetEventsAfter(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{data.EventMovieCreated, data.EventMovieUpdated, data.EventMovieDeleted}
+	if len(all) != len(expected) {
+		t.Fatalf("expected %d events but got %d", len(expected), len(all))
+	}
+	for i, event := range all {
+		if event.Type != expected[i] || event.Movie == nil || event.Movie.ID != movie.ID {
+			t.Errorf("expected %s of movie %d but got %+v", expected[i], movie.ID, event)
+		}
+		if i > 0 && event.ID <= all[i-1].ID {
+			t.Errorf("expected ids to grow but got %d after %d", event.ID, all[i-1].ID)
+		}
+	}
+	if all[1].Movie.Title != "Aliens" || all[1].Version != 2 {
+		t.Errorf("expected the update to carry the new title and version 2 but got %+v", all[1])
+	}
+
+	last, err = events.LastEventID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != all[2].ID {
+		t.Errorf("expected the last event to be %d but got %d", all[2].ID, last)
+	}
+	first, err = events.FirstEventID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != all[0].ID {
+		t.Errorf("expected the first event to be %d but got %d", all[0].ID, first)
+	}
+	after, err := events.GetEventsAfter(ctx, all[0].ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != 1 || after[0].ID != all[1].ID {
+		t.Errorf("expected only event %d but got %+v", all[1].ID, after)
+	}
+	after, err = events.GetEventsAfter(ctx, last, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != 0 {
+		t.Errorf("expected no events after the last one but got %+v", after)
+	}
+}
+
+// testConcurrentWrites reads the outbox while movies are being written, the
+// way a resuming client does, and checks that no event is recorded behind
+// one that was already read.
+func testConcurrentWrites(t *testing.T, movies data.Movies, events data.Events) {
+	ctx := context.Background()
+	const writers, writes = 4, 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				err := movies.CreateMovie(ctx, newMovie())
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var lastID int64
+	read := 0
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		batch, err := events.GetEventsAfter(ctx, lastID, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, event := range batch {
+			lastID = event.ID
+			read++
+		}
+		if len(batch) > 0 {
+			finished = false
+		}
+	}
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if read != writers*writes {
+		t.Errorf("expected to read all %d events in order but got %d", writers*writes, read)
+	}
+}
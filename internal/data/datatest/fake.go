@@ -0,0 +1,209 @@
+package datatest
+
+import (
+	"context"
+	"fmt"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMethods are the methods of data.Movies that can be programmed.
+var fakeMethods = map[string]bool{
+	"GetMovie":     true,
+	"GetAllMovies": true,
+	"CreateMovie":  true,
+	"UpdateMovie":  true,
+	"DeleteMovie":  true,
+	"UpsertMovie":  true,
+}
+
+// Call is one call made to a FakeMovies.
+type Call struct {
+	Method string
+	// ID is the id passed to GetMovie and DeleteMovie.
+	ID int64
+	// Movie is a copy of the movie passed to CreateMovie, UpdateMovie and
+	// UpsertMovie, as it was when the call was made.
+	Movie *data.Movie
+}
+
+// FakeMovies is a data.Movies for handler tests. It stores movies like
+// data.MemoryMovieModel does, and each test can seed its own movies, make a
+// method fail or slow down, and check the calls that were made.
+type FakeMovies struct {
+	store *data.MemoryMovieModel
+
+	mu     sync.Mutex
+	errs   map[string]error
+	delays map[string]time.Duration
+	calls  []Call
+}
+
+// NewFakeMovies returns a FakeMovies holding movies. Movies with an id keep
+// it, the others get the next free one, and all of them start at version 1.
+// Seeding is not recorded as calls.
+func NewFakeMovies(t *testing.T, movies ...*data.Movie) *FakeMovies {
+	t.Helper()
+	f := &FakeMovies{
+		store:  data.NewMemoryMovieModel(),
+		errs:   make(map[string]error),
+		delays: make(map[string]time.Duration),
+	}
+	for _, movie := range movies {
+		_, err := f.store.UpsertMovie(context.Background(), movie)
+		if err != nil {
+			t.Fatalf("seeding %q: %s", movie.Title, err)
+		}
+	}
+	return f
+}
+
+// Fail makes every later call to method return err without touching the
+// stored movies. A nil err makes method work again.
+func (f *FakeMovies) Fail(method string, err error) {
+	checkMethod(method)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errs[method] = err
+}
+
+// Delay makes every later call to method wait for d first, or until its
+// context is done.
+func (f *FakeMovies) Delay(method string, d time.Duration) {
+	checkMethod(method)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delays[method] = d
+}
+
+// Calls returns the calls made so far, in order. If methods are given, only
+// the calls to them are returned.
+func (f *FakeMovies) Calls(methods ...string) []Call {
+	for _, method := range methods {
+		checkMethod(method)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	calls := []Call{}
+	for _, call := range f.calls {
+		if len(methods) == 0 || contains(methods, call.Method) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Methods returns the names of the methods called so far, in order.
+func (f *FakeMovies) Methods() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	methods := []string{}
+	for _, call := range f.calls {
+		methods = append(methods, call.Method)
+	}
+	return methods
+}
+
+// Reset forgets the recorded calls. Stored movies, errors and delays are
+// kept.
+func (f *FakeMovies) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+}
+
+func (f *FakeMovies) GetMovie(ctx context.Context, id int64) (*data.Movie, error) {
+	err := f.call(ctx, Call{Method: "GetMovie", ID: id})
+	if err != nil {
+		return nil, err
+	}
+	return f.store.GetMovie(ctx, id)
+}
+
+func (f *FakeMovies) GetAllMovies(ctx context.Context) ([]*data.Movie, error) {
+	err := f.call(ctx, Call{Method: "GetAllMovies"})
+	if err != nil {
+		return nil, err
+	}
+	return f.store.GetAllMovies(ctx)
+}
+
+func (f *FakeMovies) CreateMovie(ctx context.Context, movie *data.Movie) error {
+	err := f.call(ctx, Call{Method: "CreateMovie", Movie: copyMovie(movie)})
+	if err != nil {
+		return err
+	}
+	return f.store.CreateMovie(ctx, movie)
+}
+
+func (f *FakeMovies) UpdateMovie(ctx context.Context, movie *data.Movie) error {
+	err := f.call(ctx, Call{Method: "UpdateMovie", Movie: copyMovie(movie)})
+	if err != nil {
+		return err
+	}
+	return f.store.UpdateMovie(ctx, movie)
+}
+
+func (f *FakeMovies) DeleteMovie(ctx context.Context, id int64) error {
+	err := f.call(ctx, Call{Method: "DeleteMovie", ID: id})
+	if err != nil {
+		return err
+	}
+	return f.store.DeleteMovie(ctx, id)
+}
+
+func (f *FakeMovies) UpsertMovie(ctx context.Context, movie *data.Movie) (bool, error) {
+	err := f.call(ctx, Call{Method: "UpsertMovie", Movie: copyMovie(movie)})
+	if err != nil {
+		return false, err
+	}
+	return f.store.UpsertMovie(ctx, movie)
+}
+
+// call records c, then waits for the delay and returns the error programmed
+// for its method, if any.
+func (f *FakeMovies) call(ctx context.Context, c Call) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, c)
+	delay := f.delays[c.Method]
+	err := f.errs[c.Method]
+	f.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// checkMethod panics on a method name FakeMovies does not have, so a typo
+// does not silently program nothing.
+func checkMethod(method string) {
+	if !fakeMethods[method] {
+		panic(fmt.Sprintf("datatest: data.Movies has no method %q", method))
+	}
+}
+
+func copyMovie(movie *data.Movie) *data.Movie {
+	c := *movie
+	c.Genres = append([]string(nil), movie.Genres...)
+	return &c
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package datatest
+
+import (
+	"context"
+	"errors"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFakeMoviesProgramming(t *testing.T) {
+	ctx := context.Background()
+	movies := NewFakeMovies(t, &data.Movie{ID: 7, Title: "Alien", Runtime: 117, Year: 1979, Genres: []string{"horror"}})
+	if len(movies.Calls()) != 0 {
+		t.Errorf("expected seeding not to be recorded but got %v", movies.Calls())
+	}
+
+	movie := get(t, movies, 7)
+	failure := errors.New("connection refused")
+	movies.Fail("UpdateMovie", failure)
+	movie.Title = "Aliens"
+	checkError(t, "UpdateMovie", failure, movies.UpdateMovie(ctx, movie))
+	if get(t, movies, 7).Title != "Alien" {
+		t.Error("expected a failed UpdateMovie not to change the movie")
+	}
+	movies.Fail("UpdateMovie", nil)
+	err := movies.UpdateMovie(ctx, movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"GetMovie", "UpdateMovie", "GetMovie", "UpdateMovie"}
+	if !reflect.DeepEqual(movies.Methods(), expected) {
+		t.Errorf("expected calls %v but got %v", expected, movies.Methods())
+	}
+	updates := movies.Calls("UpdateMovie")
+	if len(updates) != 2 || updates[1].Movie.Title != "Aliens" || updates[1].Movie.Version != 1 {
+		t.Errorf("expected the second update to send Aliens at version 1 but got %+v", updates)
+	}
+	movie.Title = "changed after the call"
+	if updates[1].Movie.Title != "Aliens" {
+		t.Error("expected the recorded movie to be a copy")
+	}
+
+	movies.Reset()
+	movies.Delay("DeleteMovie", time.Minute)
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	checkError(t, "DeleteMovie", context.DeadlineExceeded, movies.DeleteMovie(timeout, 7))
+	calls := movies.Calls()
+	if len(calls) != 1 || calls[0].ID != 7 {
+		t.Errorf("expected one DeleteMovie call for 7 but got %+v", calls)
+	}
+	get(t, movies, 7)
+}
+
+func TestFakeMoviesUnknownMethod(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for an unknown method")
+		}
+	}()
+	NewFakeMovies(t).Fail("GetMovies", errors.New("typo"))
+}
@@ -0,0 +1,352 @@
+package datatest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"reflect"
+	"testing"
+)
+
+// MoviesFactory returns an empty Movies implementation for one test.
+type MoviesFactory func(t *testing.T) data.Movies
+
+// RunMoviesSuite checks that the implementations returned by newMovies behave
+// like every other data.Movies implementation. Each subtest gets a new, empty
+// implementation.
+func RunMoviesSuite(t *testing.T, newMovies MoviesFactory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, movies data.Movies)
+	}{
+		{"create and get", testCreateAndGet},
+		{"not found", testNotFound},
+		{"get all", testGetAll},
+		{"update", testUpdate},
+		{"version conflict", testVersionConflict},
+		{"delete", testDelete},
+		{"genres", testGenres},
+		{"upsert", testUpsert},
+		{"duplicate external id", testDuplicateExternalID},
+		{"id range", testIDRange},
+		{"canceled context", testCanceledContext},
+	}
+	for _, e := range tests {
+		t.Run(e.name, func(t *testing.T) {
+			e.run(t, newMovies(t))
+		})
+	}
+}
+
+func newMovie() *data.Movie {
+	return &data.Movie{Title: "Alien", Runtime: 117, Year: 1979, Genres: []string{"horror", "action"}}
+}
+
+func create(t *testing.T, movies data.Movies, movie *data.Movie) *data.Movie {
+	t.Helper()
+	err := movies.CreateMovie(context.Background(), movie)
+	if err != nil {
+		t.Fatalf("CreateMovie: %s", err)
+	}
+	return movie
+}
+
+func get(t *testing.T, movies data.Movies, id int64) *data.Movie {
+	t.Helper()
+	movie, err := movies.GetMovie(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetMovie(%d): %s", id, err)
+	}
+	return movie
+}
+
+// checkMovie compares the fields clients see, and the version.
+func checkMovie(t *testing.T, expected, got *data.Movie) {
+	t.Helper()
+	if got.ID != expected.ID || got.Title != expected.Title || got.Runtime != expected.Runtime || got.Year != expected.Year ||
+		got.ExternalID != expected.ExternalID || got.Version != expected.Version || !reflect.DeepEqual(got.Genres, expected.Genres) {
+		t.Errorf("expected %+v but got %+v", *expected, *got)
+	}
+}
+
+func checkError(t *testing.T, operation string, expected, err error) {
+	t.Helper()
+	if !errors.Is(err, expected) {
+		t.Errorf("%s: expected %v but got %v", operation, expected, err)
+	}
+}
+
+func testCreateAndGet(t *testing.T, movies data.Movies) {
+	first := create(t, movies, newMovie())
+	if first.ID <= 0 {
+		t.Errorf("expected a positive id but got %d", first.ID)
+	}
+	if first.Version != 1 {
+		t.Errorf("expected version 1 but got %d", first.Version)
+	}
+	checkMovie(t, first, get(t, movies, first.ID))
+
+	second := create(t, movies, &data.Movie{Title: "Casablanca", Runtime: 102, Year: 1942, Genres: []string{"drama"}})
+	if second.ID == first.ID {
+		t.Errorf("expected a new id but got %d twice", first.ID)
+	}
+	checkMovie(t, second, get(t, movies, second.ID))
+}
+
+func testNotFound(t *testing.T, movies data.Movies) {
+	ctx := context.Background()
+	movie := create(t, movies, newMovie())
+
+	for _, id := range []int64{0, -1, movie.ID + 1000} {
+		_, err := movies.GetMovie(ctx, id)
+		checkError(t, "GetMovie", data.ErrNoRecordFound, err)
+		checkError(t, "DeleteMovie", data.ErrNoRecordFound, movies.DeleteMovie(ctx, id))
+	}
+
+	missing := newMovie()
+	missing.ID = movie.ID + 1000
+	missing.Version = 1
+	checkError(t, "UpdateMovie of a missing movie", data.ErrEditConflict, movies.UpdateMovie(ctx, missing))
+}
+
+func testGetAll(t *testing.T, movies data.Movies) {
+	ctx := context.Background()
+	all, err := movies.GetAllMovies(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 0 {
+		t.Errorf("expected no movies but got %d", len(all))
+	}
+
+	expected := map[int64]*data.Movie{}
+	for _, movie := range []*data.Movie{
+		newMovie(),
+		{Title: "Casablanca", Runtime: 102, Year: 1942, Genres: []string{"drama"}},
+		{Title: "Groundhog Day", Runtime: 101, Year: 1993, Genres: []string{"comedy", "drama"}},
+	} {
+		create(t, movies, movie)
+		expected[movie.ID] = movie
+	}
+
+	all, err = movies.GetAllMovies(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(expected) {
+		t.Fatalf("expected %d movies but got %d", len(expected), len(all))
+	}
+	for _, movie := range all {
+		if expected[movie.ID] == nil {
+			t.Errorf("unexpected movie %+v", *movie)
+			continue
+		}
+		checkMovie(t, expected[movie.ID], movie)
+	}
+}
+
+func testUpdate(t *testing.T, movies data.Movies) {
+	movie := create(t, movies, newMovie())
+	movie.Title = "Aliens"
+	movie.Runtime = 137
+	movie.Year = 1986
+	err := movies.UpdateMovie(context.Background(), movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.Version != 2 {
+		t.Errorf("expected version 2 but got %d", movie.Version)
+	}
+	checkMovie(t, movie, get(t, movies, movie.ID))
+}
+
+func testVersionConflict(t *testing.T, movies data.Movies) {
+	ctx := context.Background()
+	movie := create(t, movies, newMovie())
+
+	first := get(t, movies, movie.ID)
+	second := get(t, movies, movie.ID)
+	first.Title = "first"
+	err := movies.UpdateMovie(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Title = "second"
+	checkError(t, "UpdateMovie with a stale version", data.ErrEditConflict, movies.UpdateMovie(ctx, second))
+
+	stored := get(t, movies, movie.ID)
+	if stored.Title != "first" || stored.Version != 2 {
+		t.Errorf("expected the first update to stay but got %+v", *stored)
+	}
+}
+
+func testDelete(t *testing.T, movies data.Movies) {
+	ctx := context.Background()
+	movie := create(t, movies, newMovie())
+	kept := create(t, movies, newMovie())
+
+	err := movies.DeleteMovie(ctx, movie.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = movies.GetMovie(ctx, movie.ID)
+	checkError(t, "GetMovie after DeleteMovie", data.ErrNoRecordFound, err)
+	checkError(t, "DeleteMovie twice", data.ErrNoRecordFound, movies.DeleteMovie(ctx, movie.ID))
+	checkMovie(t, kept, get(t, movies, kept.ID))
+}
+
+func testGenres(t *testing.T, movies data.Movies) {
+	ctx := context.Background()
+	// the order is the client's, not sorted.
+	movie := create(t, movies, &data.Movie{Title: "Every Genre", Runtime: 100, Year: 2000, Genres: []string{"horror", "action", "drama", "comedy", "adventure"}})
+	checkMovie(t, movie, get(t, movies, movie.ID))
+
+	movie.Genres = []string{"comedy"}
+	err := movies.UpdateMovie(ctx, movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMovie(t, movie, get(t, movies, movie.ID))
+
+	// the caller's slice is not shared with the stored movie.
+	movie.Genres[0] = "drama"
+	stored := get(t, movies, movie.ID)
+	if stored.Genres[0] != "comedy" {
+		t.Errorf("expected the stored genres to be unchanged but got %v", stored.Genres)
+	}
+	stored.Genres[0] = "action"
+	if get(t, movies, movie.ID).Genres[0] != "comedy" {
+		t.Error("expected the returned genres not to be shared with the stored movie")
+	}
+}
+
+func testUpsert(t *testing.T, movies data.Movies) {
+	ctx := context.Background()
+
+	byExternalID := newMovie()
+	byExternalID.ExternalID = "imdb-tt0078748"
+	created, err := movies.UpsertMovie(ctx, byExternalID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || byExternalID.ID <= 0 || byExternalID.Version != 1 {
+		t.Errorf("expected a new movie with version 1 but got created %t and %+v", created, *byExternalID)
+	}
+	checkMovie(t, byExternalID, get(t, movies, byExternalID.ID))
+
+	again := &data.Movie{Title: "Alien (director's cut)", Runtime: 116, Year: 2003, Genres: []string{"horror"}, ExternalID: "imdb-tt0078748"}
+	created, err = movies.UpsertMovie(ctx, again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || again.ID != byExternalID.ID || again.Version != 2 {
+		t.Errorf("expected movie %d to be replaced with version 2 but got created %t and %+v", byExternalID.ID, created, *again)
+	}
+	checkMovie(t, again, get(t, movies, again.ID))
+
+	// a client chosen id is kept, and not handed out again by CreateMovie.
+	byID := newMovie()
+	byID.ID = byExternalID.ID + 100
+	created, err = movies.UpsertMovie(ctx, byID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || byID.ID != byExternalID.ID+100 {
+		t.Errorf("expected a new movie with id %d but got created %t and %+v", byExternalID.ID+100, created, *byID)
+	}
+	next := create(t, movies, newMovie())
+	if next.ID <= byID.ID {
+		t.Errorf("expected an id above %d but got %d", byID.ID, next.ID)
+	}
+
+	// the external id of an existing movie is never changed.
+	replaced := &data.Movie{ID: again.ID, Title: "Alien", Runtime: 117, Year: 1979, Genres: []string{"horror"}, ExternalID: "something-else"}
+	created, err = movies.UpsertMovie(ctx, replaced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || replaced.ExternalID != "imdb-tt0078748" || replaced.Version != 3 {
+		t.Errorf("expected the external id to be kept at version 3 but got created %t and %+v", created, *replaced)
+	}
+	checkMovie(t, replaced, get(t, movies, replaced.ID))
+}
+
+func testDuplicateExternalID(t *testing.T, movies data.Movies) {
+	ctx := context.Background()
+	first := newMovie()
+	first.ExternalID = "imdb-tt0078748"
+	_, err := movies.UpsertMovie(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := newMovie()
+	other.ID = first.ID + 100
+	other.ExternalID = "imdb-tt0078748"
+	_, err = movies.UpsertMovie(ctx, other)
+	checkError(t, "UpsertMovie with a taken external id", data.ErrDuplicateExternalID, err)
+	_, err = movies.GetMovie(ctx, first.ID+100)
+	checkError(t, "GetMovie after a rejected UpsertMovie", data.ErrNoRecordFound, err)
+}
+
+func testIDRange(t *testing.T, movies data.Movies) {
+	ctx := context.Background()
+	for _, id := range []int64{-1, data.MaxMovieID + 1} {
+		movie := newMovie()
+		movie.ID = id
+		_, err := movies.UpsertMovie(ctx, movie)
+		checkError(t, fmt.Sprintf("UpsertMovie with id %d", id), data.ErrIDOutOfRange, err)
+	}
+	all, err := movies.GetAllMovies(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 0 {
+		t.Errorf("expected no movies but got %d", len(all))
+	}
+
+	// the largest id is accepted, and a smaller one chosen afterwards does not
+	// move CreateMovie back under it.
+	for _, id := range []int64{data.MaxMovieID, 5} {
+		movie := newMovie()
+		movie.ID = id
+		_, err = movies.UpsertMovie(ctx, movie)
+		if err != nil {
+			t.Fatalf("UpsertMovie with id %d: %s", id, err)
+		}
+	}
+	next := create(t, movies, newMovie())
+	if next.ID <= data.MaxMovieID {
+		t.Errorf("expected an id above %d but got %d", data.MaxMovieID, next.ID)
+	}
+}
+
+func testCanceledContext(t *testing.T, movies data.Movies) {
+	movie := create(t, movies, newMovie())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := movies.GetMovie(ctx, movie.ID)
+	checkError(t, "GetMovie", context.Canceled, err)
+	_, err = movies.GetAllMovies(ctx)
+	checkError(t, "GetAllMovies", context.Canceled, err)
+	checkError(t, "CreateMovie", context.Canceled, movies.CreateMovie(ctx, newMovie()))
+
+	changed := get(t, movies, movie.ID)
+	changed.Title = "changed"
+	checkError(t, "UpdateMovie", context.Canceled, movies.UpdateMovie(ctx, changed))
+	_, err = movies.UpsertMovie(ctx, changed)
+	checkError(t, "UpsertMovie", context.Canceled, err)
+	checkError(t, "DeleteMovie", context.Canceled, movies.DeleteMovie(ctx, movie.ID))
+
+	// nothing was written.
+	all, err := movies.GetAllMovies(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 {
+		t.Errorf("expected only the first movie but got %d movies", len(all))
+	}
+	checkMovie(t, movie, get(t, movies, movie.ID))
+}
@@ -0,0 +1,101 @@
+package datatest
+
+import (
+	"context"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"os"
+	"testing"
+	"time"
+)
+
+// MockMovieModel is left out, it only knows a few hard-coded movies.
+
+func TestPostgresMovieModel(t *testing.T) {
+	if os.Getenv("TEST_DSN") == "" {
+		t.Skip("TEST_DSN is not set")
+	}
+	RunMoviesSuite(t, func(t *testing.T) data.Movies {
+		return data.NewMovieModel(NewPostgresDB(t))
+	})
+}
+
+func TestSQLiteMovieModel(t *testing.T) {
+	RunMoviesSuite(t, func(t *testing.T) data.Movies {
+		return data.NewSQLiteMovieModel(NewSQLiteDB(t))
+	})
+}
+
+func TestMemoryMovieModel(t *testing.T) {
+	RunMoviesSuite(t, func(t *testing.T) data.Movies {
+		return data.NewMemoryMovieModel()
+	})
+}
+
+func TestCachedMovieModel(t *testing.T) {
+	RunMoviesSuite(t, func(t *testing.T) data.Movies {
+		return data.NewCachedMovieModel(data.NewMemoryMovieModel(), 100, time.Minute)
+	})
+}
+
+func TestNotifyingMovieModel(t *testing.T) {
+	RunMoviesSuite(t, func(t *testing.T) data.Movies {
+		movies := data.NewMemoryMovieModel()
+		notifying, err := data.NewNotifyingMovieModel(context.Background(), movies, data.NewMemoryEventModel(movies), func(data.MovieEvent) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return notifying
+	})
+}
+
+func TestPostgresTrash(t *testing.T) {
+	RunTrashSuite(t, func(t *testing.T) (data.Movies, data.Trash) {
+		movies := data.NewMovieModel(NewPostgresDB(t))
+		return movies, movies
+	})
+}
+
+func TestSQLiteTrash(t *testing.T) {
+	RunTrashSuite(t, func(t *testing.T) (data.Movies, data.Trash) {
+		movies := data.NewSQLiteMovieModel(NewSQLiteDB(t))
+		return movies, movies
+	})
+}
+
+func TestPostgresEventModel(t *testing.T) {
+	RunEventsSuite(t, func(t *testing.T) (data.Movies, data.Events) {
+		db := NewPostgresDB(t)
+		return data.NewMovieModel(db), data.NewEventModel(db)
+	})
+}
+
+func TestSQLiteEventModel(t *testing.T) {
+	RunEventsSuite(t, func(t *testing.T) (data.Movies, data.Events) {
+		db := NewSQLiteDB(t)
+		return data.NewSQLiteMovieModel(db), data.NewSQLiteEventModel(db)
+	})
+}
+
+func TestMemoryEventModel(t *testing.T) {
+	RunEventsSuite(t, func(t *testing.T) (data.Movies, data.Events) {
+		movies := data.NewMemoryMovieModel()
+		return movies, data.NewMemoryEventModel(movies)
+	})
+}
+
+func TestFakeMovies(t *testing.T) {
+	RunMoviesSuite(t, func(t *testing.T) data.Movies {
+		return NewFakeMovies(t)
+	})
+}
+
+func TestReplicatedMovieModel(t *testing.T) {
+	RunMoviesSuite(t, func(t *testing.T) data.Movies {
+		// the replica reads the primary's database, as if replication was
+		// instant.
+		db := NewSQLiteDB(t)
+		movies := data.NewReplicatedMovieModel(data.NewSQLiteMovieModel(db), []data.Replica{{Movies: data.NewSQLiteMovieModel(db), DB: db}}, time.Second)
+		t.Cleanup(movies.Close)
+		return movies
+	})
+}
@@ -0,0 +1,86 @@
+package datatest
+
+import (
+	"context"
+	"errors"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"testing"
+	"time"
+)
+
+// TrashFactory returns an empty Movies implementation and the Trash holding
+// the movies it deletes, for one test.
+type TrashFactory func(t *testing.T) (data.Movies, data.Trash)
+
+// RunTrashSuite checks that the movies deleted through the implementations
+// returned by newTrash are hidden until they are purged or replaced.
+func RunTrashSuite(t *testing.T, newTrash TrashFactory) {
+	ctx := context.Background()
+	movies, trash := newTrash(t)
+
+	trashed := create(t, movies, newMovie())
+	kept := create(t, movies, newMovie())
+	external := newMovie()
+	external.ExternalID = "tt0078748"
+	_, err := movies.UpsertMovie(ctx, external)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int64{trashed.ID, external.ID} {
+		err = movies.DeleteMovie(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = movies.GetMovie(ctx, trashed.ID)
+	if !errors.Is(err, data.ErrNoRecordFound) {
+		t.Errorf("expected a trashed movie to be hidden but got %v", err)
+	}
+	all, err := movies.GetAllMovies(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].ID != kept.ID {
+		t.Errorf("expected only movie %d to be listed but got %+v", kept.ID, all)
+	}
+	err = movies.DeleteMovie(ctx, trashed.ID)
+	if !errors.Is(err, data.ErrNoRecordFound) {
+		t.Errorf("expected deleting a trashed movie to fail with ErrNoRecordFound but got %v", err)
+	}
+	err = movies.UpdateMovie(ctx, trashed)
+	if !errors.Is(err, data.ErrEditConflict) {
+		t.Errorf("expected updating a trashed movie to fail with ErrEditConflict but got %v", err)
+	}
+
+	replaced := newMovie()
+	replaced.ID = trashed.ID
+	created, err := movies.UpsertMovie(ctx, replaced)
+	if err != nil || !created || replaced.Version != 1 {
+		t.Errorf("expected an upsert to create a new movie %d but got created %t, version %d, %v", trashed.ID, created, replaced.Version, err)
+	}
+	again := newMovie()
+	again.ExternalID = external.ExternalID
+	created, err = movies.UpsertMovie(ctx, again)
+	if err != nil || !created {
+		t.Errorf("expected an upsert to create a new movie with the external id of a trashed one but got created %t, %v", created, err)
+	}
+
+	err = movies.DeleteMovie(ctx, kept.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	purged, err := trash.PurgeTrash(ctx, time.Now().Add(-time.Hour))
+	if err != nil || purged != 0 {
+		t.Errorf("expected a recently trashed movie to be kept but got %d purged, %v", purged, err)
+	}
+	purged, err = trash.PurgeTrash(ctx, time.Now().Add(time.Minute))
+	if err != nil || purged != 1 {
+		t.Errorf("expected the trashed movie to be purged but got %d purged, %v", purged, err)
+	}
+	kept.Version = 0
+	created, err = movies.UpsertMovie(ctx, kept)
+	if err != nil || !created {
+		t.Errorf("expected the purged movie %d to be created again but got created %t, %v", kept.ID, created, err)
+	}
+}
@@ -0,0 +1,130 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventMovieCreated = "movie.created"
+	EventMovieUpdated = "movie.updated"
+	EventMovieDeleted = "movie.deleted"
+)
+
+// EventTypes lists every event a webhook can subscribe to.
+var EventTypes = []string{EventMovieCreated, EventMovieUpdated, EventMovieDeleted}
+
+// Event is a change to the catalogue recorded in the outbox in the same
+// transaction as the change itself, so no event is lost if the process dies
+// before it is delivered.
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	MovieID   int64           `json:"movie_id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// MovieEvent is the JSON body of an event, as sent to webhooks and streamed
+// to clients. The movie version is included separately because Movie hides it
+// from JSON.
+type MovieEvent struct {
+	// ID is the outbox ID of the event, and 0 until it is recorded. It is not
+	// part of the body, which is stored before the ID is known.
+	ID         int64     `json:"-"`
+	Type       string    `json:"type"`
+	Movie      *Movie    `json:"movie"`
+	Version    int32     `json:"version"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func NewMovieEvent(eventType string, movie *Movie) MovieEvent {
+	return MovieEvent{
+		Type:       eventType,
+		Movie:      copyMovie(movie),
+		Version:    movie.Version,
+		OccurredAt: time.Now().UTC(),
+	}
+}
+
+func newEventPayload(eventType string, movie *Movie) ([]byte, error) {
+	return json.Marshal(NewMovieEvent(eventType, movie))
+}
+
+// decodeEvent returns the MovieEvent stored as payload in the outbox with id.
+func decodeEvent(id int64, payload []byte) (MovieEvent, error) {
+	var event MovieEvent
+	err := json.Unmarshal(payload, &event)
+	if err != nil {
+		return MovieEvent{}, err
+	}
+	event.ID = id
+	return event, nil
+}
+
+// Events reads the outbox, so clients that missed some changes can catch up.
+// Outbox IDs only grow, in the order the writes commit, and are shared by
+// every API instance using the same database. So an event is never recorded
+// before one a client has already read.
+type Events interface {
+	// GetEventsAfter returns up to limit events recorded after the event with
+	// afterID, oldest first.
+	GetEventsAfter(ctx context.Context, afterID int64, limit int) ([]MovieEvent, error)
+	// FirstEventID returns the ID of the oldest event still kept, or 0 if
+	// there is none. Older events were removed and cannot be replayed.
+	FirstEventID(ctx context.Context) (int64, error)
+	// LastEventID returns the ID of the newest event, or 0 if there is none.
+	LastEventID(ctx context.Context) (int64, error)
+}
+
+type EventModel struct {
+	DB *sql.DB
+}
+
+func NewEventModel(db *sql.DB) EventModel {
+	return EventModel{DB: db}
+}
+
+func (m EventModel) GetEventsAfter(ctx context.Context, afterID int64, limit int) ([]MovieEvent, error) {
+	query := `select id, payload from outbox_events where id > $1 order by id limit $2`
+	return queryEvents(ctx, m.DB, query, afterID, limit)
+}
+
+func (m EventModel) FirstEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := m.DB.QueryRowContext(ctx, `select coalesce(min(id), 0) from outbox_events`).Scan(&id)
+	return id, err
+}
+
+func (m EventModel) LastEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := m.DB.QueryRowContext(ctx, `select coalesce(max(id), 0) from outbox_events`).Scan(&id)
+	return id, err
+}
+
+// queryEvents decodes the id and payload rows returned by query.
+func queryEvents(ctx context.Context, db *sql.DB, query string, args ...any) ([]MovieEvent, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []MovieEvent{}
+	for rows.Next() {
+		var id int64
+		var payload []byte
+		err = rows.Scan(&id, &payload)
+		if err != nil {
+			return nil, err
+		}
+		event, err := decodeEvent(id, payload)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,66 @@
+package data
+
+import (
+	"context"
+	"sort"
+)
+
+// MemoryEventModel reads the outbox of a MemoryMovieModel. The outbox only
+// lives as long as the process, like the movies, and only keeps the newest
+// events.
+type MemoryEventModel struct {
+	movies *MemoryMovieModel
+}
+
+func NewMemoryEventModel(movies *MemoryMovieModel) *MemoryEventModel {
+	return &MemoryEventModel{movies: movies}
+}
+
+func (m *MemoryEventModel) GetEventsAfter(ctx context.Context, afterID int64, limit int) ([]MovieEvent, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+	m.movies.mu.RLock()
+	defer m.movies.mu.RUnlock()
+
+	// events are appended in ID order.
+	outbox := m.movies.events
+	i := sort.Search(len(outbox), func(i int) bool {
+		return outbox[i].ID > afterID
+	})
+	events := []MovieEvent{}
+	for ; i < len(outbox) && len(events) < limit; i++ {
+		event, err := decodeEvent(outbox[i].ID, outbox[i].Payload)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (m *MemoryEventModel) FirstEventID(ctx context.Context) (int64, error) {
+	err := ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	m.movies.mu.RLock()
+	defer m.movies.mu.RUnlock()
+
+	if len(m.movies.events) == 0 {
+		return 0, nil
+	}
+	return m.movies.events[0].ID, nil
+}
+
+func (m *MemoryEventModel) LastEventID(ctx context.Context) (int64, error) {
+	err := ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	m.movies.mu.RLock()
+	defer m.movies.mu.RUnlock()
+
+	return m.movies.nextEventID - 1, nil
+}
@@ -0,0 +1,31 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+)
+
+type SQLiteEventModel struct {
+	DB *sql.DB
+}
+
+func NewSQLiteEventModel(db *sql.DB) SQLiteEventModel {
+	return SQLiteEventModel{DB: db}
+}
+
+func (m SQLiteEventModel) GetEventsAfter(ctx context.Context, afterID int64, limit int) ([]MovieEvent, error) {
+	query := `select id, payload from outbox_events where id > ? order by id limit ?`
+	return queryEvents(ctx, m.DB, query, afterID, limit)
+}
+
+func (m SQLiteEventModel) FirstEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := m.DB.QueryRowContext(ctx, `select coalesce(min(id), 0) from outbox_events`).Scan(&id)
+	return id, err
+}
+
+func (m SQLiteEventModel) LastEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := m.DB.QueryRowContext(ctx, `select coalesce(max(id), 0) from outbox_events`).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyReused   = errors.New("idempotency key was used with a different request")
+	ErrIdempotencyKeyInFlight = errors.New("idempotency key is in use by a request in progress")
+)
+
+// IdempotencyKeys remembers the responses to requests sent with an
+// Idempotency-Key header, so retried requests are answered without running
+// them again.
+type IdempotencyKeys interface {
+	// ReserveKey claims key for a request with the given fingerprint until
+	// lockTimeout has passed. It returns the stored response if the request
+	// already completed, ErrIdempotencyKeyInFlight if it is still running and
+	// ErrIdempotencyKeyReused if key belongs to a different request. A nil
+	// response and error mean the caller owns the key and should run the
+	// request.
+	ReserveKey(ctx context.Context, key, fingerprint string, lockTimeout time.Duration) (*StoredResponse, error)
+	// SaveResponse stores the response for a reserved key for ttl.
+	SaveResponse(ctx context.Context, key string, response *StoredResponse, ttl time.Duration) error
+	// ReleaseKey forgets a reserved key, so the request can be retried.
+	ReleaseKey(ctx context.Context, key string) error
+	// DeleteExpiredKeys removes the keys whose response or lock has expired
+	// and returns how many there were.
+	DeleteExpiredKeys(ctx context.Context) (int64, error)
+}
+
+// StoredResponse is the response replayed for a repeated request.
+type StoredResponse struct {
+	Status  int
+	Headers http.Header
+	Body    []byte
+}
+
+type IdempotencyKeyModel struct {
+	DB *sql.DB
+}
+
+func NewIdempotencyKeyModel(db *sql.DB) IdempotencyKeyModel {
+	return IdempotencyKeyModel{DB: db}
+}
+
+func (m IdempotencyKeyModel) ReserveKey(ctx context.Context, key, fingerprint string, lockTimeout time.Duration) (*StoredResponse, error) {
+	// An expired record, finished or abandoned, is taken over.
+	query := `insert into idempotency_keys (key, fingerprint, expires_at) values ($1, $2, now() + $3 * interval '1 millisecond')
+		on conflict (key) do update set fingerprint = excluded.fingerprint, status = 0, headers = '{}', body = '', created_at = now(), expires_at = excluded.expires_at
+		where idempotency_keys.expires_at <= now()
+		returning key`
+	err := m.DB.QueryRowContext(ctx, query, key, fingerprint, lockTimeout.Milliseconds()).Scan(&key)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	var stored string
+	var response StoredResponse
+	var headers []byte
+	query = `select fingerprint, status, headers, body from idempotency_keys where key = $1`
+	err = m.DB.QueryRowContext(ctx, query, key).Scan(&stored, &response.Status, &headers, &response.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// released in the meantime, the client can simply retry.
+			return nil, ErrIdempotencyKeyInFlight
+		default:
+			return nil, err
+		}
+	}
+	return storedResponse(stored, fingerprint, &response, headers)
+}
+
+func (m IdempotencyKeyModel) SaveResponse(ctx context.Context, key string, response *StoredResponse, ttl time.Duration) error {
+	headers, err := json.Marshal(response.Headers)
+	if err != nil {
+		return err
+	}
+	query := `update idempotency_keys set status = $1, headers = $2, body = $3, expires_at = now() + $4 * interval '1 millisecond' where key = $5`
+	_, err = m.DB.ExecContext(ctx, query, response.Status, headers, response.Body, ttl.Milliseconds(), key)
+	return err
+}
+
+func (m IdempotencyKeyModel) ReleaseKey(ctx context.Context, key string) error {
+	_, err := m.DB.ExecContext(ctx, `delete from idempotency_keys where key = $1 and status = 0`, key)
+	return err
+}
+
+func (m IdempotencyKeyModel) DeleteExpiredKeys(ctx context.Context) (int64, error) {
+	result, err := m.DB.ExecContext(ctx, `delete from idempotency_keys where expires_at <= now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// storedResponse checks a record found by ReserveKey against the request
+// fingerprint.
+func storedResponse(stored, fingerprint string, response *StoredResponse, headers []byte) (*StoredResponse, error) {
+	if stored != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if response.Status == 0 {
+		return nil, ErrIdempotencyKeyInFlight
+	}
+	err := json.Unmarshal(headers, &response.Headers)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryIdempotencyRecord struct {
+	fingerprint string
+	response    *StoredResponse
+	expires     time.Time
+}
+
+// MemoryIdempotencyKeyModel keeps idempotency keys in memory. Expired keys are
+// ignored when reserved again, and removed by Run every SweepInterval.
+type MemoryIdempotencyKeyModel struct {
+	SweepInterval time.Duration
+
+	mu   sync.Mutex
+	keys map[string]*memoryIdempotencyRecord
+}
+
+func NewMemoryIdempotencyKeyModel() *MemoryIdempotencyKeyModel {
+	return &MemoryIdempotencyKeyModel{
+		SweepInterval: time.Minute,
+		keys:          make(map[string]*memoryIdempotencyRecord),
+	}
+}
+
+// Run removes the expired keys every SweepInterval until ctx is cancelled.
+func (m *MemoryIdempotencyKeyModel) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.SweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, _ = m.DeleteExpiredKeys(ctx)
+		}
+	}
+}
+
+func (m *MemoryIdempotencyKeyModel) ReserveKey(ctx context.Context, key, fingerprint string, lockTimeout time.Duration) (*StoredResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	record, ok := m.keys[key]
+	if !ok || !record.expires.After(now) {
+		m.keys[key] = &memoryIdempotencyRecord{fingerprint: fingerprint, expires: now.Add(lockTimeout)}
+		return nil, nil
+	}
+	if record.fingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if record.response == nil {
+		return nil, ErrIdempotencyKeyInFlight
+	}
+	return copyStoredResponse(record.response), nil
+}
+
+func (m *MemoryIdempotencyKeyModel) SaveResponse(ctx context.Context, key string, response *StoredResponse, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if record, ok := m.keys[key]; ok {
+		record.response = copyStoredResponse(response)
+		record.expires = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+func (m *MemoryIdempotencyKeyModel) ReleaseKey(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if record, ok := m.keys[key]; ok && record.response == nil {
+		delete(m.keys, key)
+	}
+	return nil
+}
+
+func (m *MemoryIdempotencyKeyModel) DeleteExpiredKeys(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deleteExpired(time.Now()), nil
+}
+
+// deleteExpired is called with mu held.
+func (m *MemoryIdempotencyKeyModel) deleteExpired(now time.Time) int64 {
+	var deleted int64
+	for k, record := range m.keys {
+		if !record.expires.After(now) {
+			delete(m.keys, k)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+func copyStoredResponse(response *StoredResponse) *StoredResponse {
+	c := &StoredResponse{
+		Status:  response.Status,
+		Headers: response.Headers.Clone(),
+		Body:    make([]byte, len(response.Body)),
+	}
+	copy(c.Body, response.Body)
+	return c
+}
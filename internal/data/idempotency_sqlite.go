@@ -0,0 +1,70 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type SQLiteIdempotencyKeyModel struct {
+	DB *sql.DB
+}
+
+func NewSQLiteIdempotencyKeyModel(db *sql.DB) SQLiteIdempotencyKeyModel {
+	return SQLiteIdempotencyKeyModel{DB: db}
+}
+
+func (m SQLiteIdempotencyKeyModel) ReserveKey(ctx context.Context, key, fingerprint string, lockTimeout time.Duration) (*StoredResponse, error) {
+	now := time.Now()
+	query := `insert into idempotency_keys (key, fingerprint, created_at, expires_at) values (?, ?, ?, ?)
+		on conflict (key) do update set fingerprint = excluded.fingerprint, status = 0, headers = '{}', body = x'', created_at = excluded.created_at, expires_at = excluded.expires_at
+		where idempotency_keys.expires_at <= excluded.created_at
+		returning key`
+	err := m.DB.QueryRowContext(ctx, query, key, fingerprint, sqliteTime(now), sqliteTime(now.Add(lockTimeout))).Scan(&key)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	var stored string
+	var response StoredResponse
+	var headers []byte
+	query = `select fingerprint, status, headers, body from idempotency_keys where key = ?`
+	err = m.DB.QueryRowContext(ctx, query, key).Scan(&stored, &response.Status, &headers, &response.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrIdempotencyKeyInFlight
+		default:
+			return nil, err
+		}
+	}
+	return storedResponse(stored, fingerprint, &response, headers)
+}
+
+func (m SQLiteIdempotencyKeyModel) SaveResponse(ctx context.Context, key string, response *StoredResponse, ttl time.Duration) error {
+	headers, err := json.Marshal(response.Headers)
+	if err != nil {
+		return err
+	}
+	query := `update idempotency_keys set status = ?, headers = ?, body = ?, expires_at = ? where key = ?`
+	_, err = m.DB.ExecContext(ctx, query, response.Status, string(headers), response.Body, sqliteTime(time.Now().Add(ttl)), key)
+	return err
+}
+
+func (m SQLiteIdempotencyKeyModel) ReleaseKey(ctx context.Context, key string) error {
+	_, err := m.DB.ExecContext(ctx, `delete from idempotency_keys where key = ? and status = 0`, key)
+	return err
+}
+
+func (m SQLiteIdempotencyKeyModel) DeleteExpiredKeys(ctx context.Context) (int64, error) {
+	result, err := m.DB.ExecContext(ctx, `delete from idempotency_keys where expires_at <= ?`, sqliteTime(time.Now()))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,63 @@
+package data
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMemoryIdempotencyKeyExpiry(t *testing.T) {
+	ctx := context.Background()
+	keys := NewMemoryIdempotencyKeyModel()
+	_, err := keys.ReserveKey(ctx, "key", "a", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = keys.SaveResponse(ctx, "key", &StoredResponse{Status: http.StatusCreated}, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	stored, err := keys.ReserveKey(ctx, "key", "b", time.Minute)
+	if err != nil || stored != nil {
+		t.Errorf("expected an expired key to be reusable but got %v, %v", stored, err)
+	}
+}
+
+func TestMemoryIdempotencyKeySweep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	keys := NewMemoryIdempotencyKeyModel()
+	keys.SweepInterval = time.Millisecond
+	_, err := keys.ReserveKey(ctx, "expired", "a", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = keys.ReserveKey(ctx, "kept", "a", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		keys.Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for {
+		keys.mu.Lock()
+		n := len(keys.keys)
+		keys.mu.Unlock()
+		if n == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if _, ok := keys.keys["kept"]; !ok || len(keys.keys) != 1 {
+		t.Errorf("expected only the unexpired key to be left but got %d keys", len(keys.keys))
+	}
+}
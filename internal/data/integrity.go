@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/rrebeiz/quickmovies/internal/i18n"
+	"github.com/rrebeiz/quickmovies/internal/validator"
+	"sort"
+	"strings"
+)
+
+// IntegrityProblem is an inconsistency found by CheckIntegrity.
+type IntegrityProblem struct {
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// orphanChecks count rows whose parent is gone, which the foreign keys rule
+// out unless they were disabled, e.g. by a SQLite connection opened without
+// the foreign_keys pragma. The queries run on Postgres and SQLite alike.
+var orphanChecks = []struct {
+	name  string
+	query string
+}{
+	{"orphaned_tokens", `select count(*) from tokens t left join users u on u.id = t.user_id where u.id is null`},
+	{"orphaned_permissions", `select count(*) from users_permissions up left join users u on u.id = up.user_id left join permissions p on p.id = up.permission_id where u.id is null or p.id is null`},
+	{"orphaned_deliveries", `select count(*) from webhook_deliveries d left join webhooks w on w.id = d.webhook_id left join outbox_events e on e.id = d.event_id where w.id is null or e.id is null`},
+}
+
+// CheckIntegrity looks for data the API would not accept or could not serve:
+// a schema older than SchemaVersion or left dirty by a failed migration,
+// movies failing validation, which may have been written before a rule was
+// added or straight to the database, and orphaned rows. It returns the
+// problems found; the error is only set when the checks could not run.
+func CheckIntegrity(ctx context.Context, db *sql.DB, movies Movies) ([]IntegrityProblem, error) {
+	problems := []IntegrityProblem{}
+
+	_, err := CheckSchema(ctx, db)
+	if err != nil {
+		// the other checks depend on the tables being there.
+		return append(problems, IntegrityProblem{Check: "schema", Message: err.Error()}), nil
+	}
+
+	all, err := movies.GetAllMovies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	for _, movie := range all {
+		v := validator.NewValidator()
+		ValidateMovie(v, movie)
+		if v.Valid() {
+			continue
+		}
+		var fields []string
+		errs := v.FirstErrors(i18n.DefaultLanguage)
+		for _, field := range v.Fields() {
+			fields = append(fields, fmt.Sprintf("%s %s", field, errs[field]))
+		}
+		problems = append(problems, IntegrityProblem{
+			Check:   "invalid_movie",
+			Message: fmt.Sprintf("movie %d: %s", movie.ID, strings.Join(fields, ", ")),
+		})
+	}
+
+	for _, check := range orphanChecks {
+		var count int
+		err := db.QueryRowContext(ctx, check.query).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			problems = append(problems, IntegrityProblem{Check: check.name, Message: fmt.Sprintf("%d rows", count)})
+		}
+	}
+	return problems, nil
+}
@@ -3,19 +3,92 @@ package data
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 )
 
 var (
-	ErrNoRecordFound = errors.New("the requested resource could not be found")
-	ErrEditConflict  = errors.New("edit conflict")
+	ErrNoRecordFound       = errors.New("the requested resource could not be found")
+	ErrEditConflict        = errors.New("edit conflict")
+	ErrDuplicateExternalID = errors.New("duplicate external id")
+	ErrIDOutOfRange        = errors.New("id out of range")
 )
 
+// checkConstraintFields maps the check constraints of the movies table to the
+// field they check.
+var checkConstraintFields = map[string]string{
+	"movies_runtime_check": "runtime",
+	"genres_length_check":  "genres",
+}
+
+// CheckViolationError is returned when the database rejects a write with a
+// check constraint, which means validation let an invalid movie through.
+type CheckViolationError struct {
+	Constraint string
+	// Field is the field checked by the constraint, or the constraint itself
+	// if it is not known.
+	Field string
+}
+
+func newCheckViolationError(constraint string) *CheckViolationError {
+	field, ok := checkConstraintFields[constraint]
+	if !ok {
+		field = constraint
+	}
+	return &CheckViolationError{Constraint: constraint, Field: field}
+}
+
+func (e *CheckViolationError) Error() string {
+	return fmt.Sprintf("check constraint %s violated", e.Constraint)
+}
+
 type Models struct {
-	Movies Movies
+	Movies          Movies
+	Events          Events
+	Webhooks        Webhooks
+	IdempotencyKeys IdempotencyKeys
+	// Users is nil for memory storage, which has no accounts.
+	Users Users
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Movies: NewMovieModel(db),
+		Movies:          NewMovieModel(db),
+		Events:          NewEventModel(db),
+		Webhooks:        NewWebhookModel(db),
+		IdempotencyKeys: NewIdempotencyKeyModel(db),
+		Users:           NewUserModel(db),
+	}
+}
+
+func NewMemoryModels(movies *MemoryMovieModel) Models {
+	return Models{
+		Movies:          movies,
+		Events:          NewMemoryEventModel(movies),
+		Webhooks:        NewMemoryWebhookModel(movies),
+		IdempotencyKeys: NewMemoryIdempotencyKeyModel(),
+	}
+}
+
+func NewSQLiteModels(db *sql.DB) Models {
+	return Models{
+		Movies:          NewSQLiteMovieModel(db),
+		Events:          NewSQLiteEventModel(db),
+		Webhooks:        NewSQLiteWebhookModel(db),
+		IdempotencyKeys: NewSQLiteIdempotencyKeyModel(db),
+		Users:           NewSQLiteUserModel(db),
+	}
+}
+
+// NewReplicatedModels reads movies through the replicas. Events, webhooks,
+// idempotency keys and users always use the primary db, since webhooks and
+// idempotency keys write on every read, a new token must work at once and a
+// replayed event must not be behind the notification of the next one.
+func NewReplicatedModels(movies *ReplicatedMovieModel, db *sql.DB) Models {
+	return Models{
+		Movies:          movies,
+		Events:          NewEventModel(db),
+		Webhooks:        NewWebhookModel(db),
+		IdempotencyKeys: NewIdempotencyKeyModel(db),
+		Users:           NewUserModel(db),
 	}
 }
@@ -3,10 +3,11 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/lib/pq"
 	"github.com/rrebeiz/quickmovies/internal/validator"
+	"reflect"
 	"time"
 )
 
@@ -14,19 +15,46 @@ type Movies interface {
 	GetMovie(ctx context.Context, id int64) (*Movie, error)
 	CreateMovie(ctx context.Context, movie *Movie) error
 	UpdateMovie(ctx context.Context, movie *Movie) error
+	// DeleteMovie removes the movie with id. The databases move it to the
+	// trash, where it stays hidden until Trash.PurgeTrash removes it for good
+	// or an upsert takes its ID or external ID.
 	DeleteMovie(ctx context.Context, id int64) error
 	GetAllMovies(ctx context.Context) ([]*Movie, error)
+	// UpsertMovie replaces the movie with movie.ID, or the movie with
+	// movie.ExternalID when movie.ID is 0, creating it if it does not exist.
+	// It reports whether the movie was created. The external ID of an existing
+	// movie is never changed. A negative movie.ID or one above MaxMovieID
+	// returns ErrIDOutOfRange.
+	UpsertMovie(ctx context.Context, movie *Movie) (bool, error)
+}
+
+// MaxMovieID is the largest ID a client may choose. It keeps IDs exact in
+// JavaScript clients and leaves the rest of the int64 range to CreateMovie,
+// so a client chosen ID cannot exhaust it.
+const MaxMovieID int64 = 1<<53 - 1
+
+// Genres are the genres a movie may have, as listed in the validate tag of
+// Movie.Genres.
+var Genres = []string{"action", "adventure", "comedy", "horror", "drama"}
+
+// Trash holds the movies deleted from a database. The memory storage deletes
+// movies outright, since it only lives as long as the process.
+type Trash interface {
+	// PurgeTrash removes the movies deleted before before for good, and
+	// returns how many there were.
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
 }
 
 type Movie struct {
-	ID        int64     `json:"id"`
-	Title     string    `json:"title"`
-	Runtime   int32     `json:"runtime"`
-	Year      int32     `json:"year"`
-	Genres    []string  `json:"genres"`
-	Version   int32     `json:"-"`
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"-"`
+	ID         int64     `json:"id"`
+	Title      string    `json:"title" validate:"required,max=500"`
+	Runtime    int32     `json:"runtime" validate:"required,positive"`
+	Year       int32     `json:"year" validate:"required,positive"`
+	Genres     []string  `json:"genres" validate:"required,min=1,max=5,unique,dive,oneof=action adventure comedy horror drama"`
+	ExternalID string    `json:"external_id,omitempty" validate:"max=255"`
+	Version    int32     `json:"-"`
+	CreatedAt  time.Time `json:"-"`
+	UpdatedAt  time.Time `json:"-"`
 }
 
 type MovieModel struct {
@@ -38,12 +66,12 @@ func NewMovieModel(db *sql.DB) MovieModel {
 }
 
 func (m MovieModel) GetMovie(ctx context.Context, id int64) (*Movie, error) {
-	query := `select id, title, runtime, year, genres, version from movies where id = $1`
+	query := `select id, title, runtime, year, genres, coalesce(external_id, ''), version from movies where id = $1 and deleted_at is null`
 	var movie Movie
 	if id <= 0 {
 		return nil, ErrNoRecordFound
 	}
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(&movie.ID, &movie.Title, &movie.Runtime, &movie.Year, pq.Array(&movie.Genres), &movie.Version)
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&movie.ID, &movie.Title, &movie.Runtime, &movie.Year, pq.Array(&movie.Genres), &movie.ExternalID, &movie.Version)
 
 	if err != nil {
 		switch {
@@ -57,7 +85,7 @@ func (m MovieModel) GetMovie(ctx context.Context, id int64) (*Movie, error) {
 }
 
 func (m MovieModel) GetAllMovies(ctx context.Context) ([]*Movie, error) {
-	query := `select id, title, runtime, year, genres from movies`
+	query := `select id, title, runtime, year, genres, coalesce(external_id, ''), version from movies where deleted_at is null`
 
 	rows, err := m.DB.QueryContext(ctx, query)
 	if err != nil {
@@ -68,7 +96,7 @@ func (m MovieModel) GetAllMovies(ctx context.Context) ([]*Movie, error) {
 	var movies []*Movie
 	for rows.Next() {
 		var movie Movie
-		err = rows.Scan(&movie.ID, &movie.Title, &movie.Runtime, &movie.Year, pq.Array(&movie.Genres))
+		err = rows.Scan(&movie.ID, &movie.Title, &movie.Runtime, &movie.Year, pq.Array(&movie.Genres), &movie.ExternalID, &movie.Version)
 		if err != nil {
 			return nil, err
 		}
@@ -81,34 +109,94 @@ func (m MovieModel) GetAllMovies(ctx context.Context) ([]*Movie, error) {
 	return movies, nil
 }
 
+// createMovieAttempts bounds how often CreateMovie draws a new ID after
+// losing it to an UpsertMovie that chose the same one.
+const createMovieAttempts = 3
+
+// CreateMovie returns ErrEditConflict if every ID it drew was taken by a
+// concurrent UpsertMovie.
 func (m MovieModel) CreateMovie(ctx context.Context, movie *Movie) error {
-	query := `insert into movies (title, runtime, year, genres) values ($1, $2, $3, $4) returning id`
+	for attempt := 1; ; attempt++ {
+		err := m.createMovie(ctx, movie)
+		if !errors.Is(err, errIDTaken) {
+			return err
+		}
+		if attempt == createMovieAttempts {
+			return ErrEditConflict
+		}
+	}
+}
+
+// errIDTaken is returned by createMovie when the ID drawn from the sequence
+// was chosen by an UpsertMovie before it moved the sequence past it.
+var errIDTaken = errors.New("movie id taken")
+
+func (m MovieModel) createMovie(ctx context.Context, movie *Movie) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `insert into movies (title, runtime, year, genres) values ($1, $2, $3, $4) returning id, version`
 	args := []interface{}{movie.Title, movie.Runtime, movie.Year, pq.Array(movie.Genres)}
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "movies_pkey":
+			return errIDTaken
+		default:
+			return pqCheckViolation(err)
+		}
+	}
 
+	err = insertEvent(ctx, tx, EventMovieCreated, movie)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (m MovieModel) UpdateMovie(ctx context.Context, movie *Movie) error {
-	query := `update movies set title = $1, runtime = $2, year = $3, genres = $4, version = version + 1 where id = $5 and version = $6 returning id, version`
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `update movies set title = $1, runtime = $2, year = $3, genres = $4, version = version + 1 where id = $5 and version = $6 and deleted_at is null returning id, version`
 	args := []interface{}{movie.Title, movie.Runtime, movie.Year, pq.Array(movie.Genres), movie.ID, movie.Version}
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.Version)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
 		default:
-			return err
+			return pqCheckViolation(err)
 		}
 	}
-	return nil
+
+	err = insertEvent(ctx, tx, EventMovieUpdated, movie)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (m MovieModel) DeleteMovie(ctx context.Context, id int64) error {
 	if id < 1 {
 		return ErrNoRecordFound
 	}
-	query := `delete from movies where id = $1`
-	res, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `update movies set deleted_at = now() where id = $1 and deleted_at is null returning id, title, runtime, year, genres, coalesce(external_id, ''), version`
+	var movie Movie
+	err = tx.QueryRowContext(ctx, query, id).Scan(&movie.ID, &movie.Title, &movie.Runtime, &movie.Year, pq.Array(&movie.Genres), &movie.ExternalID, &movie.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -118,37 +206,139 @@ func (m MovieModel) DeleteMovie(ctx context.Context, id int64) error {
 		}
 	}
 
-	rowsAffected, err := res.RowsAffected()
+	err = insertEvent(ctx, tx, EventMovieDeleted, &movie)
 	if err != nil {
 		return err
 	}
+	return tx.Commit()
+}
 
-	if rowsAffected == 0 {
-		return ErrNoRecordFound
+func (m MovieModel) UpsertMovie(ctx context.Context, movie *Movie) (bool, error) {
+	if movie.ID < 0 || movie.ID > MaxMovieID {
+		return false, ErrIDOutOfRange
+	}
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
 
+	// a trashed movie is replaced by a new one.
+	_, err = tx.ExecContext(ctx, `delete from movies where deleted_at is not null and (id = $1 or external_id = $2)`, movie.ID, movie.ExternalID)
+	if err != nil {
+		return false, err
 	}
-	return nil
-}
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
-	permittedGenres := []string{"action", "adventure", "comedy", "horror", "drama"}
-	v.Check(movie.Title != "", "title", "should not be empty")
-	v.Check(len(movie.Title) <= 500, "title", "should not be greater than 500 bytes")
+	// A new row starts at version 1 and every update bumps it, so the version
+	// tells an insert from an update.
+	byID := movie.ID != 0
+	var query string
+	args := []interface{}{movie.Title, movie.Runtime, movie.Year, pq.Array(movie.Genres), movie.ExternalID}
+	if byID {
+		query = `insert into movies (title, runtime, year, genres, external_id, id) values ($1, $2, $3, $4, nullif($5, ''), $6)
+			on conflict (id) do update set title = excluded.title, runtime = excluded.runtime, year = excluded.year, genres = excluded.genres, version = movies.version + 1, updated_at = now()
+			returning id, coalesce(external_id, ''), version`
+		args = append(args, movie.ID)
+	} else {
+		query = `insert into movies (title, runtime, year, genres, external_id) values ($1, $2, $3, $4, $5)
+			on conflict (external_id) do update set title = excluded.title, runtime = excluded.runtime, year = excluded.year, genres = excluded.genres, version = movies.version + 1, updated_at = now()
+			returning id, external_id, version`
+	}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.ExternalID, &movie.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "movies_external_id_key":
+			return false, ErrDuplicateExternalID
+		default:
+			return false, pqCheckViolation(err)
+		}
+	}
+	created := movie.Version == 1
 
-	v.Check(movie.Year != 0, "year", "should not be empty")
-	v.Check(movie.Year >= 0, "year", "should be a positive number")
+	if created && byID {
+		// keep the sequence ahead of client chosen IDs, so CreateMovie does not
+		// hand them out again. The sequence only ever moves forward, and the
+		// lock keeps two of these from racing each other back. The sequence is
+		// looked up rather than named, since a renamed table keeps its old one.
+		_, err = tx.ExecContext(ctx, `select pg_advisory_xact_lock(hashtext(pg_get_serial_sequence('movies', 'id')))`)
+		if err != nil {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, `select setval(seq::regclass, greatest((select max(id) from movies), pg_sequence_last_value(seq::regclass)))
+			from pg_get_serial_sequence('movies', 'id') as seq`)
+		if err != nil {
+			return false, err
+		}
+	}
 
-	v.Check(movie.Runtime != 0, "runtime", "should not be empty")
-	v.Check(movie.Runtime > 0, "runtime", "should be a positive number")
+	eventType := EventMovieUpdated
+	if created {
+		eventType = EventMovieCreated
+	}
+	err = insertEvent(ctx, tx, eventType, movie)
+	if err != nil {
+		return false, err
+	}
+	return created, tx.Commit()
+}
 
-	v.Check(movie.Genres != nil, "genres", "should not be empty")
-	v.Check(len(movie.Genres) >= 1, "genres", "should contain at least 1 genre")
-	v.Check(len(movie.Genres) <= 5, "genres", "should not contain more than 5 genres")
+func (m MovieModel) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	result, err := m.DB.ExecContext(ctx, `delete from movies where deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
 
-	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate genres")
+// insertEvent records a change to movie in the outbox as part of tx, and
+// notifies the other API instances on MoviesChannel once tx commits. It must
+// be the last statement before the commit.
+//
+// Sequence values are handed out before commit, so without the lock a
+// transaction holding a lower ID could commit after a higher one has been
+// read, and a client resuming from the higher one would never see it. Holding
+// the lock until the commit makes outbox IDs follow the commit order.
+func insertEvent(ctx context.Context, tx *sql.Tx, eventType string, movie *Movie) error {
+	payload, err := newEventPayload(eventType, movie)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `select pg_advisory_xact_lock(hashtext('outbox_events'))`)
+	if err != nil {
+		return err
+	}
+	query := `insert into outbox_events (event_type, movie_id, payload) values ($1, $2, $3) returning id`
+	var id int64
+	err = tx.QueryRowContext(ctx, query, eventType, movie.ID, payload).Scan(&id)
+	if err != nil {
+		return err
+	}
+	notification, err := json.Marshal(movieNotification{ID: id, Event: payload})
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `select pg_notify($1, $2)`, MoviesChannel, string(notification))
+	return err
+}
 
-	for _, genre := range movie.Genres {
-		v.Check(validator.PermittedValue(genre, permittedGenres...), "genre", fmt.Sprintf("please use the following permitted genres %s", permittedGenres))
+// pqCheckViolation turns a check constraint violation into a
+// CheckViolationError, and returns any other error as it is.
+func pqCheckViolation(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23514" {
+		return newCheckViolationError(pqErr.Constraint)
 	}
+	return err
+}
 
+func init() {
+	validator.RegisterRule("positive", "not_positive", "validation.not_positive", func(value reflect.Value) bool {
+		return value.Int() > 0
+	})
+}
+
+// ValidateMovie checks movie against the rules in the validate tags of Movie.
+func ValidateMovie(v *validator.Validator, movie *Movie) {
+	v.Struct(movie)
 }
@@ -0,0 +1,246 @@
+package data
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheNegativeTTL bounds how long a "not found" answer is remembered, so a
+// movie created through another instance shows up quickly.
+const cacheNegativeTTL = 5 * time.Second
+
+// CacheStats is a point in time view of the CachedMovieModel counters.
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Size   int   `json:"size"`
+}
+
+// CachedMovieModel is a read-through cache around another Movies
+// implementation. GetMovie results, including ErrNoRecordFound, are kept in a
+// size bounded LRU with a TTL, and concurrent misses for the same ID share a
+// single call to the wrapped implementation.
+type CachedMovieModel struct {
+	next    Movies
+	size    int
+	ttl     time.Duration
+	negTTL  time.Duration
+	hits    atomic.Int64
+	misses  atomic.Int64
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[int64]*list.Element
+	calls   map[int64]*cacheCall
+	// gen is bumped on every invalidation so a lookup that raced with a write
+	// does not put a stale movie back into the cache.
+	gen uint64
+}
+
+type cacheEntry struct {
+	id      int64
+	movie   *Movie
+	expires time.Time
+}
+
+type cacheCall struct {
+	// done is closed once movie and err are set.
+	done  chan struct{}
+	movie *Movie
+	err   error
+}
+
+func NewCachedMovieModel(next Movies, size int, ttl time.Duration) *CachedMovieModel {
+	negTTL := cacheNegativeTTL
+	if ttl < negTTL {
+		negTTL = ttl
+	}
+	return &CachedMovieModel{
+		next:    next,
+		size:    size,
+		ttl:     ttl,
+		negTTL:  negTTL,
+		lru:     list.New(),
+		entries: make(map[int64]*list.Element),
+		calls:   make(map[int64]*cacheCall),
+	}
+}
+
+func (m *CachedMovieModel) GetMovie(ctx context.Context, id int64) (*Movie, error) {
+	// a canceled request is not answered from the cache either, like from
+	// any other implementation.
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+	if id <= 0 {
+		return nil, ErrNoRecordFound
+	}
+
+	m.mu.Lock()
+	if el, ok := m.entries[id]; ok {
+		entry := el.Value.(*cacheEntry)
+		if time.Now().Before(entry.expires) {
+			m.lru.MoveToFront(el)
+			m.mu.Unlock()
+			m.hits.Add(1)
+			if entry.movie == nil {
+				return nil, ErrNoRecordFound
+			}
+			return copyMovie(entry.movie), nil
+		}
+		m.removeElement(el)
+	}
+	m.misses.Add(1)
+
+	// the lookup is shared, so it must not fail because the request that
+	// started it went away. Every caller waits for it on its own context.
+	c, ok := m.calls[id]
+	if !ok {
+		c = &cacheCall{done: make(chan struct{})}
+		m.calls[id] = c
+		go m.load(ctx, id, c, m.gen)
+	}
+	m.mu.Unlock()
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	return copyMovie(c.movie), nil
+}
+
+// load fetches the movie for the callers waiting on c and caches the result,
+// unless the movie was invalidated since gen. It keeps the values and the
+// deadline of ctx, but not its cancellation.
+func (m *CachedMovieModel) load(ctx context.Context, id int64, c *cacheCall, gen uint64) {
+	shared := context.Context(detachedContext{ctx})
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		shared, cancel = context.WithDeadline(shared, deadline)
+		defer cancel()
+	}
+	c.movie, c.err = m.next.GetMovie(shared, id)
+
+	m.mu.Lock()
+	delete(m.calls, id)
+	if gen == m.gen {
+		switch {
+		case c.err == nil:
+			m.add(id, copyMovie(c.movie), m.ttl)
+		case errors.Is(c.err, ErrNoRecordFound):
+			m.add(id, nil, m.negTTL)
+		}
+	}
+	m.mu.Unlock()
+	close(c.done)
+}
+
+// detachedContext has the values of its parent but is never canceled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (c detachedContext) Done() <-chan struct{}       { return nil }
+func (c detachedContext) Err() error                  { return nil }
+func (c detachedContext) Value(key any) any           { return c.parent.Value(key) }
+
+func (m *CachedMovieModel) GetAllMovies(ctx context.Context) ([]*Movie, error) {
+	return m.next.GetAllMovies(ctx)
+}
+
+func (m *CachedMovieModel) CreateMovie(ctx context.Context, movie *Movie) error {
+	err := m.next.CreateMovie(ctx, movie)
+	if err != nil {
+		return err
+	}
+	// the new ID may still be cached as not found.
+	m.Invalidate(movie.ID)
+	return nil
+}
+
+func (m *CachedMovieModel) UpdateMovie(ctx context.Context, movie *Movie) error {
+	defer m.Invalidate(movie.ID)
+	return m.next.UpdateMovie(ctx, movie)
+}
+
+func (m *CachedMovieModel) DeleteMovie(ctx context.Context, id int64) error {
+	defer m.Invalidate(id)
+	return m.next.DeleteMovie(ctx, id)
+}
+
+func (m *CachedMovieModel) UpsertMovie(ctx context.Context, movie *Movie) (bool, error) {
+	created, err := m.next.UpsertMovie(ctx, movie)
+	if err != nil {
+		return false, err
+	}
+	m.Invalidate(movie.ID)
+	return created, nil
+}
+
+// Invalidate drops any cached answer for id.
+func (m *CachedMovieModel) Invalidate(id int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.gen++
+	if el, ok := m.entries[id]; ok {
+		m.removeElement(el)
+	}
+}
+
+// Purge drops every cached answer, for when changes may have been missed.
+func (m *CachedMovieModel) Purge() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.gen++
+	m.lru.Init()
+	m.entries = make(map[int64]*list.Element)
+}
+
+// Stats returns the current hit and miss counters and the number of cached
+// entries.
+func (m *CachedMovieModel) Stats() CacheStats {
+	m.mu.Lock()
+	size := m.lru.Len()
+	m.mu.Unlock()
+
+	return CacheStats{
+		Hits:   m.hits.Load(),
+		Misses: m.misses.Load(),
+		Size:   size,
+	}
+}
+
+// add stores movie for id, evicting the least recently used entries once the
+// cache is full. A nil movie records a negative lookup. m.mu must be held.
+func (m *CachedMovieModel) add(id int64, movie *Movie, ttl time.Duration) {
+	if m.size <= 0 || ttl <= 0 {
+		return
+	}
+	entry := &cacheEntry{id: id, movie: movie, expires: time.Now().Add(ttl)}
+	if el, ok := m.entries[id]; ok {
+		el.Value = entry
+		m.lru.MoveToFront(el)
+		return
+	}
+	m.entries[id] = m.lru.PushFront(entry)
+	for m.lru.Len() > m.size {
+		m.removeElement(m.lru.Back())
+	}
+}
+
+// removeElement drops el from the cache. m.mu must be held.
+func (m *CachedMovieModel) removeElement(el *list.Element) {
+	m.lru.Remove(el)
+	delete(m.entries, el.Value.(*cacheEntry).id)
+}
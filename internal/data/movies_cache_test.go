@@ -0,0 +1,189 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingMovies counts GetMovie calls and can block them until release is
+// closed, so tests can observe coalesced lookups.
+type countingMovies struct {
+	Movies
+	gets    atomic.Int64
+	release chan struct{}
+}
+
+func (m *countingMovies) GetMovie(ctx context.Context, id int64) (*Movie, error) {
+	m.gets.Add(1)
+	if m.release != nil {
+		<-m.release
+	}
+	return m.Movies.GetMovie(ctx, id)
+}
+
+func newCountingMovies(t *testing.T) *countingMovies {
+	store := NewMemoryMovieModel()
+	err := store.CreateMovie(context.Background(), &Movie{Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &countingMovies{Movies: store}
+}
+
+func TestCachedMovieModelGetMovie(t *testing.T) {
+	ctx := context.Background()
+	next := newCountingMovies(t)
+	cache := NewCachedMovieModel(next, 10, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		movie, err := cache.GetMovie(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		movie.Title = "changed by the caller"
+	}
+	movie, _ := cache.GetMovie(ctx, 1)
+	if movie.Title != "test" {
+		t.Errorf("expected cached movie to be unaffected by callers but got %q", movie.Title)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetMovie(ctx, 2)
+		if !errors.Is(err, ErrNoRecordFound) {
+			t.Errorf("expected %v but got %v", ErrNoRecordFound, err)
+		}
+	}
+
+	if next.gets.Load() != 2 {
+		t.Errorf("expected 2 calls to the wrapped store but got %d", next.gets.Load())
+	}
+	stats := cache.Stats()
+	if stats.Hits != 4 || stats.Misses != 2 {
+		t.Errorf("expected 4 hits and 2 misses but got %d and %d", stats.Hits, stats.Misses)
+	}
+}
+
+func TestCachedMovieModelInvalidation(t *testing.T) {
+	ctx := context.Background()
+	next := newCountingMovies(t)
+	cache := NewCachedMovieModel(next, 10, time.Minute)
+
+	movie, _ := cache.GetMovie(ctx, 1)
+	movie.Title = "new test"
+	err := cache.UpdateMovie(ctx, movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	movie, _ = cache.GetMovie(ctx, 1)
+	if movie.Title != "new test" {
+		t.Errorf("expected %q but got %q", "new test", movie.Title)
+	}
+
+	// a negative lookup must not hide a movie created afterwards.
+	_, _ = cache.GetMovie(ctx, 2)
+	err = cache.CreateMovie(ctx, &Movie{Title: "second", Runtime: 100, Year: 2020, Genres: []string{"drama"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cache.GetMovie(ctx, 2)
+	if err != nil {
+		t.Errorf("expected created movie to be found but got %v", err)
+	}
+
+	err = cache.DeleteMovie(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cache.GetMovie(ctx, 1)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("expected %v but got %v", ErrNoRecordFound, err)
+	}
+}
+
+func TestCachedMovieModelEviction(t *testing.T) {
+	ctx := context.Background()
+	next := newCountingMovies(t)
+	cache := NewCachedMovieModel(next, 2, time.Minute)
+
+	for _, id := range []int64{1, 2, 3, 1} {
+		_, _ = cache.GetMovie(ctx, id)
+	}
+	if size := cache.Stats().Size; size != 2 {
+		t.Errorf("expected size 2 but got %d", size)
+	}
+	if next.gets.Load() != 4 {
+		t.Errorf("expected the least recently used entry to be evicted, got %d calls", next.gets.Load())
+	}
+}
+
+func TestCachedMovieModelCoalescing(t *testing.T) {
+	ctx := context.Background()
+	next := newCountingMovies(t)
+	next.release = make(chan struct{})
+	cache := NewCachedMovieModel(next, 10, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.GetMovie(ctx, 1)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	for cache.Stats().Misses < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	close(next.release)
+	wg.Wait()
+
+	if next.gets.Load() != 1 {
+		t.Errorf("expected 1 call to the wrapped store but got %d", next.gets.Load())
+	}
+}
+
+func TestCachedMovieModelCanceledLeader(t *testing.T) {
+	next := newCountingMovies(t)
+	next.release = make(chan struct{})
+	cache := NewCachedMovieModel(next, 10, time.Minute)
+
+	leader, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error)
+	go func() {
+		_, err := cache.GetMovie(leader, 1)
+		leaderErr <- err
+	}()
+	for next.gets.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	waiter := make(chan error)
+	go func() {
+		_, err := cache.GetMovie(context.Background(), 1)
+		waiter <- err
+	}()
+	for cache.Stats().Misses < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	err := <-leaderErr
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the leader to be canceled but got %v", err)
+	}
+	close(next.release)
+	err = <-waiter
+	if err != nil {
+		t.Errorf("expected the waiter to get the movie but got %s", err)
+	}
+	if next.gets.Load() != 1 {
+		t.Errorf("expected 1 call to the wrapped store but got %d", next.gets.Load())
+	}
+}
@@ -0,0 +1,130 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/lib/pq"
+	"log"
+	"sync"
+	"time"
+)
+
+// MoviesChannel is the Postgres notification channel MovieModel announces
+// changes on. The payload is a JSON movieNotification.
+const MoviesChannel = "movies"
+
+// movieNotification is an event recorded in the outbox, with its outbox ID.
+type movieNotification struct {
+	ID    int64           `json:"id"`
+	Event json.RawMessage `json:"event"`
+}
+
+// listenerPingInterval is how often an idle listener checks its connection,
+// since pq only notices a dead connection when it is used.
+const listenerPingInterval = 90 * time.Second
+
+// MovieListener holds a dedicated Postgres connection listening on
+// MoviesChannel and hands every change to its subscribers, so caches and
+// streams see the writes made through other API instances. The connection is
+// re-established with exponential backoff when it drops.
+type MovieListener struct {
+	listener *pq.Listener
+	errorLog *log.Logger
+
+	mu      sync.Mutex
+	changed []func(MovieEvent)
+	resync  []func()
+}
+
+func NewMovieListener(dsn string, minBackoff, maxBackoff time.Duration, errorLog *log.Logger) (*MovieListener, error) {
+	l := &MovieListener{errorLog: errorLog}
+	l.listener = pq.NewListener(dsn, minBackoff, maxBackoff, l.logEvent)
+	err := l.listener.Listen(MoviesChannel)
+	if err != nil {
+		l.listener.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Subscribe registers changed to be called for every change. resync, if not
+// nil, is called after a reconnect, because changes made while the listener
+// was disconnected are lost and subscribers have to assume anything changed.
+// Subscribers are called from the Run goroutine and must not block.
+func (l *MovieListener) Subscribe(changed func(MovieEvent), resync func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.changed = append(l.changed, changed)
+	if resync != nil {
+		l.resync = append(l.resync, resync)
+	}
+}
+
+// Run delivers notifications to the subscribers until ctx is cancelled.
+func (l *MovieListener) Run(ctx context.Context) {
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-l.listener.Notify:
+			l.dispatch(n)
+			ticker.Reset(listenerPingInterval)
+		case <-ticker.C:
+			go func() {
+				err := l.listener.Ping()
+				if err != nil {
+					l.errorLog.Printf("movie listener ping failed %s", err)
+				}
+			}()
+		}
+	}
+}
+
+func (l *MovieListener) Close() error {
+	return l.listener.Close()
+}
+
+// dispatch hands n to the subscribers. pq sends a nil notification after
+// re-establishing the connection.
+func (l *MovieListener) dispatch(n *pq.Notification) {
+	l.mu.Lock()
+	changed := l.changed
+	resync := l.resync
+	l.mu.Unlock()
+
+	if n == nil {
+		for _, fn := range resync {
+			fn()
+		}
+		return
+	}
+
+	var notification movieNotification
+	err := json.Unmarshal([]byte(n.Extra), &notification)
+	if err != nil {
+		l.errorLog.Printf("invalid notification on %s: %q", n.Channel, n.Extra)
+		return
+	}
+	event, err := decodeEvent(notification.ID, notification.Event)
+	if err != nil || event.Movie == nil {
+		l.errorLog.Printf("invalid notification on %s: %q", n.Channel, n.Extra)
+		return
+	}
+	for _, fn := range changed {
+		fn(event)
+	}
+}
+
+func (l *MovieListener) logEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventDisconnected:
+		l.errorLog.Printf("movie listener disconnected %s", err)
+	case pq.ListenerEventConnectionAttemptFailed:
+		l.errorLog.Printf("movie listener failed to reconnect %s", err)
+	case pq.ListenerEventReconnected:
+		l.errorLog.Printf("movie listener reconnected")
+	}
+}
@@ -0,0 +1,67 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/lib/pq"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestMovieListenerDispatch(t *testing.T) {
+	l := &MovieListener{errorLog: log.New(io.Discard, "", 0)}
+	var changed []MovieEvent
+	resyncs := 0
+	l.Subscribe(func(event MovieEvent) {
+		changed = append(changed, event)
+	}, func() {
+		resyncs++
+	})
+
+	payload, err := newEventPayload(EventMovieUpdated, &Movie{ID: 3, Title: "test", Version: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notification, err := json.Marshal(movieNotification{ID: 7, Event: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.dispatch(&pq.Notification{Channel: MoviesChannel, Extra: string(notification)})
+	l.dispatch(&pq.Notification{Channel: MoviesChannel, Extra: string(payload)})
+	l.dispatch(&pq.Notification{Channel: MoviesChannel, Extra: "not json"})
+	l.dispatch(nil)
+
+	if len(changed) != 1 || changed[0].ID != 7 || changed[0].Movie.ID != 3 || changed[0].Version != 2 || changed[0].Type != EventMovieUpdated {
+		t.Errorf("expected event 7 updating movie 3 to version 2 but got %+v", changed)
+	}
+	if resyncs != 1 {
+		t.Errorf("expected 1 resync after the reconnect but got %d", resyncs)
+	}
+}
+
+func TestCachedMovieModelFollowsListener(t *testing.T) {
+	ctx := context.Background()
+	next := newCountingMovies(t)
+	cache := NewCachedMovieModel(next, 10, time.Minute)
+	l := &MovieListener{errorLog: log.New(io.Discard, "", 0)}
+	l.Subscribe(func(event MovieEvent) {
+		cache.Invalidate(event.Movie.ID)
+	}, cache.Purge)
+
+	_, _ = cache.GetMovie(ctx, 1)
+	payload, _ := newEventPayload(EventMovieDeleted, &Movie{ID: 1})
+	notification, _ := json.Marshal(movieNotification{ID: 1, Event: payload})
+	l.dispatch(&pq.Notification{Channel: MoviesChannel, Extra: string(notification)})
+	_, _ = cache.GetMovie(ctx, 1)
+	l.dispatch(nil)
+	_, _ = cache.GetMovie(ctx, 1)
+
+	if next.gets.Load() != 3 {
+		t.Errorf("expected every notification to invalidate the cache but the wrapped store was called %d times", next.gets.Load())
+	}
+	if cache.Stats().Size != 1 {
+		t.Errorf("expected 1 cached movie but got %d", cache.Stats().Size)
+	}
+}
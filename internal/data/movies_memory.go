@@ -0,0 +1,352 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryEventWindow is how many of the newest events the in-memory outbox
+// keeps once they have been fanned out to the webhooks.
+const memoryEventWindow = 10000
+
+// MemoryMovieModel is an in-memory implementation of Movies. It is safe for
+// concurrent use and is meant for local development and demos where running
+// Postgres is not practical.
+type MemoryMovieModel struct {
+	mu     sync.RWMutex
+	nextID int64
+	movies map[int64]*Movie
+
+	// events is the in-memory outbox. MemoryWebhookModel fans out the events
+	// after the first fanned ones, and MemoryEventModel reads all of them.
+	// Fanned out events beyond the newest eventWindow are dropped.
+	nextEventID int64
+	events      []*Event
+	fanned      int
+	eventWindow int
+}
+
+func NewMemoryMovieModel() *MemoryMovieModel {
+	return &MemoryMovieModel{
+		nextID:      1,
+		movies:      make(map[int64]*Movie),
+		nextEventID: 1,
+		eventWindow: memoryEventWindow,
+	}
+}
+
+func (m *MemoryMovieModel) GetMovie(ctx context.Context, id int64) (*Movie, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+	if id <= 0 {
+		return nil, ErrNoRecordFound
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	movie, ok := m.movies[id]
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	return copyMovie(movie), nil
+}
+
+func (m *MemoryMovieModel) GetAllMovies(ctx context.Context) ([]*Movie, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	movies := make([]*Movie, 0, len(m.movies))
+	for _, movie := range m.movies {
+		movies = append(movies, copyMovie(movie))
+	}
+	sort.Slice(movies, func(i, j int) bool {
+		return movies[i].ID < movies[j].ID
+	})
+	return movies, nil
+}
+
+func (m *MemoryMovieModel) CreateMovie(ctx context.Context, movie *Movie) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	movie.ID = m.nextID
+	movie.Version = 1
+	movie.CreatedAt = now
+	movie.UpdatedAt = now
+	m.nextID++
+
+	m.movies[movie.ID] = copyMovie(movie)
+	return m.recordEvent(EventMovieCreated, movie)
+}
+
+func (m *MemoryMovieModel) UpdateMovie(ctx context.Context, movie *Movie) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.movies[movie.ID]
+	if !ok || stored.Version != movie.Version {
+		return ErrEditConflict
+	}
+
+	movie.Version++
+	movie.CreatedAt = stored.CreatedAt
+	movie.UpdatedAt = time.Now()
+	m.movies[movie.ID] = copyMovie(movie)
+	return m.recordEvent(EventMovieUpdated, movie)
+}
+
+func (m *MemoryMovieModel) DeleteMovie(ctx context.Context, id int64) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	if id < 1 {
+		return ErrNoRecordFound
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	movie, ok := m.movies[id]
+	if !ok {
+		return ErrNoRecordFound
+	}
+	delete(m.movies, id)
+	return m.recordEvent(EventMovieDeleted, movie)
+}
+
+func (m *MemoryMovieModel) UpsertMovie(ctx context.Context, movie *Movie) (bool, error) {
+	err := ctx.Err()
+	if err != nil {
+		return false, err
+	}
+	if movie.ID < 0 || movie.ID > MaxMovieID {
+		return false, ErrIDOutOfRange
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var stored *Movie
+	if movie.ID != 0 {
+		stored = m.movies[movie.ID]
+	} else {
+		stored = m.findExternalID(movie.ExternalID)
+	}
+
+	now := time.Now()
+	if stored == nil {
+		if movie.ExternalID != "" && m.findExternalID(movie.ExternalID) != nil {
+			return false, ErrDuplicateExternalID
+		}
+		if movie.ID == 0 {
+			movie.ID = m.nextID
+		}
+		if movie.ID >= m.nextID {
+			m.nextID = movie.ID + 1
+		}
+		movie.Version = 1
+		movie.CreatedAt = now
+		movie.UpdatedAt = now
+		m.movies[movie.ID] = copyMovie(movie)
+		return true, m.recordEvent(EventMovieCreated, movie)
+	}
+
+	movie.ID = stored.ID
+	movie.ExternalID = stored.ExternalID
+	movie.Version = stored.Version + 1
+	movie.CreatedAt = stored.CreatedAt
+	movie.UpdatedAt = now
+	m.movies[movie.ID] = copyMovie(movie)
+	return false, m.recordEvent(EventMovieUpdated, movie)
+}
+
+// findExternalID returns the movie with the given external ID, if any. The
+// caller must hold m.mu.
+func (m *MemoryMovieModel) findExternalID(externalID string) *Movie {
+	if externalID == "" {
+		return nil
+	}
+	for _, movie := range m.movies {
+		if movie.ExternalID == externalID {
+			return movie
+		}
+	}
+	return nil
+}
+
+// recordEvent appends a change to the outbox. The caller must hold m.mu.
+func (m *MemoryMovieModel) recordEvent(eventType string, movie *Movie) error {
+	payload, err := newEventPayload(eventType, movie)
+	if err != nil {
+		return err
+	}
+	m.events = append(m.events, &Event{
+		ID:        m.nextEventID,
+		Type:      eventType,
+		MovieID:   movie.ID,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	})
+	m.nextEventID++
+
+	// drop the old events in one go once they make up half of the outbox,
+	// copying the rest so the dropped ones can be freed.
+	excess := len(m.events) - m.eventWindow
+	if excess > m.fanned {
+		excess = m.fanned
+	}
+	if excess > 0 && excess >= len(m.events)/2 {
+		m.events = append([]*Event(nil), m.events[excess:]...)
+		m.fanned -= excess
+	}
+	return nil
+}
+
+// takeEvents returns up to limit of the oldest outbox events that were not
+// taken yet.
+func (m *MemoryMovieModel) takeEvents(limit int) []*Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if limit > len(m.events)-m.fanned {
+		limit = len(m.events) - m.fanned
+	}
+	events := m.events[m.fanned : m.fanned+limit]
+	m.fanned += limit
+	return events
+}
+
+// memorySnapshot is the on-disk format used by LoadSnapshot and SaveSnapshot.
+// Movie hides its version and timestamps from JSON, so they are stored here
+// explicitly.
+type memorySnapshot struct {
+	NextID int64            `json:"next_id"`
+	Movies []snapshotRecord `json:"movies"`
+}
+
+type snapshotRecord struct {
+	ID         int64     `json:"id"`
+	Title      string    `json:"title"`
+	Runtime    int32     `json:"runtime"`
+	Year       int32     `json:"year"`
+	Genres     []string  `json:"genres"`
+	ExternalID string    `json:"external_id,omitempty"`
+	Version    int32     `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// LoadSnapshot replaces the contents of the store with the snapshot at path.
+// A missing file is not an error, so a fresh deployment starts out empty.
+func (m *MemoryMovieModel) LoadSnapshot(path string) error {
+	js, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var snapshot memorySnapshot
+	err = json.Unmarshal(js, &snapshot)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.movies = make(map[int64]*Movie, len(snapshot.Movies))
+	m.nextID = 1
+	for _, r := range snapshot.Movies {
+		m.movies[r.ID] = &Movie{
+			ID:         r.ID,
+			Title:      r.Title,
+			Runtime:    r.Runtime,
+			Year:       r.Year,
+			Genres:     r.Genres,
+			ExternalID: r.ExternalID,
+			Version:    r.Version,
+			CreatedAt:  r.CreatedAt,
+			UpdatedAt:  r.UpdatedAt,
+		}
+		if r.ID >= m.nextID {
+			m.nextID = r.ID + 1
+		}
+	}
+	if snapshot.NextID > m.nextID {
+		m.nextID = snapshot.NextID
+	}
+	return nil
+}
+
+// SaveSnapshot writes the contents of the store to path. The snapshot is
+// written to a temporary file first and renamed into place, so a crash while
+// saving never leaves a truncated snapshot behind.
+func (m *MemoryMovieModel) SaveSnapshot(path string) error {
+	m.mu.RLock()
+	snapshot := memorySnapshot{
+		NextID: m.nextID,
+		Movies: make([]snapshotRecord, 0, len(m.movies)),
+	}
+	for _, movie := range m.movies {
+		snapshot.Movies = append(snapshot.Movies, snapshotRecord{
+			ID:         movie.ID,
+			Title:      movie.Title,
+			Runtime:    movie.Runtime,
+			Year:       movie.Year,
+			Genres:     movie.Genres,
+			ExternalID: movie.ExternalID,
+			Version:    movie.Version,
+			CreatedAt:  movie.CreatedAt,
+			UpdatedAt:  movie.UpdatedAt,
+		})
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(snapshot.Movies, func(i, j int) bool {
+		return snapshot.Movies[i].ID < snapshot.Movies[j].ID
+	})
+
+	js, err := json.MarshalIndent(snapshot, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, js, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// copyMovie returns a deep copy of movie so callers can never mutate the
+// records held by the store.
+func copyMovie(movie *Movie) *Movie {
+	c := *movie
+	if movie.Genres != nil {
+		c.Genres = make([]string, len(movie.Genres))
+		copy(c.Genres, movie.Genres)
+	}
+	return &c
+}
@@ -0,0 +1,178 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryMovieModelCRUD(t *testing.T) {
+	m := NewMemoryMovieModel()
+	ctx := context.Background()
+
+	first := &Movie{Title: "first", Runtime: 100, Year: 2020, Genres: []string{"action"}}
+	second := &Movie{Title: "second", Runtime: 90, Year: 2021, Genres: []string{"drama"}}
+	for _, movie := range []*Movie{first, second} {
+		err := m.CreateMovie(ctx, movie)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if first.ID != 1 || second.ID != 2 || first.Version != 1 {
+		t.Errorf("expected ids 1 and 2 at version 1 but got %d and %d at version %d", first.ID, second.ID, first.Version)
+	}
+
+	stored, err := m.GetMovie(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Title != "first" || !reflect.DeepEqual(stored.Genres, []string{"action"}) {
+		t.Errorf("expected the first movie but got %+v", stored)
+	}
+	stored.Genres[0] = "changed"
+	again, _ := m.GetMovie(ctx, first.ID)
+	if again.Genres[0] != "action" {
+		t.Errorf("expected the stored movie to be a copy but got genres %v", again.Genres)
+	}
+
+	stored.Title = "updated"
+	err = m.UpdateMovie(ctx, stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Version != 2 {
+		t.Errorf("expected version 2 but got %d", stored.Version)
+	}
+	stale := *again
+	err = m.UpdateMovie(ctx, &stale)
+	if !errors.Is(err, ErrEditConflict) {
+		t.Errorf("expected an edit conflict for a stale version but got %v", err)
+	}
+
+	err = m.DeleteMovie(ctx, second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	movies, err := m.GetAllMovies(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) != 1 || movies[0].Title != "updated" {
+		t.Errorf("expected only the updated movie but got %+v", movies)
+	}
+}
+
+func TestMemoryMovieModelNotFound(t *testing.T) {
+	m := NewMemoryMovieModel()
+	ctx := context.Background()
+	err := m.CreateMovie(ctx, &Movie{Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		call     func() error
+		expected error
+	}{
+		{"get zero test", func() error { _, err := m.GetMovie(ctx, 0); return err }, ErrNoRecordFound},
+		{"get missing test", func() error { _, err := m.GetMovie(ctx, 2); return err }, ErrNoRecordFound},
+		{"delete negative test", func() error { return m.DeleteMovie(ctx, -1) }, ErrNoRecordFound},
+		{"delete missing test", func() error { return m.DeleteMovie(ctx, 2) }, ErrNoRecordFound},
+		{"update missing test", func() error { return m.UpdateMovie(ctx, &Movie{ID: 2, Title: "test", Version: 1}) }, ErrEditConflict},
+	}
+	for _, e := range tests {
+		err := e.call()
+		if !errors.Is(err, e.expected) {
+			t.Errorf("%s: expected %v but got %v", e.name, e.expected, err)
+		}
+	}
+}
+
+func TestMemoryMovieModelSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "movies.json")
+
+	empty := NewMemoryMovieModel()
+	err := empty.LoadSnapshot(path)
+	if err != nil {
+		t.Errorf("expected a missing snapshot to be ignored but got %s", err)
+	}
+
+	m := NewMemoryMovieModel()
+	for _, title := range []string{"first", "second"} {
+		err := m.CreateMovie(ctx, &Movie{Title: title, Runtime: 100, Year: 2020, Genres: []string{"action"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = m.DeleteMovie(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.SaveSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewMemoryMovieModel()
+	err = loaded.LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	movie, err := loaded.GetMovie(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.Title != "first" || movie.Version != 1 || movie.CreatedAt.IsZero() {
+		t.Errorf("expected the first movie with its version and timestamps but got %+v", movie)
+	}
+	next := &Movie{Title: "third", Runtime: 100, Year: 2020, Genres: []string{"action"}}
+	err = loaded.CreateMovie(ctx, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != 3 {
+		t.Errorf("expected the deleted id not to be reused but got id %d", next.ID)
+	}
+}
+
+func TestMemoryMovieModelEventWindow(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryMovieModel()
+	m.eventWindow = 4
+	events := NewMemoryEventModel(m)
+
+	create := func(n int) {
+		for i := 0; i < n; i++ {
+			err := m.CreateMovie(ctx, &Movie{Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	create(10)
+	if len(m.events) != 10 {
+		t.Errorf("expected events not fanned out yet to be kept but got %d events", len(m.events))
+	}
+
+	m.takeEvents(10)
+	create(1)
+	first, _ := events.FirstEventID(ctx)
+	last, _ := events.LastEventID(ctx)
+	if first != 8 || last != 11 || len(m.events) != 4 {
+		t.Errorf("expected events 8 to 11 to be kept but got %d events from %d to %d", len(m.events), first, last)
+	}
+	replay, err := events.GetEventsAfter(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replay) != 4 || replay[0].ID != 8 {
+		t.Errorf("expected to read events 8 to 11 but got %+v", replay)
+	}
+	if taken := m.takeEvents(10); len(taken) != 1 || taken[0].ID != 11 {
+		t.Errorf("expected only event 11 left to fan out but got %+v", taken)
+	}
+}
@@ -0,0 +1,92 @@
+package data
+
+import (
+	"context"
+	"sync"
+)
+
+// notifyBatch is how many outbox events NotifyingMovieModel reads at a time.
+const notifyBatch = 100
+
+// NotifyingMovieModel wraps a Movies implementation and, after every
+// successful write, calls Notify with the events recorded in the outbox since
+// the last call, so in-process subscribers hear about changes no matter which
+// API made them. It is meant for storage that cannot announce its changes,
+// where this process makes every write and the events are read in order.
+type NotifyingMovieModel struct {
+	Movies
+	Events Events
+	Notify func(MovieEvent)
+
+	mu     sync.Mutex
+	lastID int64
+}
+
+// NewNotifyingMovieModel starts after the newest event already in the
+// outbox.
+func NewNotifyingMovieModel(ctx context.Context, movies Movies, events Events, notify func(MovieEvent)) (*NotifyingMovieModel, error) {
+	lastID, err := events.LastEventID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &NotifyingMovieModel{Movies: movies, Events: events, Notify: notify, lastID: lastID}, nil
+}
+
+func (m *NotifyingMovieModel) CreateMovie(ctx context.Context, movie *Movie) error {
+	err := m.Movies.CreateMovie(ctx, movie)
+	if err != nil {
+		return err
+	}
+	m.notify(ctx)
+	return nil
+}
+
+func (m *NotifyingMovieModel) UpdateMovie(ctx context.Context, movie *Movie) error {
+	err := m.Movies.UpdateMovie(ctx, movie)
+	if err != nil {
+		return err
+	}
+	m.notify(ctx)
+	return nil
+}
+
+func (m *NotifyingMovieModel) UpsertMovie(ctx context.Context, movie *Movie) (bool, error) {
+	created, err := m.Movies.UpsertMovie(ctx, movie)
+	if err != nil {
+		return false, err
+	}
+	m.notify(ctx)
+	return created, nil
+}
+
+func (m *NotifyingMovieModel) DeleteMovie(ctx context.Context, id int64) error {
+	err := m.Movies.DeleteMovie(ctx, id)
+	if err != nil {
+		return err
+	}
+	m.notify(ctx)
+	return nil
+}
+
+// notify calls Notify with the events after lastID. The write has succeeded
+// by then, so the outbox is read even if ctx is canceled, and an error is not
+// reported: the events are picked up after the next write instead.
+func (m *NotifyingMovieModel) notify(ctx context.Context) {
+	ctx = detachedContext{ctx}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+		events, err := m.Events.GetEventsAfter(ctx, m.lastID, notifyBatch)
+		if err != nil {
+			return
+		}
+		for _, event := range events {
+			m.Notify(event)
+			m.lastID = event.ID
+		}
+		if len(events) < notifyBatch {
+			return
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	replicaCheckInterval = 5 * time.Second
+	replicaCheckTimeout  = time.Second
+)
+
+type clientContextKey struct{}
+
+// WithClient returns a copy of ctx tagged with the identity of the client
+// making the request. ReplicatedMovieModel uses it to give each client a read
+// your writes window on the primary.
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// ClientFromContext returns the client identity set by WithClient, or "".
+func ClientFromContext(ctx context.Context) string {
+	client, _ := ctx.Value(clientContextKey{}).(string)
+	return client
+}
+
+// Pinger is implemented by *sql.DB and is used to health check replicas.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Replica is a read-only copy of the primary database.
+type Replica struct {
+	Movies Movies
+	DB     Pinger
+}
+
+type replicaState struct {
+	Replica
+	healthy atomic.Bool
+}
+
+// ReplicatedMovieModel sends writes to the primary and spreads GetMovie and
+// GetAllMovies round-robin over the healthy replicas. After a write, reads from
+// the same client stick to the primary for the configured window so the client
+// always sees its own changes. When no replica is healthy, reads go to the
+// primary. A read that fails on a replica is retried on the primary and the
+// replica is left out until its next health check passes.
+type ReplicatedMovieModel struct {
+	primary  Movies
+	replicas []*replicaState
+	window   time.Duration
+	next     atomic.Uint64
+
+	mu     sync.Mutex
+	writes map[string]time.Time
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewReplicatedMovieModel checks the replicas once and then keeps checking
+// them in the background until Close is called.
+func NewReplicatedMovieModel(primary Movies, replicas []Replica, window time.Duration) *ReplicatedMovieModel {
+	m := &ReplicatedMovieModel{
+		primary: primary,
+		window:  window,
+		writes:  make(map[string]time.Time),
+		done:    make(chan struct{}),
+	}
+	for _, r := range replicas {
+		m.replicas = append(m.replicas, &replicaState{Replica: r})
+	}
+	m.checkReplicas()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(replicaCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.checkReplicas()
+				m.pruneWrites()
+			case <-m.done:
+				return
+			}
+		}
+	}()
+	return m
+}
+
+// Close stops the background health checks. It does not close the databases.
+func (m *ReplicatedMovieModel) Close() {
+	close(m.done)
+	m.wg.Wait()
+}
+
+func (m *ReplicatedMovieModel) GetMovie(ctx context.Context, id int64) (*Movie, error) {
+	reader, replica := m.reader(ctx)
+	movie, err := reader.GetMovie(ctx, id)
+	if m.failover(ctx, replica, err) {
+		return m.primary.GetMovie(ctx, id)
+	}
+	return movie, err
+}
+
+func (m *ReplicatedMovieModel) GetAllMovies(ctx context.Context) ([]*Movie, error) {
+	reader, replica := m.reader(ctx)
+	movies, err := reader.GetAllMovies(ctx)
+	if m.failover(ctx, replica, err) {
+		return m.primary.GetAllMovies(ctx)
+	}
+	return movies, err
+}
+
+func (m *ReplicatedMovieModel) CreateMovie(ctx context.Context, movie *Movie) error {
+	m.recordWrite(ctx)
+	return m.primary.CreateMovie(ctx, movie)
+}
+
+func (m *ReplicatedMovieModel) UpdateMovie(ctx context.Context, movie *Movie) error {
+	m.recordWrite(ctx)
+	return m.primary.UpdateMovie(ctx, movie)
+}
+
+func (m *ReplicatedMovieModel) DeleteMovie(ctx context.Context, id int64) error {
+	m.recordWrite(ctx)
+	return m.primary.DeleteMovie(ctx, id)
+}
+
+func (m *ReplicatedMovieModel) UpsertMovie(ctx context.Context, movie *Movie) (bool, error) {
+	m.recordWrite(ctx)
+	return m.primary.UpsertMovie(ctx, movie)
+}
+
+// reader picks the implementation that should serve a read for the client in
+// ctx, along with the replica it belongs to, or nil for the primary.
+func (m *ReplicatedMovieModel) reader(ctx context.Context) (Movies, *replicaState) {
+	if client := ClientFromContext(ctx); client != "" {
+		m.mu.Lock()
+		last, ok := m.writes[client]
+		m.mu.Unlock()
+		if ok && time.Since(last) < m.window {
+			return m.primary, nil
+		}
+	}
+
+	healthy := make([]*replicaState, 0, len(m.replicas))
+	for _, r := range m.replicas {
+		if r.healthy.Load() {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return m.primary, nil
+	}
+	r := healthy[m.next.Add(1)%uint64(len(healthy))]
+	return r.Movies, r
+}
+
+// failover reports whether a read that failed with err on replica should be
+// retried on the primary, and if so marks the replica unhealthy. A missing
+// record or a cancelled request is not the replica's fault.
+func (m *ReplicatedMovieModel) failover(ctx context.Context, replica *replicaState, err error) bool {
+	if replica == nil || err == nil || errors.Is(err, ErrNoRecordFound) || ctx.Err() != nil {
+		return false
+	}
+	replica.healthy.Store(false)
+	return true
+}
+
+func (m *ReplicatedMovieModel) recordWrite(ctx context.Context) {
+	client := ClientFromContext(ctx)
+	if client == "" || m.window <= 0 {
+		return
+	}
+	m.mu.Lock()
+	m.writes[client] = time.Now()
+	m.mu.Unlock()
+}
+
+// pruneWrites forgets clients whose read your writes window has passed.
+func (m *ReplicatedMovieModel) pruneWrites() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for client, last := range m.writes {
+		if time.Since(last) >= m.window {
+			delete(m.writes, client)
+		}
+	}
+}
+
+func (m *ReplicatedMovieModel) checkReplicas() {
+	for _, r := range m.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), replicaCheckTimeout)
+		err := r.DB.PingContext(ctx)
+		cancel()
+		r.healthy.Store(err == nil)
+	}
+}
@@ -0,0 +1,132 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type namedMovies struct {
+	Movies
+	name  string
+	reads *[]string
+	err   error
+}
+
+func (m namedMovies) GetMovie(ctx context.Context, id int64) (*Movie, error) {
+	*m.reads = append(*m.reads, m.name)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.Movies.GetMovie(ctx, id)
+}
+
+type fakePinger struct {
+	err error
+}
+
+func (p *fakePinger) PingContext(ctx context.Context) error {
+	return p.err
+}
+
+func TestReplicatedMovieModelRouting(t *testing.T) {
+	var reads []string
+	store := NewMemoryMovieModel()
+	primary := namedMovies{Movies: store, name: "primary", reads: &reads}
+	down := &fakePinger{err: errors.New("connection refused")}
+	replicas := []Replica{
+		{Movies: namedMovies{Movies: store, name: "replica1", reads: &reads}, DB: &fakePinger{}},
+		{Movies: namedMovies{Movies: store, name: "replica2", reads: &reads}, DB: &fakePinger{}},
+		{Movies: namedMovies{Movies: store, name: "replica3", reads: &reads}, DB: down},
+	}
+	m := NewReplicatedMovieModel(primary, replicas, time.Minute)
+	defer m.Close()
+
+	ctx := WithClient(context.Background(), "10.0.0.1")
+	other := WithClient(context.Background(), "10.0.0.2")
+
+	for i := 0; i < 4; i++ {
+		_, _ = m.GetMovie(ctx, 1)
+	}
+	err := m.CreateMovie(ctx, &Movie{Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = m.GetMovie(ctx, 1)
+	_, _ = m.GetMovie(other, 1)
+
+	expected := []string{"replica2", "replica1", "replica2", "replica1", "primary", "replica2"}
+	if len(reads) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, reads)
+	}
+	for i := range expected {
+		if reads[i] != expected[i] {
+			t.Errorf("read %d: expected %s but got %s", i, expected[i], reads[i])
+		}
+	}
+}
+
+func TestReplicatedMovieModelFallback(t *testing.T) {
+	var reads []string
+	store := NewMemoryMovieModel()
+	primary := namedMovies{Movies: store, name: "primary", reads: &reads}
+	replica := &fakePinger{}
+	m := NewReplicatedMovieModel(primary, []Replica{
+		{Movies: namedMovies{Movies: store, name: "replica", reads: &reads}, DB: replica},
+	}, time.Minute)
+	defer m.Close()
+
+	replica.err = errors.New("connection refused")
+	m.checkReplicas()
+	_, _ = m.GetMovie(context.Background(), 1)
+
+	if len(reads) != 1 || reads[0] != "primary" {
+		t.Errorf("expected the read to fall back to the primary but got %v", reads)
+	}
+}
+
+func TestReplicatedMovieModelReadError(t *testing.T) {
+	var reads []string
+	store := NewMemoryMovieModel()
+	err := store.CreateMovie(context.Background(), &Movie{Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary := namedMovies{Movies: store, name: "primary", reads: &reads}
+	broken := namedMovies{Movies: store, name: "broken", reads: &reads, err: errors.New("connection reset by peer")}
+	m := NewReplicatedMovieModel(primary, []Replica{
+		{Movies: namedMovies{Movies: store, name: "replica", reads: &reads}, DB: &fakePinger{}},
+		{Movies: broken, DB: &fakePinger{}},
+	}, time.Minute)
+	defer m.Close()
+
+	tests := []struct {
+		name          string
+		id            int64
+		expectedReads []string
+		expectedErr   error
+	}{
+		{"retried on primary test", 1, []string{"broken", "primary"}, nil},
+		{"broken replica skipped test", 1, []string{"replica"}, nil},
+		{"not found test", 2, []string{"replica"}, ErrNoRecordFound},
+	}
+
+	for _, e := range tests {
+		reads = nil
+		_, err := m.GetMovie(context.Background(), e.id)
+		if !errors.Is(err, e.expectedErr) {
+			t.Errorf("%s: expected %v but got %v", e.name, e.expectedErr, err)
+		}
+		if len(reads) != len(e.expectedReads) {
+			t.Errorf("%s: expected %v but got %v", e.name, e.expectedReads, reads)
+			continue
+		}
+		for i := range reads {
+			if reads[i] != e.expectedReads[i] {
+				t.Errorf("%s: expected %v but got %v", e.name, e.expectedReads, reads)
+				break
+			}
+		}
+	}
+}
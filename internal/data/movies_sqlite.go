@@ -0,0 +1,257 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// sqliteCheckRX finds the constraint in the error of a failed check, such as
+// "CHECK constraint failed: movies_runtime_check".
+var sqliteCheckRX = regexp.MustCompile(`CHECK constraint failed: (\w+)`)
+
+// SQLiteMovieModel is a Movies implementation backed by SQLite. Genres are
+// stored as a JSON array since SQLite has no array type.
+type SQLiteMovieModel struct {
+	DB *sql.DB
+}
+
+func NewSQLiteMovieModel(db *sql.DB) SQLiteMovieModel {
+	return SQLiteMovieModel{DB: db}
+}
+
+func (m SQLiteMovieModel) GetMovie(ctx context.Context, id int64) (*Movie, error) {
+	query := `select id, title, runtime, year, genres, coalesce(external_id, ''), version from movies where id = ? and deleted_at is null`
+	var movie Movie
+	var genres []byte
+	if id <= 0 {
+		return nil, ErrNoRecordFound
+	}
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&movie.ID, &movie.Title, &movie.Runtime, &movie.Year, &genres, &movie.ExternalID, &movie.Version)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	err = json.Unmarshal(genres, &movie.Genres)
+	if err != nil {
+		return nil, err
+	}
+	return &movie, nil
+}
+
+func (m SQLiteMovieModel) GetAllMovies(ctx context.Context) ([]*Movie, error) {
+	query := `select id, title, runtime, year, genres, coalesce(external_id, ''), version from movies where deleted_at is null`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movies []*Movie
+	for rows.Next() {
+		var movie Movie
+		var genres []byte
+		err = rows.Scan(&movie.ID, &movie.Title, &movie.Runtime, &movie.Year, &genres, &movie.ExternalID, &movie.Version)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(genres, &movie.Genres)
+		if err != nil {
+			return nil, err
+		}
+		movies = append(movies, &movie)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
+
+func (m SQLiteMovieModel) CreateMovie(ctx context.Context, movie *Movie) error {
+	genres, err := json.Marshal(movie.Genres)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `insert into movies (title, runtime, year, genres) values (?, ?, ?, ?) returning id, version`
+	args := []interface{}{movie.Title, movie.Runtime, movie.Year, string(genres)}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.Version)
+	if err != nil {
+		return sqliteCheckViolation(err)
+	}
+
+	err = insertSQLiteEvent(ctx, tx, EventMovieCreated, movie)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m SQLiteMovieModel) UpdateMovie(ctx context.Context, movie *Movie) error {
+	genres, err := json.Marshal(movie.Genres)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `update movies set title = ?, runtime = ?, year = ?, genres = ?, version = version + 1, updated_at = current_timestamp where id = ? and version = ? and deleted_at is null returning id, version`
+	args := []interface{}{movie.Title, movie.Runtime, movie.Year, string(genres), movie.ID, movie.Version}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return sqliteCheckViolation(err)
+		}
+	}
+
+	err = insertSQLiteEvent(ctx, tx, EventMovieUpdated, movie)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m SQLiteMovieModel) DeleteMovie(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrNoRecordFound
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `update movies set deleted_at = ? where id = ? and deleted_at is null returning id, title, runtime, year, genres, coalesce(external_id, ''), version`
+	var movie Movie
+	var genres []byte
+	err = tx.QueryRowContext(ctx, query, sqliteTime(time.Now()), id).Scan(&movie.ID, &movie.Title, &movie.Runtime, &movie.Year, &genres, &movie.ExternalID, &movie.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNoRecordFound
+		default:
+			return err
+		}
+	}
+	err = json.Unmarshal(genres, &movie.Genres)
+	if err != nil {
+		return err
+	}
+
+	err = insertSQLiteEvent(ctx, tx, EventMovieDeleted, &movie)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m SQLiteMovieModel) UpsertMovie(ctx context.Context, movie *Movie) (bool, error) {
+	if movie.ID < 0 || movie.ID > MaxMovieID {
+		return false, ErrIDOutOfRange
+	}
+	genres, err := json.Marshal(movie.Genres)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// a trashed movie is replaced by a new one.
+	_, err = tx.ExecContext(ctx, `delete from movies where deleted_at is not null and (id = ? or external_id = ?)`, movie.ID, movie.ExternalID)
+	if err != nil {
+		return false, err
+	}
+
+	// A new row starts at version 1 and every update bumps it, so the version
+	// tells an insert from an update.
+	var query string
+	args := []interface{}{movie.Title, movie.Runtime, movie.Year, string(genres), movie.ExternalID}
+	if movie.ID != 0 {
+		query = `insert into movies (title, runtime, year, genres, external_id, id) values (?, ?, ?, ?, nullif(?, ''), ?)
+			on conflict (id) do update set title = excluded.title, runtime = excluded.runtime, year = excluded.year, genres = excluded.genres, version = movies.version + 1, updated_at = current_timestamp
+			returning id, coalesce(external_id, ''), version`
+		args = append(args, movie.ID)
+	} else {
+		query = `insert into movies (title, runtime, year, genres, external_id) values (?, ?, ?, ?, ?)
+			on conflict (external_id) do update set title = excluded.title, runtime = excluded.runtime, year = excluded.year, genres = excluded.genres, version = movies.version + 1, updated_at = current_timestamp
+			returning id, external_id, version`
+	}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.ExternalID, &movie.Version)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "UNIQUE constraint failed: movies.external_id"):
+			return false, ErrDuplicateExternalID
+		default:
+			return false, sqliteCheckViolation(err)
+		}
+	}
+	created := movie.Version == 1
+
+	eventType := EventMovieUpdated
+	if created {
+		eventType = EventMovieCreated
+	}
+	err = insertSQLiteEvent(ctx, tx, eventType, movie)
+	if err != nil {
+		return false, err
+	}
+	return created, tx.Commit()
+}
+
+func (m SQLiteMovieModel) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	result, err := m.DB.ExecContext(ctx, `delete from movies where deleted_at < ?`, sqliteTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// sqliteCheckViolation turns a check constraint violation into a
+// CheckViolationError, and returns any other error as it is.
+func sqliteCheckViolation(err error) error {
+	match := sqliteCheckRX.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+	return newCheckViolationError(match[1])
+}
+
+// insertSQLiteEvent records a change to movie in the outbox as part of tx.
+func insertSQLiteEvent(ctx context.Context, tx *sql.Tx, eventType string, movie *Movie) error {
+	payload, err := newEventPayload(eventType, movie)
+	if err != nil {
+		return err
+	}
+	query := `insert into outbox_events (event_type, movie_id, payload) values (?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, eventType, movie.ID, string(payload))
+	return err
+}
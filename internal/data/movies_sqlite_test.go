@@ -0,0 +1,154 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	_ "modernc.org/sqlite"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newSQLiteTestDB opens an in-memory SQLite database with every migration
+// applied.
+func newSQLiteTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file::memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: gets its own database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	files, err := filepath.Glob("../../migrations/sqlite/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.Exec(string(migration))
+		if err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+	}
+	return db
+}
+
+func TestSQLiteMovieModelCRUD(t *testing.T) {
+	m := NewSQLiteMovieModel(newSQLiteTestDB(t))
+	ctx := context.Background()
+
+	movie := &Movie{Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action", "adventure"}}
+	err := m.CreateMovie(ctx, movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.ID != 1 {
+		t.Errorf("expected id 1 but got %d", movie.ID)
+	}
+
+	stored, err := m.GetMovie(ctx, movie.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Title != "test" || stored.Version != 1 || !reflect.DeepEqual(stored.Genres, movie.Genres) {
+		t.Errorf("expected the created movie at version 1 but got %+v", stored)
+	}
+
+	stored.Title = "updated"
+	err = m.UpdateMovie(ctx, stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Version != 2 {
+		t.Errorf("expected version 2 but got %d", stored.Version)
+	}
+	stale := *stored
+	stale.Version = 1
+	err = m.UpdateMovie(ctx, &stale)
+	if !errors.Is(err, ErrEditConflict) {
+		t.Errorf("expected an edit conflict for a stale version but got %v", err)
+	}
+
+	movies, err := m.GetAllMovies(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) != 1 || movies[0].Title != "updated" {
+		t.Errorf("expected only the updated movie but got %+v", movies)
+	}
+
+	err = m.DeleteMovie(ctx, movie.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.GetMovie(ctx, movie.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("expected the deleted movie to be gone but got %v", err)
+	}
+}
+
+func TestSQLiteMovieModelNotFound(t *testing.T) {
+	m := NewSQLiteMovieModel(newSQLiteTestDB(t))
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		call     func() error
+		expected error
+	}{
+		{"get zero test", func() error { _, err := m.GetMovie(ctx, 0); return err }, ErrNoRecordFound},
+		{"get missing test", func() error { _, err := m.GetMovie(ctx, 1); return err }, ErrNoRecordFound},
+		{"delete negative test", func() error { return m.DeleteMovie(ctx, -1) }, ErrNoRecordFound},
+		{"delete missing test", func() error { return m.DeleteMovie(ctx, 1) }, ErrNoRecordFound},
+		{"update missing test", func() error {
+			return m.UpdateMovie(ctx, &Movie{ID: 1, Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action"}, Version: 1})
+		}, ErrEditConflict},
+	}
+	for _, e := range tests {
+		err := e.call()
+		if !errors.Is(err, e.expected) {
+			t.Errorf("%s: expected %v but got %v", e.name, e.expected, err)
+		}
+	}
+}
+
+func TestSQLiteMovieModelCheckViolation(t *testing.T) {
+	m := NewSQLiteMovieModel(newSQLiteTestDB(t))
+	ctx := context.Background()
+
+	stored := &Movie{Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action"}}
+	err := m.CreateMovie(ctx, stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		write         func(movie *Movie) error
+		movie         Movie
+		expectedField string
+	}{
+		{"create runtime test", func(movie *Movie) error { return m.CreateMovie(ctx, movie) }, Movie{Title: "test", Runtime: -1, Year: 2020, Genres: []string{"action"}}, "runtime"},
+		{"create genres test", func(movie *Movie) error { return m.CreateMovie(ctx, movie) }, Movie{Title: "test", Runtime: 100, Year: 2020, Genres: []string{}}, "genres"},
+		{"update genres test", func(movie *Movie) error { return m.UpdateMovie(ctx, movie) }, Movie{ID: stored.ID, Title: "test", Runtime: 100, Year: 2020, Genres: []string{"a", "b", "c", "d", "e", "f"}, Version: stored.Version}, "genres"},
+		{"upsert runtime test", func(movie *Movie) error { _, err := m.UpsertMovie(ctx, movie); return err }, Movie{ID: stored.ID, Title: "test", Runtime: -1, Year: 2020, Genres: []string{"action"}}, "runtime"},
+	}
+	for _, e := range tests {
+		err := e.write(&e.movie)
+		var checkErr *CheckViolationError
+		if !errors.As(err, &checkErr) {
+			t.Errorf("%s: expected a check violation but got %v", e.name, err)
+			continue
+		}
+		if checkErr.Field != e.expectedField {
+			t.Errorf("%s: expected field %s but got %s", e.name, e.expectedField, checkErr.Field)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 )
 
+// MockMovieModel answers from hard-coded ids and titles. New tests should use
+// datatest.FakeMovies, which can be seeded and programmed per test.
 type MockMovieModel struct {
 }
 
@@ -32,6 +34,8 @@ func (m MockMovieModel) CreateMovie(ctx context.Context, movie *Movie) error {
 	if movie.Title == "test" {
 		movie.ID = 2
 		return nil
+	} else if movie.Title == "check violation" {
+		return newCheckViolationError("movies_runtime_check")
 	}
 	return errors.New("failed to create movie")
 }
@@ -78,3 +82,20 @@ func (m MockMovieModel) GetAllMovies(ctx context.Context) ([]*Movie, error) {
 	}
 	return movies, nil
 }
+
+func (m MockMovieModel) UpsertMovie(ctx context.Context, movie *Movie) (bool, error) {
+	switch {
+	case movie.ID == 1 || movie.ExternalID == "tt0000001":
+		movie.ID = 1
+		movie.Version = 2
+		return false, nil
+	case movie.ID == 3 || movie.ExternalID == "tt0000003":
+		movie.ID = 3
+		movie.Version = 1
+		return true, nil
+	case movie.ExternalID == "tt0000002":
+		return false, ErrDuplicateExternalID
+	default:
+		return false, errors.New("failed to upsert movie")
+	}
+}
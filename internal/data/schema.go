@@ -0,0 +1,38 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion is the last migration the models are written against. It has
+// to be bumped along with every new migration.
+const SchemaVersion = 7
+
+// CheckSchema pings db and returns the version recorded by migrate in the
+// schema_migrations table. It fails when the last migration did not finish or
+// the schema is older than SchemaVersion. A newer schema is fine, since the
+// migrations of a new release run before its instances replace the old ones.
+func CheckSchema(ctx context.Context, db *sql.DB) (int, error) {
+	err := db.PingContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var version int
+	var dirty bool
+	err = db.QueryRowContext(ctx, `select version, dirty from schema_migrations limit 1`).Scan(&version, &dirty)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, errors.New("no migrations have been applied")
+	case err != nil:
+		return 0, err
+	case dirty:
+		return version, fmt.Errorf("migration %d did not finish", version)
+	case version < SchemaVersion:
+		return version, fmt.Errorf("schema version %d is older than %d", version, SchemaVersion)
+	}
+	return version, nil
+}
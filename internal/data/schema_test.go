@@ -0,0 +1,66 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSchemaVersionMatchesMigrations(t *testing.T) {
+	for _, dir := range []string{"../../migrations", "../../migrations/sqlite"} {
+		files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+		if err != nil || len(files) == 0 {
+			t.Fatalf("%s: no migrations found %v", dir, err)
+		}
+		sort.Strings(files)
+		prefix, _, _ := strings.Cut(filepath.Base(files[len(files)-1]), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != SchemaVersion {
+			t.Errorf("%s: expected the last migration to be %d but got %d", dir, SchemaVersion, version)
+		}
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	create := "create table schema_migrations (version bigint not null primary key, dirty boolean not null); insert into schema_migrations "
+	tests := []struct {
+		name            string
+		setup           string
+		expectedVersion int
+		expectedError   string
+	}{
+		{"no table test", "", 0, "no such table: schema_migrations"},
+		{"no migrations test", "create table schema_migrations (version bigint not null primary key, dirty boolean not null)", 0, "no migrations have been applied"},
+		{"current test", fmt.Sprintf("%[1]svalues (%[2]d, false)", create, SchemaVersion), SchemaVersion, ""},
+		{"newer test", fmt.Sprintf("%[1]svalues (%[2]d, false)", create, SchemaVersion+1), SchemaVersion + 1, ""},
+		{"older test", fmt.Sprintf("%[1]svalues (%[2]d, false)", create, SchemaVersion-1), SchemaVersion - 1, fmt.Sprintf("schema version %d is older than %d", SchemaVersion-1, SchemaVersion)},
+		{"dirty test", fmt.Sprintf("%[1]svalues (%[2]d, true)", create, SchemaVersion), SchemaVersion, fmt.Sprintf("migration %d did not finish", SchemaVersion)},
+	}
+	for _, e := range tests {
+		db := newSQLiteTestDB(t)
+		if e.setup != "" {
+			_, err := db.Exec(e.setup)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		version, err := CheckSchema(context.Background(), db)
+		if version != e.expectedVersion {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedVersion, version)
+		}
+		switch {
+		case e.expectedError == "" && err != nil:
+			t.Errorf("%s: expected no error but got %s", e.name, err)
+		case e.expectedError != "" && (err == nil || !strings.Contains(err.Error(), e.expectedError)):
+			t.Errorf("%s: expected %s but got %v", e.name, e.expectedError, err)
+		}
+	}
+}
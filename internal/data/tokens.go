@@ -0,0 +1,112 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"time"
+)
+
+const ScopeAPI = "api"
+
+// Tokens stores API tokens. Only the SHA-256 hash of a token is stored, so
+// the plaintext is shown once, when the token is issued.
+type Tokens interface {
+	NewToken(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error)
+	// RevokeToken deletes the token with the given plaintext, returning
+	// ErrNoRecordFound if there is none.
+	RevokeToken(ctx context.Context, plaintext string) error
+	// RevokeAllTokens deletes every token of the user and returns how many
+	// there were.
+	RevokeAllTokens(ctx context.Context, userID int64) (int64, error)
+	DeleteExpiredTokens(ctx context.Context) (int64, error)
+}
+
+type Token struct {
+	Plaintext string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	UserID    int64     `json:"user_id"`
+	Scope     string    `json:"scope"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+	token := &Token{
+		Plaintext: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes),
+		UserID:    userID,
+		Scope:     scope,
+		Expiry:    time.Now().Add(ttl).Truncate(time.Second),
+	}
+	token.Hash = hashToken(token.Plaintext)
+	return token, nil
+}
+
+func hashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+func NewTokenModel(db *sql.DB) TokenModel {
+	return TokenModel{DB: db}
+}
+
+func (m TokenModel) NewToken(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+	query := `insert into tokens (hash, user_id, scope, expiry) values ($1, $2, $3, $4)`
+	_, err = m.DB.ExecContext(ctx, query, token.Hash, token.UserID, token.Scope, token.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (m TokenModel) RevokeToken(ctx context.Context, plaintext string) error {
+	result, err := m.DB.ExecContext(ctx, `delete from tokens where hash = $1`, hashToken(plaintext))
+	return revokedOne(result, err)
+}
+
+func (m TokenModel) RevokeAllTokens(ctx context.Context, userID int64) (int64, error) {
+	result, err := m.DB.ExecContext(ctx, `delete from tokens where user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (m TokenModel) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	result, err := m.DB.ExecContext(ctx, `delete from tokens where expiry <= now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// revokedOne turns the result of deleting a token into ErrNoRecordFound when
+// the token did not exist.
+func revokedOne(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
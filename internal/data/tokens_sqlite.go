@@ -0,0 +1,49 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type SQLiteTokenModel struct {
+	DB *sql.DB
+}
+
+func NewSQLiteTokenModel(db *sql.DB) SQLiteTokenModel {
+	return SQLiteTokenModel{DB: db}
+}
+
+func (m SQLiteTokenModel) NewToken(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+	query := `insert into tokens (hash, user_id, scope, expiry) values (?, ?, ?, ?)`
+	_, err = m.DB.ExecContext(ctx, query, token.Hash, token.UserID, token.Scope, sqliteTime(token.Expiry))
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (m SQLiteTokenModel) RevokeToken(ctx context.Context, plaintext string) error {
+	result, err := m.DB.ExecContext(ctx, `delete from tokens where hash = ?`, hashToken(plaintext))
+	return revokedOne(result, err)
+}
+
+func (m SQLiteTokenModel) RevokeAllTokens(ctx context.Context, userID int64) (int64, error) {
+	result, err := m.DB.ExecContext(ctx, `delete from tokens where user_id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (m SQLiteTokenModel) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	result, err := m.DB.ExecContext(ctx, `delete from tokens where expiry <= ?`, sqliteTime(time.Now()))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
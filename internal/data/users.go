@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"github.com/rrebeiz/quickmovies/internal/validator"
+	"strings"
+	"time"
+)
+
+var ErrDuplicateEmail = errors.New("duplicate email")
+
+// PermissionCodes are the permissions that can be granted to a user.
+var PermissionCodes = []string{"metrics:read", "movies:read", "movies:write", "webhooks:read", "webhooks:write"}
+
+// Users stores the accounts managed by the admin commands and their
+// permissions.
+type Users interface {
+	// CreateUser stores user, returning ErrDuplicateEmail if the email is
+	// taken.
+	CreateUser(ctx context.Context, user *User) error
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	GetAllUsers(ctx context.Context) ([]*User, error)
+	// GetUserForToken returns the owner of the unexpired token with the given
+	// scope and plaintext, or ErrNoRecordFound.
+	GetUserForToken(ctx context.Context, scope, plaintext string) (*User, error)
+	GetPermissions(ctx context.Context, userID int64) ([]string, error)
+	// GrantPermissions adds codes to the permissions of the user. Codes the
+	// user already has are ignored.
+	GrantPermissions(ctx context.Context, userID int64, codes ...string) error
+	RevokePermissions(ctx context.Context, userID int64, codes ...string) error
+}
+
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name" validate:"required,max=500"`
+	Email     string    `json:"email" validate:"required,max=500,regexp=^[^@ ]+@[^@ ]+\\.[^@ ]+$"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidateUser checks user against the rules in the validate tags of User.
+func ValidateUser(v *validator.Validator, user *User) {
+	v.Struct(user)
+}
+
+// normalizeEmail makes emails unique regardless of case.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+type UserModel struct {
+	DB *sql.DB
+}
+
+func NewUserModel(db *sql.DB) UserModel {
+	return UserModel{DB: db}
+}
+
+func (m UserModel) CreateUser(ctx context.Context, user *User) error {
+	user.Email = normalizeEmail(user.Email)
+	query := `insert into users (name, email) values ($1, $2) returning id, created_at`
+	err := m.DB.QueryRowContext(ctx, query, user.Name, user.Email).Scan(&user.ID, &user.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_email_key":
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m UserModel) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	query := `select id, name, email, created_at from users where email = $1`
+	return scanUser(m.DB.QueryRowContext(ctx, query, normalizeEmail(email)))
+}
+
+func (m UserModel) GetUserForToken(ctx context.Context, scope, plaintext string) (*User, error) {
+	query := `select u.id, u.name, u.email, u.created_at from users u join tokens t on t.user_id = u.id where t.hash = $1 and t.scope = $2 and t.expiry > now()`
+	return scanUser(m.DB.QueryRowContext(ctx, query, hashToken(plaintext), scope))
+}
+
+func (m UserModel) GetAllUsers(ctx context.Context) ([]*User, error) {
+	rows, err := m.DB.QueryContext(ctx, `select id, name, email, created_at from users order by id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanUsers(rows)
+}
+
+func (m UserModel) GetPermissions(ctx context.Context, userID int64) ([]string, error) {
+	query := `select p.code from permissions p join users_permissions up on up.permission_id = p.id where up.user_id = $1 order by p.code`
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanPermissions(rows)
+}
+
+func (m UserModel) GrantPermissions(ctx context.Context, userID int64, codes ...string) error {
+	query := `insert into users_permissions (user_id, permission_id) select $1, id from permissions where code = any($2) on conflict do nothing`
+	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
+	return err
+}
+
+func (m UserModel) RevokePermissions(ctx context.Context, userID int64, codes ...string) error {
+	query := `delete from users_permissions where user_id = $1 and permission_id in (select id from permissions where code = any($2))`
+	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
+	return err
+}
+
+// scanUser scans a single user, returning ErrNoRecordFound if there is none.
+func scanUser(row *sql.Row) (*User, error) {
+	var user User
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+func scanUsers(rows *sql.Rows) ([]*User, error) {
+	defer rows.Close()
+	var users []*User
+	for rows.Next() {
+		var user User
+		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	err := rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func scanPermissions(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	codes := []string{}
+	for rows.Next() {
+		var code string
+		err := rows.Scan(&code)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	err := rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
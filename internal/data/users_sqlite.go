@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+type SQLiteUserModel struct {
+	DB *sql.DB
+}
+
+func NewSQLiteUserModel(db *sql.DB) SQLiteUserModel {
+	return SQLiteUserModel{DB: db}
+}
+
+func (m SQLiteUserModel) CreateUser(ctx context.Context, user *User) error {
+	user.Email = normalizeEmail(user.Email)
+	query := `insert into users (name, email) values (?, ?) returning id, created_at`
+	err := m.DB.QueryRowContext(ctx, query, user.Name, user.Email).Scan(&user.ID, &user.CreatedAt)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "UNIQUE constraint failed: users.email"):
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m SQLiteUserModel) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	query := `select id, name, email, created_at from users where email = ?`
+	return scanUser(m.DB.QueryRowContext(ctx, query, normalizeEmail(email)))
+}
+
+func (m SQLiteUserModel) GetUserForToken(ctx context.Context, scope, plaintext string) (*User, error) {
+	query := `select u.id, u.name, u.email, u.created_at from users u join tokens t on t.user_id = u.id where t.hash = ? and t.scope = ? and t.expiry > ?`
+	return scanUser(m.DB.QueryRowContext(ctx, query, hashToken(plaintext), scope, sqliteTime(time.Now())))
+}
+
+func (m SQLiteUserModel) GetAllUsers(ctx context.Context) ([]*User, error) {
+	rows, err := m.DB.QueryContext(ctx, `select id, name, email, created_at from users order by id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanUsers(rows)
+}
+
+func (m SQLiteUserModel) GetPermissions(ctx context.Context, userID int64) ([]string, error) {
+	query := `select p.code from permissions p join users_permissions up on up.permission_id = p.id where up.user_id = ? order by p.code`
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanPermissions(rows)
+}
+
+func (m SQLiteUserModel) GrantPermissions(ctx context.Context, userID int64, codes ...string) error {
+	query := `insert into users_permissions (user_id, permission_id) select ?, id from permissions where code in (select value from json_each(?)) on conflict do nothing`
+	return m.execWithCodes(ctx, query, userID, codes)
+}
+
+func (m SQLiteUserModel) RevokePermissions(ctx context.Context, userID int64, codes ...string) error {
+	query := `delete from users_permissions where user_id = ? and permission_id in (select id from permissions where code in (select value from json_each(?)))`
+	return m.execWithCodes(ctx, query, userID, codes)
+}
+
+func (m SQLiteUserModel) execWithCodes(ctx context.Context, query string, userID int64, codes []string) error {
+	js, err := json.Marshal(codes)
+	if err != nil {
+		return err
+	}
+	_, err = m.DB.ExecContext(ctx, query, userID, string(js))
+	return err
+}
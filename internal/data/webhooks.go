@@ -0,0 +1,343 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/lib/pq"
+	"github.com/rrebeiz/quickmovies/internal/validator"
+	"net/url"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhooks stores webhook subscriptions and the outbox they are fed from.
+// FanOutEvents, ClaimDeliveries and RecordAttempt are used by the webhook
+// dispatcher.
+type Webhooks interface {
+	GetWebhook(ctx context.Context, id int64) (*Webhook, error)
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	UpdateWebhook(ctx context.Context, webhook *Webhook) error
+	DeleteWebhook(ctx context.Context, id int64) error
+	GetAllWebhooks(ctx context.Context) ([]*Webhook, error)
+	GetDeliveries(ctx context.Context, webhookID int64) ([]*WebhookDelivery, error)
+
+	// FanOutEvents turns up to limit outbox events into one pending delivery
+	// per active webhook subscribed to the event, and returns how many
+	// events were processed.
+	FanOutEvents(ctx context.Context, limit int) (int, error)
+	// ClaimDeliveries returns up to limit pending deliveries that are due and
+	// hides them from other callers for lease, so a crashed dispatcher's
+	// deliveries are retried once the lease runs out.
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error)
+	// RecordAttempt stores the outcome of a delivery attempt. A failed attempt
+	// counts towards the webhook's consecutive failures, and the webhook is
+	// disabled once they reach disableAfter.
+	RecordAttempt(ctx context.Context, delivery *WebhookDelivery, disableAfter int) error
+	// DeleteOldEvents removes the deliveries that succeeded or failed before
+	// before, then the outbox events fanned out before it that no delivery
+	// refers to any more, and returns how many of each were removed. Pending
+	// deliveries and their events are kept.
+	DeleteOldEvents(ctx context.Context, before time.Time) (deliveries, events int64, err error)
+}
+
+type Webhook struct {
+	ID           int64     `json:"id"`
+	URL          string    `json:"url"`
+	Events       []string  `json:"events"`
+	Secret       string    `json:"-"`
+	Active       bool      `json:"active"`
+	FailureCount int32     `json:"failure_count"`
+	Version      int32     `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is the delivery of one event to one webhook, including its
+// retries.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventID        int64           `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	LastStatusCode int32           `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	URL            string          `json:"-"`
+	Secret         string          `json:"-"`
+	Payload        json.RawMessage `json:"-"`
+}
+
+// Subscribed reports whether the webhook wants events of eventType.
+func (w *Webhook) Subscribed(eventType string) bool {
+	return validator.PermittedValue(eventType, w.Events...)
+}
+
+func ValidateWebhook(v *validator.Validator, webhook *Webhook) {
+	v.CheckMessage(webhook.URL != "", "url", "required", "validation.required")
+	u, err := url.Parse(webhook.URL)
+	v.CheckMessage(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "invalid_url", "validation.url_invalid")
+
+	v.CheckMessage(len(webhook.Events) >= 1, "events", "too_few", "validation.events_too_few", 1)
+	v.CheckMessage(validator.Unique(webhook.Events), "events", "duplicate", "validation.events_duplicate")
+	for _, event := range webhook.Events {
+		v.CheckMessage(validator.PermittedValue(event, EventTypes...), "events", "not_permitted", "validation.events_not_permitted", EventTypes)
+	}
+
+	v.CheckMessage(len(webhook.Secret) >= 16, "secret", "too_short", "validation.too_short_bytes", 16)
+	v.CheckMessage(len(webhook.Secret) <= 256, "secret", "too_long", "validation.too_long_bytes", 256)
+}
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+func NewWebhookModel(db *sql.DB) WebhookModel {
+	return WebhookModel{DB: db}
+}
+
+func (m WebhookModel) GetWebhook(ctx context.Context, id int64) (*Webhook, error) {
+	if id <= 0 {
+		return nil, ErrNoRecordFound
+	}
+	query := `select id, url, events, secret, active, failure_count, version, created_at from webhooks where id = $1`
+	var webhook Webhook
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&webhook.ID, &webhook.URL, pq.Array(&webhook.Events), &webhook.Secret, &webhook.Active, &webhook.FailureCount, &webhook.Version, &webhook.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &webhook, nil
+}
+
+func (m WebhookModel) GetAllWebhooks(ctx context.Context) ([]*Webhook, error) {
+	query := `select id, url, events, secret, active, failure_count, version, created_at from webhooks order by id`
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*Webhook
+	for rows.Next() {
+		var webhook Webhook
+		err = rows.Scan(&webhook.ID, &webhook.URL, pq.Array(&webhook.Events), &webhook.Secret, &webhook.Active, &webhook.FailureCount, &webhook.Version, &webhook.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (m WebhookModel) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	query := `insert into webhooks (url, events, secret, active) values ($1, $2, $3, $4) returning id, version, created_at`
+	args := []interface{}{webhook.URL, pq.Array(webhook.Events), webhook.Secret, webhook.Active}
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&webhook.ID, &webhook.Version, &webhook.CreatedAt)
+}
+
+func (m WebhookModel) UpdateWebhook(ctx context.Context, webhook *Webhook) error {
+	query := `update webhooks set url = $1, events = $2, secret = $3, active = $4, failure_count = $5, version = version + 1, updated_at = now() where id = $6 and version = $7 returning version`
+	args := []interface{}{webhook.URL, pq.Array(webhook.Events), webhook.Secret, webhook.Active, webhook.FailureCount, webhook.ID, webhook.Version}
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&webhook.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m WebhookModel) DeleteWebhook(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrNoRecordFound
+	}
+	res, err := m.DB.ExecContext(ctx, `delete from webhooks where id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+func (m WebhookModel) GetDeliveries(ctx context.Context, webhookID int64) ([]*WebhookDelivery, error) {
+	query := `select d.id, d.webhook_id, d.event_id, e.event_type, d.status, d.attempts, d.last_status_code, d.last_error, d.next_attempt_at, d.created_at, d.updated_at
+		from webhook_deliveries d join outbox_events e on e.id = d.event_id
+		where d.webhook_id = $1 order by d.id desc limit 100`
+	rows, err := m.DB.QueryContext(ctx, query, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.LastStatusCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &d)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (m WebhookModel) FanOutEvents(ctx context.Context, limit int) (int, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// skip locked lets several instances fan out concurrently without
+	// handing out the same event twice.
+	query := `select id, event_type from outbox_events where fanned_out_at is null order by id limit $1 for update skip locked`
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+	var events []*Event
+	for rows.Next() {
+		var event Event
+		err = rows.Scan(&event.ID, &event.Type)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, &event)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		query = `insert into webhook_deliveries (webhook_id, event_id) select id, $1 from webhooks where active and $2 = any(events)`
+		_, err = tx.ExecContext(ctx, query, event.ID, event.Type)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.ExecContext(ctx, `update outbox_events set fanned_out_at = now() where id = $1`, event.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(events), tx.Commit()
+}
+
+func (m WebhookModel) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	query := `update webhook_deliveries d set next_attempt_at = now() + $2 * interval '1 millisecond', updated_at = now()
+		from webhooks w, outbox_events e
+		where d.id in (
+			select d2.id from webhook_deliveries d2 join webhooks w2 on w2.id = d2.webhook_id
+			where d2.status = 'pending' and d2.next_attempt_at <= now() and w2.active
+			order by d2.next_attempt_at limit $1 for update of d2 skip locked
+		) and w.id = d.webhook_id and e.id = d.event_id
+		returning d.id, d.webhook_id, d.event_id, e.event_type, d.status, d.attempts, d.created_at, w.url, w.secret, e.payload`
+	rows, err := m.DB.QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.CreatedAt, &d.URL, &d.Secret, &d.Payload)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &d)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (m WebhookModel) RecordAttempt(ctx context.Context, delivery *WebhookDelivery, disableAfter int) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `update webhook_deliveries set status = $1, attempts = $2, last_status_code = $3, last_error = $4, next_attempt_at = $5, updated_at = now() where id = $6`
+	args := []interface{}{delivery.Status, delivery.Attempts, delivery.LastStatusCode, delivery.LastError, delivery.NextAttemptAt, delivery.ID}
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if delivery.Status == DeliverySucceeded {
+		query = `update webhooks set failure_count = 0 where id = $1`
+		_, err = tx.ExecContext(ctx, query, delivery.WebhookID)
+	} else {
+		query = `update webhooks set failure_count = failure_count + 1, active = active and failure_count + 1 < $2, updated_at = now() where id = $1`
+		_, err = tx.ExecContext(ctx, query, delivery.WebhookID, disableAfter)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m WebhookModel) DeleteOldEvents(ctx context.Context, before time.Time) (int64, int64, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `delete from webhook_deliveries where status <> 'pending' and updated_at < $1`, before)
+	if err != nil {
+		return 0, 0, err
+	}
+	deliveries, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	query := `delete from outbox_events e where e.fanned_out_at < $1
+		and not exists (select 1 from webhook_deliveries d where d.event_id = e.id)`
+	result, err = tx.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, 0, err
+	}
+	events, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	return deliveries, events, tx.Commit()
+}
@@ -0,0 +1,237 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryWebhookModel is the in-memory implementation of Webhooks. It fans out
+// the events recorded by the MemoryMovieModel it was created with.
+type MemoryWebhookModel struct {
+	movies *MemoryMovieModel
+
+	mu             sync.Mutex
+	nextID         int64
+	nextDeliveryID int64
+	webhooks       map[int64]*Webhook
+	deliveries     []*WebhookDelivery
+	payloads       map[int64]*Event
+}
+
+func NewMemoryWebhookModel(movies *MemoryMovieModel) *MemoryWebhookModel {
+	return &MemoryWebhookModel{
+		movies:         movies,
+		nextID:         1,
+		nextDeliveryID: 1,
+		webhooks:       make(map[int64]*Webhook),
+		payloads:       make(map[int64]*Event),
+	}
+}
+
+func (m *MemoryWebhookModel) GetWebhook(ctx context.Context, id int64) (*Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	return copyWebhook(webhook), nil
+}
+
+func (m *MemoryWebhookModel) GetAllWebhooks(ctx context.Context) ([]*Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhooks := make([]*Webhook, 0, len(m.webhooks))
+	for _, webhook := range m.webhooks {
+		webhooks = append(webhooks, copyWebhook(webhook))
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].ID < webhooks[j].ID
+	})
+	return webhooks, nil
+}
+
+func (m *MemoryWebhookModel) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhook.ID = m.nextID
+	webhook.Version = 1
+	webhook.CreatedAt = time.Now()
+	m.nextID++
+	m.webhooks[webhook.ID] = copyWebhook(webhook)
+	return nil
+}
+
+func (m *MemoryWebhookModel) UpdateWebhook(ctx context.Context, webhook *Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.webhooks[webhook.ID]
+	if !ok || stored.Version != webhook.Version {
+		return ErrEditConflict
+	}
+	webhook.Version++
+	m.webhooks[webhook.ID] = copyWebhook(webhook)
+	return nil
+}
+
+func (m *MemoryWebhookModel) DeleteWebhook(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.webhooks[id]; !ok {
+		return ErrNoRecordFound
+	}
+	delete(m.webhooks, id)
+
+	deliveries := m.deliveries[:0]
+	for _, d := range m.deliveries {
+		if d.WebhookID != id {
+			deliveries = append(deliveries, d)
+		}
+	}
+	m.deliveries = deliveries
+	return nil
+}
+
+func (m *MemoryWebhookModel) GetDeliveries(ctx context.Context, webhookID int64) ([]*WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deliveries := []*WebhookDelivery{}
+	for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < 100; i-- {
+		if m.deliveries[i].WebhookID == webhookID {
+			d := *m.deliveries[i]
+			deliveries = append(deliveries, &d)
+		}
+	}
+	return deliveries, nil
+}
+
+func (m *MemoryWebhookModel) FanOutEvents(ctx context.Context, limit int) (int, error) {
+	events := m.movies.takeEvents(limit)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, event := range events {
+		for _, webhook := range m.webhooks {
+			if !webhook.Active || !webhook.Subscribed(event.Type) {
+				continue
+			}
+			m.deliveries = append(m.deliveries, &WebhookDelivery{
+				ID:            m.nextDeliveryID,
+				WebhookID:     webhook.ID,
+				EventID:       event.ID,
+				EventType:     event.Type,
+				Status:        DeliveryPending,
+				NextAttemptAt: now,
+				CreatedAt:     now,
+				UpdatedAt:     now,
+			})
+			m.payloads[event.ID] = event
+			m.nextDeliveryID++
+		}
+	}
+	return len(events), nil
+}
+
+func (m *MemoryWebhookModel) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var deliveries []*WebhookDelivery
+	for _, d := range m.deliveries {
+		if len(deliveries) == limit {
+			break
+		}
+		webhook, ok := m.webhooks[d.WebhookID]
+		if d.Status != DeliveryPending || d.NextAttemptAt.After(now) || !ok || !webhook.Active {
+			continue
+		}
+		d.NextAttemptAt = now.Add(lease)
+		d.UpdatedAt = now
+
+		c := *d
+		c.URL = webhook.URL
+		c.Secret = webhook.Secret
+		c.Payload = m.payloads[d.EventID].Payload
+		deliveries = append(deliveries, &c)
+	}
+	return deliveries, nil
+}
+
+func (m *MemoryWebhookModel) RecordAttempt(ctx context.Context, delivery *WebhookDelivery, disableAfter int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, d := range m.deliveries {
+		if d.ID == delivery.ID {
+			d.Status = delivery.Status
+			d.Attempts = delivery.Attempts
+			d.LastStatusCode = delivery.LastStatusCode
+			d.LastError = delivery.LastError
+			d.NextAttemptAt = delivery.NextAttemptAt
+			d.UpdatedAt = time.Now()
+			break
+		}
+	}
+
+	webhook, ok := m.webhooks[delivery.WebhookID]
+	if !ok {
+		return nil
+	}
+	if delivery.Status == DeliverySucceeded {
+		webhook.FailureCount = 0
+		return nil
+	}
+	webhook.FailureCount++
+	if int(webhook.FailureCount) >= disableAfter {
+		webhook.Active = false
+	}
+	return nil
+}
+
+func copyWebhook(webhook *Webhook) *Webhook {
+	c := *webhook
+	c.Events = make([]string, len(webhook.Events))
+	copy(c.Events, webhook.Events)
+	return &c
+}
+
+// DeleteOldEvents removes the finished deliveries and the payloads no
+// delivery refers to any more. The outbox itself is trimmed by the
+// MemoryMovieModel, so no events are counted.
+func (m *MemoryWebhookModel) DeleteOldEvents(ctx context.Context, before time.Time) (int64, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	kept := m.deliveries[:0]
+	referenced := make(map[int64]bool)
+	for _, d := range m.deliveries {
+		if d.Status != DeliveryPending && d.UpdatedAt.Before(before) {
+			deleted++
+			continue
+		}
+		kept = append(kept, d)
+		referenced[d.EventID] = true
+	}
+	for i := len(kept); i < len(m.deliveries); i++ {
+		m.deliveries[i] = nil
+	}
+	m.deliveries = kept
+	for id := range m.payloads {
+		if !referenced[id] {
+			delete(m.payloads, id)
+		}
+	}
+	return deleted, 0, nil
+}
@@ -0,0 +1,302 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// sqliteTimeFormat is used for every timestamp the SQLite models write so
+// that they compare correctly as text against each other and against
+// current_timestamp.
+const sqliteTimeFormat = "2006-01-02 15:04:05.000"
+
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// SQLiteWebhookModel is the SQLite implementation of Webhooks. Event lists are
+// stored as JSON arrays like movie genres.
+type SQLiteWebhookModel struct {
+	DB *sql.DB
+}
+
+func NewSQLiteWebhookModel(db *sql.DB) SQLiteWebhookModel {
+	return SQLiteWebhookModel{DB: db}
+}
+
+func (m SQLiteWebhookModel) GetWebhook(ctx context.Context, id int64) (*Webhook, error) {
+	if id <= 0 {
+		return nil, ErrNoRecordFound
+	}
+	query := `select id, url, events, secret, active, failure_count, version, created_at from webhooks where id = ?`
+	var webhook Webhook
+	var events []byte
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&webhook.ID, &webhook.URL, &events, &webhook.Secret, &webhook.Active, &webhook.FailureCount, &webhook.Version, &webhook.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	err = json.Unmarshal(events, &webhook.Events)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (m SQLiteWebhookModel) GetAllWebhooks(ctx context.Context) ([]*Webhook, error) {
+	query := `select id, url, events, secret, active, failure_count, version, created_at from webhooks order by id`
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*Webhook
+	for rows.Next() {
+		var webhook Webhook
+		var events []byte
+		err = rows.Scan(&webhook.ID, &webhook.URL, &events, &webhook.Secret, &webhook.Active, &webhook.FailureCount, &webhook.Version, &webhook.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(events, &webhook.Events)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (m SQLiteWebhookModel) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	query := `insert into webhooks (url, events, secret, active, created_at, updated_at) values (?, ?, ?, ?, ?, ?) returning id, version`
+	args := []interface{}{webhook.URL, string(events), webhook.Secret, webhook.Active, sqliteTime(now), sqliteTime(now)}
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&webhook.ID, &webhook.Version)
+	if err != nil {
+		return err
+	}
+	webhook.CreatedAt = now
+	return nil
+}
+
+func (m SQLiteWebhookModel) UpdateWebhook(ctx context.Context, webhook *Webhook) error {
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return err
+	}
+	query := `update webhooks set url = ?, events = ?, secret = ?, active = ?, failure_count = ?, version = version + 1, updated_at = ? where id = ? and version = ? returning version`
+	args := []interface{}{webhook.URL, string(events), webhook.Secret, webhook.Active, webhook.FailureCount, sqliteTime(time.Now()), webhook.ID, webhook.Version}
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&webhook.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m SQLiteWebhookModel) DeleteWebhook(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrNoRecordFound
+	}
+	res, err := m.DB.ExecContext(ctx, `delete from webhooks where id = ?`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+func (m SQLiteWebhookModel) GetDeliveries(ctx context.Context, webhookID int64) ([]*WebhookDelivery, error) {
+	query := `select d.id, d.webhook_id, d.event_id, e.event_type, d.status, d.attempts, d.last_status_code, d.last_error, d.next_attempt_at, d.created_at, d.updated_at
+		from webhook_deliveries d join outbox_events e on e.id = d.event_id
+		where d.webhook_id = ? order by d.id desc limit 100`
+	rows, err := m.DB.QueryContext(ctx, query, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.LastStatusCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &d)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (m SQLiteWebhookModel) FanOutEvents(ctx context.Context, limit int) (int, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `select id, event_type from outbox_events where fanned_out_at is null order by id limit ?`, limit)
+	if err != nil {
+		return 0, err
+	}
+	var events []*Event
+	for rows.Next() {
+		var event Event
+		err = rows.Scan(&event.ID, &event.Type)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, &event)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	now := sqliteTime(time.Now())
+	for _, event := range events {
+		query := `insert into webhook_deliveries (webhook_id, event_id, next_attempt_at, created_at, updated_at)
+			select w.id, ?, ?, ?, ? from webhooks w
+			where w.active and exists (select 1 from json_each(w.events) where value = ?)`
+		_, err = tx.ExecContext(ctx, query, event.ID, now, now, now, event.Type)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.ExecContext(ctx, `update outbox_events set fanned_out_at = ? where id = ?`, now, event.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(events), tx.Commit()
+}
+
+func (m SQLiteWebhookModel) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	query := `select d.id, d.webhook_id, d.event_id, e.event_type, d.status, d.attempts, d.created_at, w.url, w.secret, e.payload
+		from webhook_deliveries d join webhooks w on w.id = d.webhook_id join outbox_events e on e.id = d.event_id
+		where d.status = 'pending' and d.next_attempt_at <= ? and w.active
+		order by d.next_attempt_at limit ?`
+	rows, err := tx.QueryContext(ctx, query, sqliteTime(now), limit)
+	if err != nil {
+		return nil, err
+	}
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload string
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.CreatedAt, &d.URL, &d.Secret, &payload)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, &d)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	leased := sqliteTime(now.Add(lease))
+	for _, d := range deliveries {
+		_, err = tx.ExecContext(ctx, `update webhook_deliveries set next_attempt_at = ?, updated_at = ? where id = ?`, leased, sqliteTime(now), d.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return deliveries, tx.Commit()
+}
+
+func (m SQLiteWebhookModel) RecordAttempt(ctx context.Context, delivery *WebhookDelivery, disableAfter int) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := sqliteTime(time.Now())
+	query := `update webhook_deliveries set status = ?, attempts = ?, last_status_code = ?, last_error = ?, next_attempt_at = ?, updated_at = ? where id = ?`
+	args := []interface{}{delivery.Status, delivery.Attempts, delivery.LastStatusCode, delivery.LastError, sqliteTime(delivery.NextAttemptAt), now, delivery.ID}
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if delivery.Status == DeliverySucceeded {
+		_, err = tx.ExecContext(ctx, `update webhooks set failure_count = 0 where id = ?`, delivery.WebhookID)
+	} else {
+		query = `update webhooks set failure_count = failure_count + 1, active = active and failure_count + 1 < ?, updated_at = ? where id = ?`
+		_, err = tx.ExecContext(ctx, query, disableAfter, now, delivery.WebhookID)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m SQLiteWebhookModel) DeleteOldEvents(ctx context.Context, before time.Time) (int64, int64, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `delete from webhook_deliveries where status <> 'pending' and updated_at < ?`, sqliteTime(before))
+	if err != nil {
+		return 0, 0, err
+	}
+	deliveries, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	query := `delete from outbox_events where fanned_out_at < ?
+		and not exists (select 1 from webhook_deliveries d where d.event_id = outbox_events.id)`
+	result, err = tx.ExecContext(ctx, query, sqliteTime(before))
+	if err != nil {
+		return 0, 0, err
+	}
+	events, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	return deliveries, events, tx.Commit()
+}
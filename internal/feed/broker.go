@@ -0,0 +1,70 @@
+// Package feed fans movie events out to in-process subscribers, such as the
+// server-sent events endpoint.
+package feed
+
+import (
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"sync"
+)
+
+// subscriberBuffer is how many events may queue up for a subscriber before it
+// is considered too slow and dropped.
+const subscriberBuffer = 64
+
+// Subscription receives events on C until it is closed, either through
+// Broker.Unsubscribe or because the subscriber fell too far behind.
+type Subscription struct {
+	C <-chan data.MovieEvent
+	c chan data.MovieEvent
+}
+
+// Broker publishes events to its subscribers. It keeps no history: events are
+// numbered by the outbox, which subscribers read to catch up on the events
+// published before they subscribed.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[*Subscription]struct{})}
+}
+
+// Publish sends event to every subscriber. It never blocks: a subscriber
+// whose queue is full is dropped.
+func (b *Broker) Publish(event data.MovieEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		select {
+		case sub.c <- event:
+		default:
+			delete(b.subs, sub)
+			close(sub.c)
+		}
+	}
+}
+
+// Subscribe registers a new subscriber.
+func (b *Broker) Subscribe() *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan data.MovieEvent, subscriberBuffer)
+	sub := &Subscription{C: c, c: c}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes sub and closes its channel. It is safe to call more
+// than once.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
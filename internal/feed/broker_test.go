@@ -0,0 +1,58 @@
+package feed
+
+import (
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"testing"
+)
+
+func publish(b *Broker, n int) {
+	for i := 0; i < n; i++ {
+		b.Publish(data.NewMovieEvent(data.EventMovieUpdated, &data.Movie{ID: 1, Version: int32(i + 1)}))
+	}
+}
+
+func TestBrokerFanOut(t *testing.T) {
+	b := NewBroker()
+	publish(b, 1)
+	first := b.Subscribe()
+	second := b.Subscribe()
+	publish(b, 2)
+	b.Unsubscribe(first)
+	b.Unsubscribe(second)
+
+	for _, sub := range []*Subscription{first, second} {
+		versions := []int32{}
+		for e := range sub.C {
+			versions = append(versions, e.Version)
+		}
+		if len(versions) != 2 || versions[0] != 1 || versions[1] != 2 {
+			t.Errorf("expected only the events published after subscribing but got versions %v", versions)
+		}
+	}
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	b := NewBroker()
+	slow := b.Subscribe()
+	fast := b.Subscribe()
+
+	publish(b, subscriberBuffer)
+	for i := 0; i < subscriberBuffer; i++ {
+		<-fast.C
+	}
+	publish(b, 1)
+
+	n := 0
+	for range slow.C {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("expected the slow subscriber to get %d events before being dropped but got %d", subscriberBuffer, n)
+	}
+	e, ok := <-fast.C
+	if !ok || e.Version != 1 {
+		t.Errorf("expected the fast subscriber to keep receiving events but got version %d, %t", e.Version, ok)
+	}
+	b.Unsubscribe(fast)
+	b.Unsubscribe(fast)
+}
@@ -0,0 +1,102 @@
+package i18n
+
+var ar = map[string]string{
+	"validation.required":                  "يجب ألا يكون فارغًا",
+	"validation.too_long_bytes":            "يجب ألا يتجاوز %d بايت",
+	"validation.too_short_bytes":           "يجب أن يتكون من %d بايت على الأقل",
+	"validation.not_positive":              "يجب أن يكون رقمًا موجبًا",
+	"validation.out_of_range":              "يجب أن يكون بين %d و %d",
+	"validation.too_few":                   "يجب أن يحتوي على %d عناصر على الأقل",
+	"validation.too_many":                  "يجب ألا يحتوي على أكثر من %d عناصر",
+	"validation.too_small":                 "يجب ألا يقل عن %s",
+	"validation.too_large":                 "يجب ألا يزيد عن %s",
+	"validation.length_bytes":              "يجب أن يتكون من %d بايت بالضبط",
+	"validation.length_items":              "يجب أن يحتوي على %d عناصر بالضبط",
+	"validation.not_permitted":             "يرجى استخدام إحدى القيم %s",
+	"validation.duplicate":                 "يجب ألا يحتوي على قيم مكررة",
+	"validation.invalid_format":            "ليس بصيغة صالحة",
+	"validation.constraint_violation":      "لا تقبله قاعدة البيانات",
+	"validation.genres_too_few":            "يجب أن يحتوي على %d نوع على الأقل",
+	"validation.genres_too_many":           "يجب ألا يحتوي على أكثر من %d أنواع",
+	"validation.genres_duplicate":          "يجب ألا يحتوي على أنواع مكررة",
+	"validation.genres_not_permitted":      "يرجى استخدام الأنواع المسموح بها التالية %s",
+	"validation.url_invalid":               "يجب أن يكون عنوان URL مطلقًا يبدأ بـ http أو https",
+	"validation.url_not_public":            "يجب أن يشير إلى عنوان عام",
+	"validation.events_too_few":            "يجب أن يحتوي على %d حدث على الأقل",
+	"validation.events_duplicate":          "يجب ألا يحتوي على أحداث مكررة",
+	"validation.events_not_permitted":      "يرجى استخدام الأحداث التالية %s",
+	"validation.update_mask_not_permitted": "يجب ألا يحتوي إلا على title و runtime و year و genres",
+	"validation.id_mismatch":               "يجب أن يطابق المعرّف الموجود في عنوان URL",
+	"validation.external_id_mismatch":      "يجب أن يطابق external_id الموجود في عنوان URL",
+	"validation.immutable":                 "لا يمكن تغييره",
+
+	"error.internal":                     "واجه الخادم مشكلة ولم يتمكن من معالجة طلبك",
+	"error.not_found":                    "تعذر العثور على المورد المطلوب",
+	"error.method_not_allowed":           "الطريقة %s غير مدعومة لهذا المورد",
+	"error.not_acceptable":               "المورد متاح فقط بالصيغ %s",
+	"error.validation_failed":            "الطلب غير صالح",
+	"error.edit_conflict":                "تعذر تحديث السجل بسبب تعارض في التعديل، يرجى المحاولة مرة أخرى",
+	"error.patch_test_failed":            "الفيلم لا يطابق عملية test في التصحيح",
+	"error.duplicate_external_id":        "يوجد فيلم آخر يحمل external_id هذا",
+	"error.idempotency_key_reused":       "تم استخدام Idempotency-Key مسبقًا لطلب مختلف",
+	"error.idempotency_key_in_flight":    "لا يزال طلب يحمل Idempotency-Key هذا قيد المعالجة، يرجى المحاولة لاحقًا",
+	"error.invalid_authentication_token": "رمز المصادقة غير صالح أو مفقود",
+	"error.authentication_required":      "يجب أن تكون مصادقًا للوصول إلى هذا المورد",
+	"error.not_permitted":                "لا يملك حسابك الأذونات اللازمة للوصول إلى هذا المورد",
+	"error.bad_request":                  "تعذرت قراءة الطلب",
+	"error.body_empty":                   "يجب ألا يكون المحتوى فارغًا",
+	"error.body_too_large":               "يجب ألا يتجاوز المحتوى %d بايت",
+	"error.body_single_value":            "يجب أن يحتوي المحتوى على قيمة JSON واحدة فقط",
+	"error.body_badly_formed":            "يحتوي المحتوى على %s غير سليم",
+	"error.body_badly_formed_at":         "يحتوي المحتوى على %s غير سليم (عند الحرف %d)",
+	"error.body_incorrect_type":          "يحتوي المحتوى على نوع JSON غير صحيح للحقل %q",
+	"error.body_incorrect_type_at":       "يحتوي المحتوى على نوع JSON غير صحيح (عند الحرف %d)",
+	"error.body_unknown_key":             "يحتوي المحتوى على مفتاح غير معروف %s",
+	"error.body_no_json_equivalent":      "يحتوي المحتوى على %s ليس له مقابل في JSON",
+	"error.body_single_csv_record":       "يجب أن يحتوي المحتوى على صف عناوين وسجل CSV واحد",
+	"error.body_single_xml_element":      "يجب أن يحتوي المحتوى على عنصر XML واحد فقط",
+	"error.body_xml_too_deep":            "يحتوي المحتوى على XML متداخل بعمق زائد في %q",
+	"error.invalid_content_type":         "نوع الوسائط غير مدعوم %q",
+	"error.unsupported_media_type":       "نوع الوسائط غير مدعوم %s، استخدم أحد الأنواع %s",
+	"error.unsupported_body_format":      "نوع الوسائط غير مدعوم: لا يمكن استخدام محتوى %s لهذا الطلب",
+	"error.invalid_last_event_id":        "ترويسة Last-Event-ID غير صالحة %q",
+	"error.idempotency_key_too_long":     "يجب ألا يتجاوز Idempotency-Key %d حرفًا",
+	"error.patch_operation":              "العملية %d: %s",
+	"error.patch_value_required":         "تتطلب %s قيمة",
+	"error.patch_invalid_value":          "قيمة غير صالحة: %s",
+	"error.patch_unsupported_op":         "العملية %q غير مدعومة، المتوقع add أو remove أو replace أو test",
+	"error.patch_path_start":             "يجب أن يبدأ المسار %q بـ /",
+	"error.patch_path_missing":           "عنصر المسار %q غير موجود",
+	"error.patch_remove_document":        "لا يمكن حذف المستند بأكمله",
+	"error.patch_append_only":            "لا يمكن استخدام - إلا للإضافة إلى نهاية مصفوفة",
+	"error.patch_invalid_index":          "فهرس مصفوفة غير صالح %q",
+	"error.patch_index_out_of_range":     "فهرس المصفوفة %d خارج النطاق",
+	"error.patched_incorrect_type":       "يحتوي الفيلم بعد التصحيح على نوع JSON غير صحيح للحقل %q",
+	"error.patched_unknown_key":          "يحتوي الفيلم بعد التصحيح على مفتاح غير معروف %s",
+	"error.patched_not_object":           "يجب أن يكون الفيلم بعد التصحيح كائن JSON",
+	"error.patched_id_changed":           "لا يمكن تغيير id الفيلم",
+	"error.patched_external_id_changed":  "لا يمكن تغيير external_id الفيلم",
+	"error.misdirected_request":          "هذا الخادم لا يخدم المضيف %q",
+
+	"title.internal_error":               "خطأ داخلي في الخادم",
+	"title.not_found":                    "المورد غير موجود",
+	"title.movie_not_found":              "الفيلم غير موجود",
+	"title.webhook_not_found":            "الـ Webhook غير موجود",
+	"title.method_not_allowed":           "الطريقة غير مسموح بها",
+	"title.bad_request":                  "طلب غير صالح",
+	"title.unsupported_media_type":       "نوع وسائط غير مدعوم",
+	"title.not_acceptable":               "غير مقبول",
+	"title.validation_failed":            "فشل التحقق",
+	"title.edit_conflict":                "تعارض في التعديل",
+	"title.patch_test_failed":            "فشل اختبار التصحيح",
+	"title.invalid_patch":                "تصحيح غير صالح",
+	"title.duplicate_external_id":        "معرّف خارجي مكرر",
+	"title.idempotency_key_reused":       "مفتاح عدم التكرار مستخدم مسبقًا",
+	"title.idempotency_key_in_flight":    "مفتاح عدم التكرار قيد الاستخدام",
+	"title.invalid_authentication_token": "رمز مصادقة غير صالح",
+	"title.authentication_required":      "المصادقة مطلوبة",
+	"title.not_permitted":                "غير مسموح",
+	"title.misdirected_request":          "طلب موجه بشكل خاطئ",
+
+	"message.movie_deleted": "تم حذف الفيلم ذي المعرف %d",
+}
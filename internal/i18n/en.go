@@ -0,0 +1,102 @@
+package i18n
+
+var en = map[string]string{
+	"validation.required":                  "should not be empty",
+	"validation.too_long_bytes":            "should not be greater than %d bytes",
+	"validation.too_short_bytes":           "should be at least %d bytes long",
+	"validation.not_positive":              "should be a positive number",
+	"validation.out_of_range":              "should be between %d and %d",
+	"validation.too_few":                   "should contain at least %d items",
+	"validation.too_many":                  "should not contain more than %d items",
+	"validation.too_small":                 "should be at least %s",
+	"validation.too_large":                 "should not be greater than %s",
+	"validation.length_bytes":              "should be exactly %d bytes long",
+	"validation.length_items":              "should contain exactly %d items",
+	"validation.not_permitted":             "please use one of %s",
+	"validation.duplicate":                 "must not contain duplicates",
+	"validation.invalid_format":            "is not in a valid format",
+	"validation.constraint_violation":      "is not accepted by the database",
+	"validation.genres_too_few":            "should contain at least %d genre",
+	"validation.genres_too_many":           "should not contain more than %d genres",
+	"validation.genres_duplicate":          "must not contain duplicate genres",
+	"validation.genres_not_permitted":      "please use the following permitted genres %s",
+	"validation.url_invalid":               "should be an absolute http or https URL",
+	"validation.url_not_public":            "must resolve to a public address",
+	"validation.events_too_few":            "should contain at least %d event",
+	"validation.events_duplicate":          "must not contain duplicate events",
+	"validation.events_not_permitted":      "please use the following events %s",
+	"validation.update_mask_not_permitted": "may only contain title, runtime, year and genres",
+	"validation.id_mismatch":               "must match the id in the URL",
+	"validation.external_id_mismatch":      "must match the external_id in the URL",
+	"validation.immutable":                 "cannot be changed",
+
+	"error.internal":                     "the server encountered a problem and could not process your request",
+	"error.not_found":                    "the requested resource could not be found",
+	"error.method_not_allowed":           "the %s method is not supported for this resource",
+	"error.not_acceptable":               "the resource is only available as %s",
+	"error.validation_failed":            "the request failed validation",
+	"error.edit_conflict":                "unable to update the record due to an edit conflict, please try again",
+	"error.patch_test_failed":            "the movie does not match the test operation of the patch",
+	"error.duplicate_external_id":        "a different movie already has this external_id",
+	"error.idempotency_key_reused":       "the Idempotency-Key was already used for a different request",
+	"error.idempotency_key_in_flight":    "a request with this Idempotency-Key is still being processed, please try again later",
+	"error.invalid_authentication_token": "invalid or missing authentication token",
+	"error.authentication_required":      "you must be authenticated to access this resource",
+	"error.not_permitted":                "your account does not have the permissions needed to access this resource",
+	"error.bad_request":                  "the request could not be read",
+	"error.body_empty":                   "body must not be empty",
+	"error.body_too_large":               "body must not be larger than %d bytes",
+	"error.body_single_value":            "body must only contain a single JSON value",
+	"error.body_badly_formed":            "body contains badly-formed %s",
+	"error.body_badly_formed_at":         "body contains badly-formed %s (at character %d)",
+	"error.body_incorrect_type":          "body contains incorrect JSON type for field %q",
+	"error.body_incorrect_type_at":       "body contains incorrect JSON type (at character %d)",
+	"error.body_unknown_key":             "body contains unknown key %s",
+	"error.body_no_json_equivalent":      "body contains %s that has no JSON equivalent",
+	"error.body_single_csv_record":       "body must contain a header row and a single CSV record",
+	"error.body_single_xml_element":      "body must only contain a single XML element",
+	"error.body_xml_too_deep":            "body contains XML nested too deeply in %q",
+	"error.invalid_content_type":         "unsupported media type %q",
+	"error.unsupported_media_type":       "unsupported media type %s, use one of %s",
+	"error.unsupported_body_format":      "unsupported media type: %s bodies cannot be used for this request",
+	"error.invalid_last_event_id":        "invalid Last-Event-ID header %q",
+	"error.idempotency_key_too_long":     "Idempotency-Key must not be longer than %d characters",
+	"error.patch_operation":              "operation %d: %s",
+	"error.patch_value_required":         "%s requires a value",
+	"error.patch_invalid_value":          "invalid value: %s",
+	"error.patch_unsupported_op":         "unsupported op %q, expected add, remove, replace or test",
+	"error.patch_path_start":             "path %q must start with /",
+	"error.patch_path_missing":           "path member %q does not exist",
+	"error.patch_remove_document":        "the whole document cannot be removed",
+	"error.patch_append_only":            "- can only be used to add to the end of an array",
+	"error.patch_invalid_index":          "invalid array index %q",
+	"error.patch_index_out_of_range":     "array index %d is out of range",
+	"error.patched_incorrect_type":       "patched movie contains incorrect JSON type for field %q",
+	"error.patched_unknown_key":          "patched movie contains unknown key %s",
+	"error.patched_not_object":           "patched movie must be a JSON object",
+	"error.patched_id_changed":           "the movie id cannot be changed",
+	"error.patched_external_id_changed":  "the movie external_id cannot be changed",
+	"error.misdirected_request":          "this server does not serve the host %q",
+
+	"title.internal_error":               "Internal server error",
+	"title.not_found":                    "Resource not found",
+	"title.movie_not_found":              "Movie not found",
+	"title.webhook_not_found":            "Webhook not found",
+	"title.method_not_allowed":           "Method not allowed",
+	"title.bad_request":                  "Bad request",
+	"title.unsupported_media_type":       "Unsupported media type",
+	"title.not_acceptable":               "Not acceptable",
+	"title.validation_failed":            "Validation failed",
+	"title.edit_conflict":                "Edit conflict",
+	"title.patch_test_failed":            "Patch test failed",
+	"title.invalid_patch":                "Invalid patch",
+	"title.duplicate_external_id":        "Duplicate external ID",
+	"title.idempotency_key_reused":       "Idempotency key reused",
+	"title.idempotency_key_in_flight":    "Idempotency key in use",
+	"title.invalid_authentication_token": "Invalid authentication token",
+	"title.authentication_required":      "Authentication required",
+	"title.not_permitted":                "Not permitted",
+	"title.misdirected_request":          "Misdirected request",
+
+	"message.movie_deleted": "movie with the id %d has been deleted",
+}
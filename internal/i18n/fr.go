@@ -0,0 +1,102 @@
+package i18n
+
+var fr = map[string]string{
+	"validation.required":                  "ne doit pas être vide",
+	"validation.too_long_bytes":            "ne doit pas dépasser %d octets",
+	"validation.too_short_bytes":           "doit contenir au moins %d octets",
+	"validation.not_positive":              "doit être un nombre positif",
+	"validation.out_of_range":              "doit être compris entre %d et %d",
+	"validation.too_few":                   "doit contenir au moins %d éléments",
+	"validation.too_many":                  "ne doit pas contenir plus de %d éléments",
+	"validation.too_small":                 "doit être au moins égal à %s",
+	"validation.too_large":                 "ne doit pas dépasser %s",
+	"validation.length_bytes":              "doit contenir exactement %d octets",
+	"validation.length_items":              "doit contenir exactement %d éléments",
+	"validation.not_permitted":             "veuillez utiliser l'une des valeurs %s",
+	"validation.duplicate":                 "ne doit pas contenir de doublons",
+	"validation.invalid_format":            "n'est pas dans un format valide",
+	"validation.constraint_violation":      "n'est pas accepté par la base de données",
+	"validation.genres_too_few":            "doit contenir au moins %d genre",
+	"validation.genres_too_many":           "ne doit pas contenir plus de %d genres",
+	"validation.genres_duplicate":          "ne doit pas contenir de genres en double",
+	"validation.genres_not_permitted":      "veuillez utiliser les genres autorisés suivants %s",
+	"validation.url_invalid":               "doit être une URL http ou https absolue",
+	"validation.url_not_public":            "doit correspondre à une adresse publique",
+	"validation.events_too_few":            "doit contenir au moins %d événement",
+	"validation.events_duplicate":          "ne doit pas contenir d'événements en double",
+	"validation.events_not_permitted":      "veuillez utiliser les événements suivants %s",
+	"validation.update_mask_not_permitted": "ne peut contenir que title, runtime, year et genres",
+	"validation.id_mismatch":               "doit correspondre à l'id de l'URL",
+	"validation.external_id_mismatch":      "doit correspondre à l'external_id de l'URL",
+	"validation.immutable":                 "ne peut pas être modifié",
+
+	"error.internal":                     "le serveur a rencontré un problème et n'a pas pu traiter votre requête",
+	"error.not_found":                    "la ressource demandée est introuvable",
+	"error.method_not_allowed":           "la méthode %s n'est pas prise en charge pour cette ressource",
+	"error.not_acceptable":               "la ressource n'est disponible qu'en %s",
+	"error.validation_failed":            "la requête n'est pas valide",
+	"error.edit_conflict":                "impossible de mettre à jour l'enregistrement à cause d'un conflit de modification, veuillez réessayer",
+	"error.patch_test_failed":            "le film ne correspond pas à l'opération test du patch",
+	"error.duplicate_external_id":        "un autre film a déjà cet external_id",
+	"error.idempotency_key_reused":       "l'Idempotency-Key a déjà été utilisée pour une autre requête",
+	"error.idempotency_key_in_flight":    "une requête avec cette Idempotency-Key est encore en cours de traitement, veuillez réessayer plus tard",
+	"error.invalid_authentication_token": "jeton d'authentification invalide ou manquant",
+	"error.authentication_required":      "vous devez être authentifié pour accéder à cette ressource",
+	"error.not_permitted":                "votre compte n'a pas les permissions nécessaires pour accéder à cette ressource",
+	"error.bad_request":                  "la requête n'a pas pu être lue",
+	"error.body_empty":                   "le corps ne doit pas être vide",
+	"error.body_too_large":               "le corps ne doit pas dépasser %d octets",
+	"error.body_single_value":            "le corps ne doit contenir qu'une seule valeur JSON",
+	"error.body_badly_formed":            "le corps contient du %s mal formé",
+	"error.body_badly_formed_at":         "le corps contient du %s mal formé (au caractère %d)",
+	"error.body_incorrect_type":          "le corps contient un type JSON incorrect pour le champ %q",
+	"error.body_incorrect_type_at":       "le corps contient un type JSON incorrect (au caractère %d)",
+	"error.body_unknown_key":             "le corps contient la clé inconnue %s",
+	"error.body_no_json_equivalent":      "le corps contient du %s sans équivalent JSON",
+	"error.body_single_csv_record":       "le corps doit contenir une ligne d'en-tête et un seul enregistrement CSV",
+	"error.body_single_xml_element":      "le corps ne doit contenir qu'un seul élément XML",
+	"error.body_xml_too_deep":            "le corps contient du XML trop imbriqué dans %q",
+	"error.invalid_content_type":         "type de média non pris en charge %q",
+	"error.unsupported_media_type":       "type de média non pris en charge %s, utilisez l'un des types %s",
+	"error.unsupported_body_format":      "type de média non pris en charge : les corps %s ne peuvent pas être utilisés pour cette requête",
+	"error.invalid_last_event_id":        "en-tête Last-Event-ID invalide %q",
+	"error.idempotency_key_too_long":     "l'Idempotency-Key ne doit pas dépasser %d caractères",
+	"error.patch_operation":              "opération %d : %s",
+	"error.patch_value_required":         "%s nécessite une valeur",
+	"error.patch_invalid_value":          "valeur invalide : %s",
+	"error.patch_unsupported_op":         "opération %q non prise en charge, add, remove, replace ou test attendu",
+	"error.patch_path_start":             "le chemin %q doit commencer par /",
+	"error.patch_path_missing":           "le membre de chemin %q n'existe pas",
+	"error.patch_remove_document":        "le document entier ne peut pas être supprimé",
+	"error.patch_append_only":            "- ne peut être utilisé que pour ajouter à la fin d'un tableau",
+	"error.patch_invalid_index":          "indice de tableau invalide %q",
+	"error.patch_index_out_of_range":     "l'indice de tableau %d est hors limites",
+	"error.patched_incorrect_type":       "le film modifié contient un type JSON incorrect pour le champ %q",
+	"error.patched_unknown_key":          "le film modifié contient la clé inconnue %s",
+	"error.patched_not_object":           "le film modifié doit être un objet JSON",
+	"error.patched_id_changed":           "l'id du film ne peut pas être modifié",
+	"error.patched_external_id_changed":  "l'external_id du film ne peut pas être modifié",
+	"error.misdirected_request":          "ce serveur ne sert pas l'hôte %q",
+
+	"title.internal_error":               "Erreur interne du serveur",
+	"title.not_found":                    "Ressource introuvable",
+	"title.movie_not_found":              "Film introuvable",
+	"title.webhook_not_found":            "Webhook introuvable",
+	"title.method_not_allowed":           "Méthode non autorisée",
+	"title.bad_request":                  "Requête incorrecte",
+	"title.unsupported_media_type":       "Type de média non pris en charge",
+	"title.not_acceptable":               "Non acceptable",
+	"title.validation_failed":            "Échec de la validation",
+	"title.edit_conflict":                "Conflit de modification",
+	"title.patch_test_failed":            "Échec du test du patch",
+	"title.invalid_patch":                "Patch invalide",
+	"title.duplicate_external_id":        "ID externe en double",
+	"title.idempotency_key_reused":       "Clé d'idempotence réutilisée",
+	"title.idempotency_key_in_flight":    "Clé d'idempotence en cours d'utilisation",
+	"title.invalid_authentication_token": "Jeton d'authentification invalide",
+	"title.authentication_required":      "Authentification requise",
+	"title.not_permitted":                "Non autorisé",
+	"title.misdirected_request":          "Requête mal dirigée",
+
+	"message.movie_deleted": "le film avec l'id %d a été supprimé",
+}
@@ -0,0 +1,100 @@
+// Package i18n holds the catalogue of the messages shown to API clients and
+// picks the language to show them in from an Accept-Language header.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is used when a client accepts none of the catalogued
+// languages, and for messages missing from a catalogue.
+const DefaultLanguage = "en"
+
+// catalogues maps a language tag to the text of every message key. Texts are
+// fmt formats, so a translation can reorder its arguments with %[n]s.
+var catalogues = map[string]map[string]string{
+	"en": en,
+	"fr": fr,
+	"ar": ar,
+}
+
+// Message is a catalogue key and the arguments of its text. Arguments that
+// are Messages themselves are shown in the same language.
+type Message struct {
+	Key  string
+	Args []any
+}
+
+// Text returns the text of key in lang with args filled in, falling back to
+// DefaultLanguage and then to the key itself.
+func Text(lang, key string, args ...any) string {
+	text, ok := catalogues[lang][key]
+	if !ok {
+		text, ok = catalogues[DefaultLanguage][key]
+	}
+	if !ok {
+		return key
+	}
+	texts := make([]any, len(args))
+	for i, arg := range args {
+		if m, ok := arg.(Message); ok {
+			arg = m.Text(lang)
+		}
+		texts[i] = arg
+	}
+	return fmt.Sprintf(text, texts...)
+}
+
+// Text returns the text of m in lang.
+func (m Message) Text(lang string) string {
+	return Text(lang, m.Key, m.Args...)
+}
+
+// Match returns the catalogued language preferred by an Accept-Language
+// header. A range such as fr-CA matches fr, and DefaultLanguage is returned
+// when nothing matches.
+func Match(acceptLanguage string) string {
+	type languageRange struct {
+		tag     string
+		quality float64
+	}
+	var ranges []languageRange
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if tag == "" || quality <= 0 {
+			continue
+		}
+		ranges = append(ranges, languageRange{tag: strings.ToLower(tag), quality: quality})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, rng := range ranges {
+		if rng.tag == "*" {
+			return DefaultLanguage
+		}
+		primary, _, _ := strings.Cut(rng.tag, "-")
+		if _, ok := catalogues[primary]; ok {
+			return primary
+		}
+	}
+	return DefaultLanguage
+}
+
+// Has reports whether key is in the DefaultLanguage catalogue.
+func Has(key string) bool {
+	_, ok := catalogues[DefaultLanguage][key]
+	return ok
+}
@@ -0,0 +1,79 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		expected       string
+	}{
+		{"no header test", "", "en"},
+		{"exact test", "fr", "fr"},
+		{"region test", "ar-LB", "ar"},
+		{"case test", "FR-ca", "fr"},
+		{"quality test", "en;q=0.5, ar;q=0.8", "ar"},
+		{"first wins test", "fr, ar", "fr"},
+		{"unknown skipped test", "de-DE, fr;q=0.7", "fr"},
+		{"excluded test", "fr;q=0, ar;q=0.1", "ar"},
+		{"wildcard test", "*, fr;q=0.5", "en"},
+		{"unknown test", "de", "en"},
+		{"bad quality test", "fr;q=high", "en"},
+	}
+	for _, e := range tests {
+		lang := Match(e.acceptLanguage)
+		if lang != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, lang)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name     string
+		lang     string
+		key      string
+		args     []any
+		expected string
+	}{
+		{"english test", "en", "validation.too_long_bytes", []any{500}, "should not be greater than 500 bytes"},
+		{"french test", "fr", "validation.out_of_range", []any{1, 100}, "doit être compris entre 1 et 100"},
+		{"arabic test", "ar", "validation.required", nil, "يجب ألا يكون فارغًا"},
+		{"unknown language test", "de", "validation.required", nil, "should not be empty"},
+		{"unknown key test", "fr", "validation.unknown", nil, "validation.unknown"},
+		{"nested message test", "fr", "error.patch_operation", []any{0, Message{Key: "error.patch_path_missing", Args: []any{"rating"}}}, "opération 0 : le membre de chemin \"rating\" n'existe pas"},
+	}
+	for _, e := range tests {
+		text := Text(e.lang, e.key, e.args...)
+		if text != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, text)
+		}
+	}
+}
+
+var verbRX = regexp.MustCompile(`%(\[\d+\])?[a-z]`)
+
+// TestCatalogues checks that every language translates every message with the
+// same number of arguments as English.
+func TestCatalogues(t *testing.T) {
+	for lang, catalogue := range catalogues {
+		for key, text := range en {
+			translation, ok := catalogue[key]
+			if !ok {
+				t.Errorf("%s: missing %s", lang, key)
+				continue
+			}
+			if len(verbRX.FindAllString(text, -1)) != len(verbRX.FindAllString(translation, -1)) {
+				t.Errorf("%s: %s expected the arguments of %q but got %q", lang, key, text, translation)
+			}
+		}
+		for key := range catalogue {
+			if _, ok := en[key]; !ok {
+				t.Errorf("%s: %s is not in the en catalogue", lang, key)
+			}
+		}
+	}
+}
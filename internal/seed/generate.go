@@ -0,0 +1,177 @@
+package seed
+
+import (
+	"fmt"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+const (
+	firstYear = 1920
+	lastYear  = 2025
+)
+
+// runtimes are the mean and spread of the runtime of a movie by its main
+// genre, in minutes.
+var runtimes = map[string]struct{ mean, spread float64 }{
+	"action":    {115, 16},
+	"adventure": {120, 18},
+	"comedy":    {98, 12},
+	"horror":    {94, 11},
+	"drama":     {118, 20},
+}
+
+// genreWeights makes drama and comedy more common than horror, roughly like
+// an actual catalogue. It follows the order of data.Genres.
+var genreWeights = []int{20, 14, 24, 12, 30}
+
+var (
+	adjectives = []string{
+		"Last", "Silent", "Broken", "Hidden", "Crimson", "Endless", "Golden", "Lost", "Midnight", "Wild",
+		"Burning", "Frozen", "Forgotten", "Secret", "Dark", "Bright", "Hollow", "Savage", "Quiet", "Electric",
+		"Distant", "Restless", "Final", "Lonely", "Iron", "Velvet", "Shattered", "Sweet", "Bitter", "Falling",
+	}
+	nouns = []string{
+		"Horizon", "River", "Kingdom", "Shadow", "Summer", "Promise", "Storm", "Garden", "Frontier", "Empire",
+		"Station", "Island", "Mirror", "Harbor", "Signal", "Road", "Winter", "Heart", "Crown", "Machine",
+		"Valley", "Witness", "Reckoning", "Ghost", "Stranger", "Letter", "Orchard", "Tide", "Circus", "Voyage",
+	}
+	places = []string{
+		"Paris", "the North", "Tomorrow", "the Deep", "Marrakesh", "the Valley", "Berlin", "Saturn", "Avalon",
+		"the Old Town", "Lisbon", "the Dunes", "Harlem", "the Moon", "Beirut", "the Night", "Kyoto", "Nowhere",
+	}
+	names = []string{
+		"Ada", "Omar", "Juliette", "Sam", "Nadia", "Felix", "Rosa", "Karim", "Mabel", "Theo",
+		"Leila", "Hugo", "Ines", "Walter", "Yara", "Milo", "Clara", "Elias", "Zoe", "Victor",
+	}
+	sequels = []string{"II", "2", "Part Two", "Returns", "Reloaded"}
+)
+
+// generated returns the movies of a generated set. The set is made from its
+// own random source seeded with seed, so it never changes.
+func generated(name string, seed int64, n int) func() []data.Movie {
+	return func() []data.Movie {
+		rng := rand.New(rand.NewSource(seed))
+		movies := make([]data.Movie, n)
+		for i := range movies {
+			movies[i] = generateMovie(rng)
+			movies[i].ExternalID = fmt.Sprintf("seed-%s-%05d", name, i+1)
+		}
+		return movies
+	}
+}
+
+func generateMovie(rng *rand.Rand) data.Movie {
+	genres := []string{pickGenre(rng)}
+	for _, chance := range []float64{0.45, 0.15} {
+		if rng.Float64() >= chance {
+			break
+		}
+		genre := pickGenre(rng)
+		if !contains(genres, genre) {
+			genres = append(genres, genre)
+		}
+	}
+
+	r := runtimes[genres[0]]
+	runtime := int32(math.Round(r.mean + rng.NormFloat64()*r.spread))
+	if runtime < 70 {
+		runtime = 70
+	}
+
+	// most movies are recent, the rest are spread back to the silent era.
+	year := lastYear - int32(math.Abs(rng.NormFloat64())*15)
+	if year < firstYear || rng.Intn(10) < 3 {
+		year = firstYear + int32(rng.Intn(lastYear-firstYear+1))
+	}
+
+	return data.Movie{
+		Title:   generateTitle(rng),
+		Runtime: runtime,
+		Year:    year,
+		Genres:  genres,
+	}
+}
+
+func generateTitle(rng *rand.Rand) string {
+	var title string
+	switch rng.Intn(6) {
+	case 0:
+		title = "The " + pick(rng, adjectives) + " " + pick(rng, nouns)
+	case 1:
+		title = pick(rng, adjectives) + " " + pick(rng, nouns)
+	case 2:
+		title = "The " + pick(rng, nouns) + " of " + pick(rng, places)
+	case 3:
+		title = pick(rng, names) + "'s " + pick(rng, nouns)
+	case 4:
+		title = pick(rng, adjectives) + " in " + pick(rng, places)
+	default:
+		title = "The " + pick(rng, nouns)
+	}
+	if rng.Intn(12) == 0 {
+		title += " " + pick(rng, sequels)
+	}
+	return title
+}
+
+func pickGenre(rng *rand.Rand) string {
+	total := 0
+	for _, w := range genreWeights {
+		total += w
+	}
+	n := rng.Intn(total)
+	for i, w := range genreWeights {
+		if n < w {
+			return data.Genres[i]
+		}
+		n -= w
+	}
+	return data.Genres[len(data.Genres)-1]
+}
+
+func pick(rng *rand.Rand, words []string) string {
+	return words[rng.Intn(len(words))]
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// demoMovies keep the sample-N external IDs the admin seed command gave them
+// before there were fixture sets, so existing databases are not seeded twice.
+func demoMovies() []data.Movie {
+	return []data.Movie{
+		{ExternalID: "sample-1", Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror"}},
+		{ExternalID: "sample-2", Title: "Back to the Future", Year: 1985, Runtime: 116, Genres: []string{"adventure", "comedy"}},
+		{ExternalID: "sample-3", Title: "Die Hard", Year: 1988, Runtime: 132, Genres: []string{"action"}},
+		{ExternalID: "sample-4", Title: "Groundhog Day", Year: 1993, Runtime: 101, Genres: []string{"comedy", "drama"}},
+		{ExternalID: "sample-5", Title: "Jurassic Park", Year: 1993, Runtime: 127, Genres: []string{"action", "adventure"}},
+		{ExternalID: "sample-6", Title: "Casablanca", Year: 1942, Runtime: 102, Genres: []string{"drama"}},
+		{ExternalID: "sample-7", Title: "Some Like It Hot", Year: 1959, Runtime: 121, Genres: []string{"comedy"}},
+		{ExternalID: "sample-8", Title: "The Shining", Year: 1980, Runtime: 146, Genres: []string{"horror", "drama"}},
+		{ExternalID: "sample-9", Title: "Raiders of the Lost Ark", Year: 1981, Runtime: 115, Genres: []string{"action", "adventure"}},
+		{ExternalID: "sample-10", Title: "Spirited Away", Year: 2001, Runtime: 125, Genres: []string{"adventure", "drama"}},
+		{ExternalID: "sample-11", Title: "Get Out", Year: 2017, Runtime: 104, Genres: []string{"horror", "comedy"}},
+		{ExternalID: "sample-12", Title: "Mad Max: Fury Road", Year: 2015, Runtime: 120, Genres: []string{"action", "adventure"}},
+	}
+}
+
+func edgeMovies() []data.Movie {
+	return []data.Movie{
+		{ExternalID: "seed-edge-longest-title", Title: strings.Repeat("x", 500), Year: 2000, Runtime: 100, Genres: []string{"drama"}},
+		{ExternalID: "seed-edge-all-genres", Title: "Every Genre", Year: 2000, Runtime: 100, Genres: append([]string(nil), data.Genres...)},
+		{ExternalID: "seed-edge-shortest", Title: "S", Year: 1, Runtime: 1, Genres: []string{"comedy"}},
+		{ExternalID: "seed-edge-longest-runtime", Title: "Logistics", Year: 2012, Runtime: 51420, Genres: []string{"drama"}},
+		{ExternalID: "seed-edge-unicode", Title: "Amélie — 天国と地獄 — ليلة", Year: 2001, Runtime: 122, Genres: []string{"comedy"}},
+		{ExternalID: "seed-edge-csv", Title: `Commas, "quotes" and <tags> & more`, Year: 1999, Runtime: 90, Genres: []string{"action"}},
+		{ExternalID: strings.Repeat("e", 255), Title: "Longest External ID", Year: 2000, Runtime: 100, Genres: []string{"horror"}},
+	}
+}
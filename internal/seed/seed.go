@@ -0,0 +1,98 @@
+// Package seed loads named fixture sets of movies through data.Movies, so
+// the same data can be put in front of any storage for development, demos and
+// tests.
+package seed
+
+import (
+	"context"
+	"fmt"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"reflect"
+	"sort"
+)
+
+// Set is a named fixture set. The movies of a set are the same on every call,
+// and each has an external ID that is unique across sets.
+type Set struct {
+	Name        string
+	Description string
+	movies      func() []data.Movie
+}
+
+// Movies returns the movies of the set.
+func (s Set) Movies() []data.Movie {
+	return s.movies()
+}
+
+var sets = []Set{
+	{"demo", "a dozen well-known movies to click through", demoMovies},
+	{"edge", "movies at the limits of validation, for tests", edgeMovies},
+	{"small", "100 generated movies, for tests", generated("small", 1, 100)},
+	{"dev", "3000 generated movies across every genre and decade, for local development", generated("dev", 2, 3000)},
+	{"large", "20000 generated movies, for load testing", generated("large", 3, 20000)},
+}
+
+// Sets returns every fixture set.
+func Sets() []Set {
+	return append([]Set(nil), sets...)
+}
+
+// Lookup returns the set with the given name.
+func Lookup(name string) (Set, bool) {
+	for _, s := range sets {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Set{}, false
+}
+
+// Result counts what Load did with the movies of a set.
+type Result struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+// Load writes the movies of set to movies. Movies are matched on their
+// external ID, so loading a set again only rewrites the movies that changed
+// since, e.g. by hand, and leaves the rest and their versions alone.
+func Load(ctx context.Context, movies data.Movies, set Set) (Result, error) {
+	var result Result
+
+	all, err := movies.GetAllMovies(ctx)
+	if err != nil {
+		return result, err
+	}
+	existing := make(map[string]*data.Movie, len(all))
+	for _, movie := range all {
+		if movie.ExternalID != "" {
+			existing[movie.ExternalID] = movie
+		}
+	}
+
+	for _, movie := range set.Movies() {
+		if stored, ok := existing[movie.ExternalID]; ok && sameMovie(stored, &movie) {
+			result.Unchanged++
+			continue
+		}
+		created, err := movies.UpsertMovie(ctx, &movie)
+		if err != nil {
+			return result, fmt.Errorf("seeding %s: %w", movie.ExternalID, err)
+		}
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+	}
+	return result, nil
+}
+
+func sameMovie(a, b *data.Movie) bool {
+	genresA := append([]string(nil), a.Genres...)
+	genresB := append([]string(nil), b.Genres...)
+	sort.Strings(genresA)
+	sort.Strings(genresB)
+	return a.Title == b.Title && a.Runtime == b.Runtime && a.Year == b.Year && reflect.DeepEqual(genresA, genresB)
+}
@@ -0,0 +1,107 @@
+package seed
+
+import (
+	"context"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"github.com/rrebeiz/quickmovies/internal/validator"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenresMatchTag(t *testing.T) {
+	field, _ := reflect.TypeOf(data.Movie{}).FieldByName("Genres")
+	tag := field.Tag.Get("validate")
+	_, permitted, _ := strings.Cut(tag, "oneof=")
+	if !reflect.DeepEqual(strings.Fields(permitted), data.Genres) {
+		t.Errorf("expected data.Genres to be %s but got %v", permitted, data.Genres)
+	}
+}
+
+func TestSets(t *testing.T) {
+	externalIDs := make(map[string]string)
+	for _, set := range Sets() {
+		movies := set.Movies()
+		if len(movies) == 0 {
+			t.Errorf("%s: expected movies", set.Name)
+		}
+		if !reflect.DeepEqual(movies, set.Movies()) {
+			t.Errorf("%s: expected the same movies on every call", set.Name)
+		}
+		for _, movie := range movies {
+			v := validator.NewValidator()
+			data.ValidateMovie(v, &movie)
+			if !v.Valid() {
+				t.Errorf("%s: expected %s to be valid but got %v", set.Name, movie.ExternalID, v.FirstErrors("en"))
+			}
+			if other, ok := externalIDs[movie.ExternalID]; ok {
+				t.Errorf("%s: external id %s is also used by %s", set.Name, movie.ExternalID, other)
+			}
+			externalIDs[movie.ExternalID] = set.Name
+		}
+	}
+}
+
+func TestGeneratedSpread(t *testing.T) {
+	set, _ := Lookup("dev")
+	genres := make(map[string]int)
+	decades := make(map[int32]int)
+	for _, movie := range set.Movies() {
+		for _, genre := range movie.Genres {
+			genres[genre]++
+		}
+		decades[movie.Year/10*10]++
+	}
+	for _, genre := range data.Genres {
+		if genres[genre] < 100 {
+			t.Errorf("expected at least 100 %s movies but got %d", genre, genres[genre])
+		}
+	}
+	for decade := int32(firstYear); decade <= lastYear; decade += 10 {
+		if decades[decade] == 0 {
+			t.Errorf("expected movies from the %ds", decade)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	movies := data.NewMemoryMovieModel()
+	ctx := context.Background()
+	set, ok := Lookup("small")
+	if !ok {
+		t.Fatal("expected the small set")
+	}
+
+	result, err := Load(ctx, movies, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (Result{Created: 100}) {
+		t.Errorf("expected 100 created movies but got %+v", result)
+	}
+
+	all, _ := movies.GetAllMovies(ctx)
+	changed := all[0]
+	changed.Title = "changed by hand"
+	err = movies.UpdateMovie(ctx, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untouched := all[1]
+
+	result, err = Load(ctx, movies, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (Result{Updated: 1, Unchanged: 99}) {
+		t.Errorf("expected 1 updated and 99 unchanged movies but got %+v", result)
+	}
+	stored, _ := movies.GetMovie(ctx, untouched.ID)
+	if stored.Version != untouched.Version {
+		t.Errorf("expected the unchanged movie to keep version %d but got %d", untouched.Version, stored.Version)
+	}
+	all, _ = movies.GetAllMovies(ctx)
+	if len(all) != 100 {
+		t.Errorf("expected 100 movies after loading twice but got %d", len(all))
+	}
+}
@@ -0,0 +1,236 @@
+package validator
+
+import (
+	"fmt"
+	"github.com/rrebeiz/quickmovies/internal/i18n"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// customRule is a rule added with RegisterRule.
+type customRule struct {
+	code    string
+	message string
+	check   func(value reflect.Value) bool
+}
+
+var (
+	customRules = make(map[string]customRule)
+	regexps     sync.Map
+	indexRX     = regexp.MustCompile(`\[\d+\]`)
+)
+
+// RegisterRule adds a rule named name to the validate tags. check reports
+// whether a value passes the rule, and a value failing it is reported with
+// code and the catalogue message. Rules are meant to be registered from init
+// functions, before any struct is checked.
+func RegisterRule(name, code, message string, check func(value reflect.Value) bool) {
+	customRules[name] = customRule{code: code, message: message, check: check}
+}
+
+// Struct checks the fields of s, a struct or a pointer to one, against the
+// rules in their validate tags, and adds an error for every failed rule. The
+// rules are separated by commas:
+//
+//	required       the value is not the zero value; a field failing it is not checked further
+//	min=n, max=n   bounds of a number, or of the length of a string in bytes, or of a slice
+//	len=n          the exact length of a string in bytes, or of a slice
+//	oneof=a b c    the value is one of the space-separated values
+//	unique         the items of a slice are all different
+//	regexp=re      a string matches re; it takes the rest of the tag, so it comes last
+//	dive           the rules after it apply to every item of a slice
+//
+// along with the rules added with RegisterRule. Fields are named after their
+// json tag, nested structs are checked with paths such as cast.name and the
+// items of a slice after dive with paths such as genres[2].
+//
+// A failed rule is reported with the catalogue message validation.<field>_<code>
+// when there is one, with the indexes left out of the field, or else with the
+// message of the rule. This lets a field say "should contain at least 1 genre"
+// rather than "should contain at least 1 items".
+func (v *Validator) Struct(s any) {
+	v.checkStruct("", reflect.Indirect(reflect.ValueOf(s)))
+}
+
+func (v *Validator) checkStruct(prefix string, value reflect.Value) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			v.checkStruct(prefix, value.Field(i))
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		v.checkValue(name, value.Field(i), field.Tag.Get("validate"))
+	}
+}
+
+func (v *Validator) checkValue(path string, value reflect.Value, tag string) {
+	rules, items, dive := parseTag(tag)
+	for _, r := range rules {
+		if r.name == "required" {
+			if value.IsZero() {
+				v.fail(path, "required", "validation.required")
+				return
+			}
+			continue
+		}
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return
+			}
+			value = value.Elem()
+		}
+		v.checkRule(path, value, r)
+	}
+
+	value = reflect.Indirect(value)
+	switch {
+	case dive:
+		if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+			panic(fmt.Sprintf("validator: dive on %s, which is not a slice", path))
+		}
+		for i := 0; i < value.Len(); i++ {
+			v.checkValue(fmt.Sprintf("%s[%d]", path, i), value.Index(i), items)
+		}
+	case value.Kind() == reflect.Struct:
+		v.checkStruct(path, value)
+	}
+}
+
+type rule struct {
+	name  string
+	param string
+}
+
+// parseTag splits a validate tag into its rules and, after dive, the tag of
+// the items.
+func parseTag(tag string) (rules []rule, items string, dive bool) {
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "regexp=") {
+			part, tag = tag, ""
+		} else {
+			part, tag, _ = strings.Cut(tag, ",")
+		}
+		if part == "dive" {
+			return rules, tag, true
+		}
+		name, param, _ := strings.Cut(part, "=")
+		rules = append(rules, rule{name: name, param: param})
+	}
+	return rules, "", false
+}
+
+func (v *Validator) checkRule(path string, value reflect.Value, r rule) {
+	switch r.name {
+	case "min", "max", "len":
+		v.checkSize(path, value, r)
+	case "oneof":
+		options := strings.Fields(r.param)
+		if !PermittedValue(fmt.Sprint(value.Interface()), options...) {
+			v.fail(path, "not_permitted", "validation.not_permitted", options)
+		}
+	case "unique":
+		seen := make(map[any]bool, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			seen[value.Index(i).Interface()] = true
+		}
+		if len(seen) != value.Len() {
+			v.fail(path, "duplicate", "validation.duplicate")
+		}
+	case "regexp":
+		rx, ok := regexps.Load(r.param)
+		if !ok {
+			rx, _ = regexps.LoadOrStore(r.param, regexp.MustCompile(r.param))
+		}
+		if !rx.(*regexp.Regexp).MatchString(value.String()) {
+			v.fail(path, "invalid_format", "validation.invalid_format")
+		}
+	default:
+		custom, ok := customRules[r.name]
+		if !ok {
+			panic(fmt.Sprintf("validator: unknown rule %q on %s", r.name, path))
+		}
+		if !custom.check(value) {
+			v.fail(path, custom.code, custom.message)
+		}
+	}
+}
+
+// checkSize checks the min, max and len rules, which bound numbers and the
+// length of strings and slices.
+func (v *Validator) checkSize(path string, value reflect.Value, r rule) {
+	var number float64
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		number = value.Float()
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		n, err := strconv.Atoi(r.param)
+		if err != nil {
+			panic(fmt.Sprintf("validator: %s on %s is not a length", r.name, path))
+		}
+		unit := "items"
+		if value.Kind() == reflect.String {
+			unit = "bytes"
+		}
+		length := value.Len()
+		switch {
+		case r.name == "min" && length < n && unit == "bytes":
+			v.fail(path, "too_short", "validation.too_short_bytes", n)
+		case r.name == "min" && length < n:
+			v.fail(path, "too_few", "validation.too_few", n)
+		case r.name == "max" && length > n && unit == "bytes":
+			v.fail(path, "too_long", "validation.too_long_bytes", n)
+		case r.name == "max" && length > n:
+			v.fail(path, "too_many", "validation.too_many", n)
+		case r.name == "len" && length != n:
+			v.fail(path, "wrong_length", "validation.length_"+unit, n)
+		}
+		return
+	default:
+		panic(fmt.Sprintf("validator: %s on %s, which has no size", r.name, path))
+	}
+
+	bound, err := strconv.ParseFloat(r.param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validator: %s on %s is not a number", r.name, path))
+	}
+	switch {
+	case r.name == "min" && number < bound:
+		v.fail(path, "too_small", "validation.too_small", r.param)
+	case r.name == "max" && number > bound:
+		v.fail(path, "too_large", "validation.too_large", r.param)
+	case r.name == "len":
+		panic(fmt.Sprintf("validator: len on %s, which is a number", path))
+	}
+}
+
+// fail adds an error for a failed rule, with the message of the field when
+// the catalogue has one.
+func (v *Validator) fail(path, code, message string, args ...any) {
+	key := "validation." + indexRX.ReplaceAllString(path, "") + "_" + code
+	if i18n.Has(key) {
+		message = key
+	}
+	v.AddMessage(path, code, message, args...)
+}
@@ -0,0 +1,80 @@
+package validator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testCast struct {
+	Name string `json:"name" validate:"required"`
+	Role string `json:"role" validate:"oneof=lead support"`
+}
+
+type testMovie struct {
+	Title    string     `json:"title" validate:"required,min=2,max=10"`
+	Rating   float64    `json:"rating" validate:"min=0,max=10"`
+	Code     string     `json:"code,omitempty" validate:"len=3"`
+	Tags     []string   `json:"tags" validate:"max=2,unique,dive,regexp=^[a-z]+(,[a-z]+)*$"`
+	Sequel   *testCast  `json:"sequel"`
+	Cast     []testCast `json:"cast" validate:"dive"`
+	Even     int        `json:"even" validate:"even"`
+	internal string     `validate:"required"`
+	Ignored  string     `json:"-" validate:"required"`
+}
+
+func init() {
+	RegisterRule("even", "odd", "validation.not_permitted", func(value reflect.Value) bool {
+		return value.Int()%2 == 0
+	})
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name     string
+		movie    testMovie
+		expected map[string][]string
+	}{
+		{"valid test", testMovie{Title: "test", Code: "abc", Tags: []string{"a", "b,c"}, Cast: []testCast{{Name: "test", Role: "lead"}}}, map[string][]string{}},
+		{"required stops test", testMovie{Code: "abc"}, map[string][]string{"title": {"required"}}},
+		{"string length test", testMovie{Title: strings.Repeat("x", 11), Code: "abcd"}, map[string][]string{"title": {"too_long"}, "code": {"wrong_length"}}},
+		{"number test", testMovie{Title: "test", Code: "abc", Rating: 10.5}, map[string][]string{"rating": {"too_large"}}},
+		{"several errors test", testMovie{Title: "test", Code: "abc", Tags: []string{"a", "a", "B"}}, map[string][]string{"tags": {"too_many", "duplicate"}, "tags[2]": {"invalid_format"}}},
+		{"nested test", testMovie{Title: "test", Code: "abc", Sequel: &testCast{Role: "extra"}}, map[string][]string{"sequel.name": {"required"}, "sequel.role": {"not_permitted"}}},
+		{"slice of structs test", testMovie{Title: "test", Code: "abc", Cast: []testCast{{Name: "test", Role: "lead"}, {Role: "lead"}}}, map[string][]string{"cast[1].name": {"required"}}},
+		{"custom test", testMovie{Title: "test", Code: "abc", Even: 3}, map[string][]string{"even": {"odd"}}},
+	}
+	for _, e := range tests {
+		v := NewValidator()
+		v.Struct(&e.movie)
+
+		codes := make(map[string][]string)
+		for field, fieldErrors := range v.Errors {
+			for _, fieldError := range fieldErrors {
+				codes[field] = append(codes[field], fieldError.Code)
+			}
+		}
+		if !reflect.DeepEqual(e.expected, codes) {
+			t.Errorf("%s: expected %v but got %v", e.name, e.expected, codes)
+		}
+	}
+}
+
+func TestStructMessages(t *testing.T) {
+	v := NewValidator()
+	v.Struct(testMovie{Title: "t", Code: "abc", Tags: []string{"a", "b", "c"}, Cast: []testCast{{Name: "test", Role: "extra"}}})
+
+	expected := map[string]string{
+		"title":        "should be at least 2 bytes long",
+		"tags":         "should not contain more than 2 items",
+		"cast[0].role": "please use one of [lead support]",
+	}
+	if !reflect.DeepEqual(expected, v.FirstErrors("en")) {
+		t.Errorf("expected %v but got %v", expected, v.FirstErrors("en"))
+	}
+
+	localized := v.Errors["title"][0].Localize("fr")
+	if localized != "doit contenir au moins 2 octets" {
+		t.Errorf("expected a french message but got %s", localized)
+	}
+}
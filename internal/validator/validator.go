@@ -1,17 +1,55 @@
 package validator
 
+import (
+	"github.com/rrebeiz/quickmovies/internal/i18n"
+	"sort"
+)
+
+// CodeInvalid is the code of errors added without one.
+const CodeInvalid = "invalid"
+
+// Error is a failed check of a field.
+type Error struct {
+	// Code is a stable, machine-readable code for the failed check.
+	Code string
+	// Message is the English text of the error.
+	Message string
+	// Key and Args are the catalogue message of the error, so it can be shown
+	// in the client's language. Key is empty for errors added without one.
+	Key  string
+	Args []any
+}
+
+// Localize returns the text of e in lang.
+func (e Error) Localize(lang string) string {
+	if e.Key == "" {
+		return e.Message
+	}
+	return i18n.Text(lang, e.Key, e.Args...)
+}
+
 type Validator struct {
-	Errors map[string]string
+	// Errors holds the failed checks of every field, in the order they were
+	// made.
+	Errors map[string][]Error
 }
 
 func NewValidator() *Validator {
-	return &Validator{Errors: make(map[string]string)}
+	return &Validator{Errors: make(map[string][]Error)}
 }
 
 func (v *Validator) AddError(key, value string) {
-	if _, ok := v.Errors[key]; !ok {
-		v.Errors[key] = value
-	}
+	v.Errors[key] = append(v.Errors[key], Error{Code: CodeInvalid, Message: value})
+}
+
+// AddMessage adds the catalogue message with the given key and arguments.
+func (v *Validator) AddMessage(key, code, message string, args ...any) {
+	v.Errors[key] = append(v.Errors[key], Error{
+		Code:    code,
+		Message: i18n.Text(i18n.DefaultLanguage, message, args...),
+		Key:     message,
+		Args:    args,
+	})
 }
 
 func (v *Validator) Check(ok bool, key, value string) {
@@ -20,10 +58,36 @@ func (v *Validator) Check(ok bool, key, value string) {
 	}
 }
 
+func (v *Validator) CheckMessage(ok bool, key, code, message string, args ...any) {
+	if !ok {
+		v.AddMessage(key, code, message, args...)
+	}
+}
+
 func (v *Validator) Valid() bool {
 	return len(v.Errors) == 0
 }
 
+// Fields returns the keys of Errors in order.
+func (v *Validator) Fields() []string {
+	fields := make([]string, 0, len(v.Errors))
+	for field := range v.Errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// FirstErrors returns the first error of every field in lang, for clients
+// expecting a single message per field.
+func (v *Validator) FirstErrors(lang string) map[string]string {
+	errors := make(map[string]string, len(v.Errors))
+	for key, fieldErrors := range v.Errors {
+		errors[key] = fieldErrors[0].Localize(lang)
+	}
+	return errors
+}
+
 func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 	for i := range permittedValues {
 		if value == permittedValues[i] {
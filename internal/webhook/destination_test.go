@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestPublic(t *testing.T) {
+	tests := []struct {
+		name     string
+		addr     string
+		expected bool
+	}{
+		{"public ipv4 test", "93.184.216.34", true},
+		{"public ipv6 test", "2606:2800:220:1:248:1893:25c8:1946", true},
+		{"loopback test", "127.0.0.1", false},
+		{"ipv6 loopback test", "::1", false},
+		{"private test", "10.1.2.3", false},
+		{"private 192 test", "192.168.0.10", false},
+		{"metadata service test", "169.254.169.254", false},
+		{"unspecified test", "0.0.0.0", false},
+		{"carrier nat test", "100.64.0.1", false},
+		{"mapped loopback test", "::ffff:127.0.0.1", false},
+		{"unique local test", "fd00::1", false},
+		{"multicast test", "224.0.0.1", false},
+	}
+
+	for _, e := range tests {
+		if got := Public(netip.MustParseAddr(e.addr)); got != e.expected {
+			t.Errorf("%s: expected %t but got %t", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestCheckDestination(t *testing.T) {
+	tests := []struct {
+		name          string
+		url           string
+		expectedError error
+	}{
+		{"public test", "https://93.184.216.34/hook", nil},
+		{"loopback test", "http://127.0.0.1:8080/hook", ErrNotPublic},
+		{"metadata service test", "http://169.254.169.254/latest/meta-data", ErrNotPublic},
+		{"ipv6 test", "http://[::1]/hook", ErrNotPublic},
+	}
+
+	for _, e := range tests {
+		err := CheckDestination(context.Background(), e.url)
+		if !errors.Is(err, e.expectedError) {
+			t.Errorf("%s: expected %v but got %v", e.name, e.expectedError, err)
+		}
+	}
+}
+
+func TestNewClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, err := NewClient(time.Second).Get(srv.URL)
+	if !errors.Is(err, ErrNotPublic) {
+		t.Errorf("expected ErrNotPublic but got %v", err)
+	}
+}
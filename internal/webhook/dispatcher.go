@@ -0,0 +1,180 @@
+// Package webhook delivers the events recorded in the outbox to the webhooks
+// subscribed to them.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderEvent     = "X-QuickMovies-Event"
+	HeaderDelivery  = "X-QuickMovies-Delivery"
+	HeaderSignature = "X-QuickMovies-Signature"
+)
+
+// Sign returns the signature header value for body, the hex encoded
+// HMAC-SHA256 of the body keyed with the webhook secret. Receivers should
+// compute the same value and compare it with hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher polls the outbox and delivers events. Failed deliveries are
+// retried with exponential backoff until MaxAttempts is reached, and a webhook
+// is disabled after DisableAfter consecutive failed attempts.
+type Dispatcher struct {
+	Webhooks     data.Webhooks
+	Client       *http.Client
+	ErrorLog     *log.Logger
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	DisableAfter int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	// Lease is how long a claimed delivery is hidden from other dispatchers.
+	// It must be longer than the client timeout.
+	Lease time.Duration
+}
+
+func NewDispatcher(webhooks data.Webhooks, errorLog *log.Logger) *Dispatcher {
+	return &Dispatcher{
+		Webhooks:     webhooks,
+		Client:       NewClient(10 * time.Second),
+		ErrorLog:     errorLog,
+		PollInterval: time.Second,
+		BatchSize:    50,
+		MaxAttempts:  8,
+		DisableAfter: 20,
+		BackoffBase:  5 * time.Second,
+		BackoffMax:   time.Hour,
+		Lease:        time.Minute,
+	}
+}
+
+// Run dispatches events every PollInterval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		err := d.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.ErrorLog.Printf("webhook dispatch failed %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce fans out pending outbox events and attempts every delivery that is
+// due.
+func (d *Dispatcher) RunOnce(ctx context.Context) error {
+	for {
+		n, err := d.Webhooks.FanOutEvents(ctx, d.BatchSize)
+		if err != nil {
+			return err
+		}
+		if n < d.BatchSize {
+			break
+		}
+	}
+
+	deliveries, err := d.Webhooks.ClaimDeliveries(ctx, d.BatchSize, d.Lease)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery *data.WebhookDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+			err := d.Webhooks.RecordAttempt(ctx, delivery, d.DisableAfter)
+			if err != nil && ctx.Err() == nil {
+				d.ErrorLog.Printf("failed to record webhook delivery %d %s", delivery.ID, err)
+			}
+		}(delivery)
+	}
+	wg.Wait()
+	return nil
+}
+
+// deliver posts the event and updates delivery with the outcome.
+func (d *Dispatcher) deliver(ctx context.Context, delivery *data.WebhookDelivery) {
+	delivery.Attempts++
+	delivery.LastStatusCode = 0
+	delivery.LastError = ""
+
+	statusCode, err := d.post(ctx, delivery)
+	delivery.LastStatusCode = int32(statusCode)
+	switch {
+	case err != nil:
+		delivery.LastError = err.Error()
+	case statusCode < 200 || statusCode > 299:
+		delivery.LastError = fmt.Sprintf("unexpected status %d", statusCode)
+	default:
+		delivery.Status = data.DeliverySucceeded
+		delivery.NextAttemptAt = time.Now()
+		return
+	}
+
+	if int(delivery.Attempts) >= d.MaxAttempts {
+		delivery.Status = data.DeliveryFailed
+		delivery.NextAttemptAt = time.Now()
+		return
+	}
+	delivery.Status = data.DeliveryPending
+	delivery.NextAttemptAt = time.Now().Add(d.backoff(int(delivery.Attempts)))
+}
+
+func (d *Dispatcher) post(ctx context.Context, delivery *data.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "QuickMovies-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, delivery.Payload))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	// Drain a little of the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	return res.StatusCode, nil
+}
+
+// backoff returns the delay before the retry following attempt, doubling from
+// BackoffBase up to BackoffMax.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.BackoffBase
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= d.BackoffMax {
+			return d.BackoffMax
+		}
+	}
+	return delay
+}
@@ -0,0 +1,171 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/rrebeiz/quickmovies/internal/data"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef"
+
+func newTestDispatcher(t *testing.T, handler http.HandlerFunc, events ...string) (*Dispatcher, *data.MemoryMovieModel, *data.Webhook) {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	movies := data.NewMemoryMovieModel()
+	webhooks := data.NewMemoryWebhookModel(movies)
+	webhook := &data.Webhook{URL: srv.URL, Events: events, Secret: testSecret, Active: true}
+	err := webhooks.CreateWebhook(context.Background(), webhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDispatcher(webhooks, log.New(io.Discard, "", 0))
+	// the test server listens on loopback, which NewClient refuses.
+	d.Client = srv.Client()
+	d.BackoffBase = 0
+	return d, movies, webhook
+}
+
+func TestDispatcherDelivery(t *testing.T) {
+	var received atomic.Int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(HeaderSignature) != Sign(testSecret, body) {
+			t.Errorf("expected a valid signature but got %q", r.Header.Get(HeaderSignature))
+		}
+		if r.Header.Get(HeaderEvent) != data.EventMovieCreated {
+			t.Errorf("expected event %s but got %s", data.EventMovieCreated, r.Header.Get(HeaderEvent))
+		}
+		var payload struct {
+			Type  string     `json:"type"`
+			Movie data.Movie `json:"movie"`
+		}
+		err := json.Unmarshal(body, &payload)
+		if err != nil || payload.Movie.Title != "test" {
+			t.Errorf("expected the created movie in the payload but got %s", body)
+		}
+		received.Add(1)
+	}
+	d, movies, webhook := newTestDispatcher(t, handler, data.EventMovieCreated)
+
+	ctx := context.Background()
+	movie := &data.Movie{Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action"}}
+	err := movies.CreateMovie(ctx, movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Not subscribed, so this must not be delivered.
+	err = movies.DeleteMovie(ctx, movie.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.RunOnce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.Load() != 1 {
+		t.Errorf("expected 1 delivery but got %d", received.Load())
+	}
+
+	deliveries, _ := d.Webhooks.GetDeliveries(ctx, webhook.ID)
+	if len(deliveries) != 1 || deliveries[0].Status != data.DeliverySucceeded {
+		t.Fatalf("expected 1 succeeded delivery but got %+v", deliveries)
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	var calls atomic.Int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+	d, movies, webhook := newTestDispatcher(t, handler, data.EventTypes...)
+
+	ctx := context.Background()
+	err := movies.CreateMovie(ctx, &data.Movie{Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		err = d.RunOnce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deliveries, _ := d.Webhooks.GetDeliveries(ctx, webhook.ID)
+	if len(deliveries) != 1 {
+		t.Fatalf("expected 1 delivery but got %d", len(deliveries))
+	}
+	if deliveries[0].Status != data.DeliverySucceeded || deliveries[0].Attempts != 3 {
+		t.Errorf("expected a delivery succeeding after 3 attempts but got %s after %d", deliveries[0].Status, deliveries[0].Attempts)
+	}
+	stored, _ := d.Webhooks.GetWebhook(ctx, webhook.ID)
+	if stored.FailureCount != 0 {
+		t.Errorf("expected the failure count to be reset but got %d", stored.FailureCount)
+	}
+}
+
+func TestDispatcherDisablesFailingWebhook(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	d, movies, webhook := newTestDispatcher(t, handler, data.EventTypes...)
+	d.MaxAttempts = 2
+	d.DisableAfter = 3
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		err := movies.CreateMovie(ctx, &data.Movie{Title: "test", Runtime: 100, Year: 2020, Genres: []string{"action"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		err := d.RunOnce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stored, _ := d.Webhooks.GetWebhook(ctx, webhook.ID)
+	if stored.Active {
+		t.Errorf("expected the webhook to be disabled after %d failures", stored.FailureCount)
+	}
+	deliveries, _ := d.Webhooks.GetDeliveries(ctx, webhook.ID)
+	for _, delivery := range deliveries {
+		if delivery.Attempts > int32(d.MaxAttempts) {
+			t.Errorf("expected at most %d attempts but got %d", d.MaxAttempts, delivery.Attempts)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{BackoffBase: time.Second, BackoffMax: 10 * time.Second}
+	tests := []struct {
+		name     string
+		attempt  int
+		expected time.Duration
+	}{
+		{name: "first retry test", attempt: 1, expected: time.Second},
+		{name: "third retry test", attempt: 3, expected: 4 * time.Second},
+		{name: "capped retry test", attempt: 10, expected: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		actual := d.backoff(tt.attempt)
+		if actual != tt.expected {
+			t.Errorf("%s: expected %s but got %s", tt.name, tt.expected, actual)
+		}
+	}
+}
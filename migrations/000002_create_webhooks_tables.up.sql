@@ -0,0 +1,38 @@
+create table if not exists outbox_events (
+    id bigserial primary key,
+    event_type text not null,
+    movie_id bigint not null,
+    payload jsonb not null,
+    created_at timestamp(0) with time zone not null default now(),
+    fanned_out_at timestamp(0) with time zone
+);
+
+create index if not exists outbox_events_pending_idx on outbox_events (id) where fanned_out_at is null;
+
+create table if not exists webhooks (
+    id bigserial primary key,
+    url text not null,
+    events text[] not null,
+    secret text not null,
+    active boolean not null default true,
+    failure_count integer not null default 0,
+    version integer not null default 1,
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now()
+);
+
+create table if not exists webhook_deliveries (
+    id bigserial primary key,
+    webhook_id bigint not null references webhooks on delete cascade,
+    event_id bigint not null references outbox_events on delete cascade,
+    status text not null default 'pending',
+    attempts integer not null default 0,
+    last_status_code integer not null default 0,
+    last_error text not null default '',
+    next_attempt_at timestamp(3) with time zone not null default now(),
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now(),
+    constraint webhook_deliveries_status_check check ( status in ('pending', 'succeeded', 'failed') )
+);
+
+create index if not exists webhook_deliveries_due_idx on webhook_deliveries (next_attempt_at) where status = 'pending';
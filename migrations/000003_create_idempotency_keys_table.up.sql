@@ -0,0 +1,11 @@
+create table if not exists idempotency_keys (
+    key text primary key,
+    fingerprint text not null,
+    status integer not null default 0,
+    headers jsonb not null default '{}',
+    body bytea not null default '',
+    created_at timestamp(0) with time zone not null default now(),
+    expires_at timestamp(3) with time zone not null
+);
+
+create index if not exists idempotency_keys_expires_at_idx on idempotency_keys (expires_at);
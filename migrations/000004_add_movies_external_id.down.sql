@@ -0,0 +1,2 @@
+alter table movies drop constraint if exists movies_external_id_key;
+alter table movies drop column if exists external_id;
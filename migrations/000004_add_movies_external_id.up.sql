@@ -0,0 +1,2 @@
+alter table movies add column if not exists external_id text;
+alter table movies add constraint movies_external_id_key unique (external_id);
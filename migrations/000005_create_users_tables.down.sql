@@ -0,0 +1,4 @@
+drop table if exists tokens;
+drop table if exists users_permissions;
+drop table if exists permissions;
+drop table if exists users;
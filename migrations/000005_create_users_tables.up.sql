@@ -0,0 +1,31 @@
+create table if not exists users (
+    id bigserial primary key,
+    name text not null,
+    email text not null,
+    created_at timestamp(0) with time zone not null default now(),
+    constraint users_email_key unique (email)
+);
+
+create table if not exists permissions (
+    id bigserial primary key,
+    code text not null unique
+);
+
+insert into permissions (code) values ('movies:read'), ('movies:write'), ('webhooks:read'), ('webhooks:write')
+    on conflict do nothing;
+
+create table if not exists users_permissions (
+    user_id bigint not null references users on delete cascade,
+    permission_id bigint not null references permissions on delete cascade,
+    primary key (user_id, permission_id)
+);
+
+create table if not exists tokens (
+    hash bytea primary key,
+    user_id bigint not null references users on delete cascade,
+    scope text not null,
+    expiry timestamp(0) with time zone not null,
+    created_at timestamp(0) with time zone not null default now()
+);
+
+create index if not exists tokens_expiry_idx on tokens (expiry);
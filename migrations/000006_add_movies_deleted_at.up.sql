@@ -0,0 +1,2 @@
+alter table movies add column if not exists deleted_at timestamp(0) with time zone;
+create index if not exists movies_deleted_at_idx on movies (deleted_at) where deleted_at is not null;
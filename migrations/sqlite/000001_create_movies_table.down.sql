@@ -0,0 +1 @@
+drop table if exists movies;
\ No newline at end of file
@@ -0,0 +1,12 @@
+create table if not exists movies (
+    id integer primary key autoincrement,
+    title text not null,
+    year integer not null,
+    runtime integer not null,
+    genres text not null,
+    version integer not null default 1,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    constraint movies_runtime_check check ( runtime >= 0 ),
+    constraint genres_length_check check ( json_valid(genres) and json_array_length(genres) between 1 and 5 )
+);
@@ -0,0 +1,37 @@
+create table if not exists outbox_events (
+    id integer primary key autoincrement,
+    event_type text not null,
+    movie_id integer not null,
+    payload text not null,
+    created_at timestamp not null default current_timestamp,
+    fanned_out_at timestamp
+);
+
+create table if not exists webhooks (
+    id integer primary key autoincrement,
+    url text not null,
+    events text not null,
+    secret text not null,
+    active boolean not null default true,
+    failure_count integer not null default 0,
+    version integer not null default 1,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    constraint webhooks_events_check check ( json_valid(events) )
+);
+
+create table if not exists webhook_deliveries (
+    id integer primary key autoincrement,
+    webhook_id integer not null references webhooks on delete cascade,
+    event_id integer not null references outbox_events on delete cascade,
+    status text not null default 'pending',
+    attempts integer not null default 0,
+    last_status_code integer not null default 0,
+    last_error text not null default '',
+    next_attempt_at timestamp not null default current_timestamp,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    constraint webhook_deliveries_status_check check ( status in ('pending', 'succeeded', 'failed') )
+);
+
+create index if not exists webhook_deliveries_due_idx on webhook_deliveries (status, next_attempt_at);
@@ -0,0 +1,11 @@
+create table if not exists idempotency_keys (
+    key text primary key,
+    fingerprint text not null,
+    status integer not null default 0,
+    headers text not null default '{}',
+    body blob not null default x'',
+    created_at timestamp not null default current_timestamp,
+    expires_at timestamp not null
+);
+
+create index if not exists idempotency_keys_expires_at_idx on idempotency_keys (expires_at);
@@ -0,0 +1,2 @@
+drop index if exists movies_external_id_key;
+alter table movies drop column external_id;
@@ -0,0 +1,2 @@
+alter table movies add column external_id text;
+create unique index if not exists movies_external_id_key on movies (external_id);
@@ -0,0 +1,31 @@
+create table if not exists users (
+    id integer primary key autoincrement,
+    name text not null,
+    email text not null,
+    created_at timestamp not null default current_timestamp,
+    constraint users_email_key unique (email)
+);
+
+create table if not exists permissions (
+    id integer primary key autoincrement,
+    code text not null unique
+);
+
+insert into permissions (code) values ('movies:read'), ('movies:write'), ('webhooks:read'), ('webhooks:write')
+    on conflict do nothing;
+
+create table if not exists users_permissions (
+    user_id integer not null references users on delete cascade,
+    permission_id integer not null references permissions on delete cascade,
+    primary key (user_id, permission_id)
+);
+
+create table if not exists tokens (
+    hash blob primary key,
+    user_id integer not null references users on delete cascade,
+    scope text not null,
+    expiry timestamp not null,
+    created_at timestamp not null default current_timestamp
+);
+
+create index if not exists tokens_expiry_idx on tokens (expiry);
@@ -0,0 +1,3 @@
+delete from movies where deleted_at is not null;
+drop index if exists movies_deleted_at_idx;
+alter table movies drop column deleted_at;
@@ -0,0 +1 @@
+insert into permissions (code) values ('metrics:read') on conflict do nothing;